@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// sessionOnlyPaths manage the account itself and can not be used with a personal access token.
+var sessionOnlyPaths = []string{"/tokens", "/sessions", "/identities", "/passwordReset", "/totp", "/guardians", "/wards", "/push/", "/webhooks", "/calendars", "/appPasswords"}
+
+// accessTokenScope returns the scope a personal access token needs for a request.
+// ok is false for endpoints that can only be used with a session.
+func accessTokenScope(r *http.Request) (scope gen.TokenScope, ok bool) {
+	path := r.URL.Path
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if strings.HasPrefix(path, "/user/") || strings.HasPrefix(path, "/oidc/") || path == "/logout" {
+		return "", false
+	}
+	for _, part := range sessionOnlyPaths {
+		if strings.Contains(path, part) {
+			return "", false
+		}
+	}
+	switch {
+	case strings.HasPrefix(path, "/view"), strings.HasPrefix(path, "/week/"), path == "/cafeteria", path == "/stream", path == "/changes":
+		// PUT /view only reads, the options are sent in the body
+		return gen.TokenScopeViewRead, true
+	case path == "/untis/fetch", path == "/untis/sync":
+		return gen.TokenScopeAdmin, true
+	case strings.HasPrefix(path, "/untis/") && read:
+		return gen.TokenScopeUntisRead, true
+	case strings.HasPrefix(path, "/users/") && strings.Contains(path, "/choices"):
+		if read {
+			return gen.TokenScopeChoicesRead, true
+		}
+		return gen.TokenScopeChoicesWrite, true
+	case (path == "/currentUser" || strings.HasPrefix(path, "/users")) && read:
+		return gen.TokenScopeUserRead, true
+	}
+	return gen.TokenScopeAdmin, true
+}
+
+// Get the personal access tokens of a user
+// (GET /users/{userId}/tokens)
+func (server Server) GetUsersUserIdTokens(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	tokens, err := server.DB.GetAccessTokens(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(tokens)
+}
+
+// Create a personal access token
+// (POST /users/{userId}/tokens)
+func (server Server) PostUsersUserIdTokens(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user, claims := principal.User, principal.Claims
+	if userId == -1 {
+		userId = *user.Id
+	}
+	// Tokens carry the crypto key of their user, so they can only be created for yourself.
+	if userId != *user.Id {
+		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+		return
+	}
+	var body gen.PostUsersUserIdTokensJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || strings.TrimSpace(body.Name) == "" || len(body.Scopes) == 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	knownScopes := []gen.TokenScope{gen.TokenScopeAdmin, gen.TokenScopeChoicesRead, gen.TokenScopeChoicesWrite, gen.TokenScopeUntisRead, gen.TokenScopeUserRead, gen.TokenScopeViewRead}
+	scopes := make([]string, 0, len(body.Scopes))
+	for _, scope := range body.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			http.Error(w, "Unknown scope: "+string(scope), http.StatusBadRequest)
+			return
+		}
+		if scope == gen.TokenScopeAdmin && !authz.Can(userRole(user), authz.All) {
+			http.Error(w, "Only admins can create tokens with the admin scope.", http.StatusForbidden)
+			return
+		}
+		if !slices.Contains(scopes, string(scope)) {
+			scopes = append(scopes, string(scope))
+		}
+	}
+	var expiresAt time.Time
+	if body.ExpiresAt != nil {
+		if body.ExpiresAt.Before(time.Now()) {
+			http.Error(w, "Expiry is in the past.", http.StatusBadRequest)
+			return
+		}
+		expiresAt = *body.ExpiresAt
+	}
+	// Without a crypto key (e.g. tokens of old sessions) the token can not access Untis.
+	cryptoKey, err := claims.UnwrapCryptoKey()
+	if err != nil {
+		cryptoKey = nil
+	}
+	token, entry, err := server.DB.CreateAccessToken(userId, strings.TrimSpace(body.Name), scopes, expiresAt, cryptoKey, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	server.audit(r, "token.create", "user", userId, map[string]interface{}{"tokenId": entry.Id, "name": entry.Name, "scopes": scopes})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(gen.NewPersonalAccessToken{Token: token, AccessToken: entry})
+}
+
+// Revoke a personal access token
+// (DELETE /users/{userId}/tokens/{tokenId})
+func (server Server) DeleteUsersUserIdTokensTokenId(w http.ResponseWriter, r *http.Request, userId int, tokenId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	err := server.DB.RevokeAccessToken(userId, tokenId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrAccessTokenNotFound) {
+			http.Error(w, "Token not found.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	server.audit(r, "token.revoke", "user", userId, map[string]interface{}{"tokenId": tokenId})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// Get the CalDAV app passwords of a user
+// (GET /users/{userId}/appPasswords)
+func (server Server) GetUsersUserIdAppPasswords(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	passwords, err := server.DB.GetAppPasswords(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(passwords)
+}
+
+// Create an app password for CalDAV clients
+// (POST /users/{userId}/appPasswords)
+func (server Server) PostUsersUserIdAppPasswords(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	// App passwords sign in as their user, so they can only be created for yourself.
+	if userId != *user.Id {
+		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+		return
+	}
+	if !config.Config.CalDAV.Enabled {
+		http.Error(w, "CalDAV is disabled.", http.StatusNotFound)
+		return
+	}
+	var body gen.PostUsersUserIdAppPasswordsJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var name string
+	if body.Name != nil {
+		name = strings.TrimSpace(*body.Name)
+	}
+	newPassword, err := server.DB.CreateAppPassword(userId, name, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrUserNotFound) {
+			http.Error(w, "User not found.", http.StatusNotFound)
+		} else if errors.Is(err, dbModels.ErrAppPasswordLimitReached) {
+			http.Error(w, "The maximum number of app passwords is reached. Revoke a password first.", http.StatusConflict)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	server.audit(r, "appPassword.create", "user", userId, map[string]interface{}{"appPasswordId": newPassword.AppPassword.Id, "name": name})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(newPassword)
+}
+
+// Revoke an app password
+// (DELETE /users/{userId}/appPasswords/{appPasswordId})
+func (server Server) DeleteUsersUserIdAppPasswordsAppPasswordId(w http.ResponseWriter, r *http.Request, userId int, appPasswordId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	err := server.DB.DeleteAppPassword(userId, appPasswordId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrAppPasswordNotFound) {
+			http.Error(w, "App password not found.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	server.audit(r, "appPassword.revoke", "user", userId, map[string]interface{}{"appPasswordId": appPasswordId})
+	w.WriteHeader(http.StatusNoContent)
+}
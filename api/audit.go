@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
+)
+
+// audit records an action of the authenticated user in the audit log. targetId 0 means the action has no single target.
+// A failure is only logged, the action itself already happened.
+func (server Server) audit(r *http.Request, action string, targetType string, targetId int, diff map[string]interface{}) {
+	event := db.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		IP:         clientIP(r),
+		Diff:       diff,
+	}
+	if targetId != 0 {
+		event.TargetId = strconv.Itoa(targetId)
+	}
+	if principal, ok := PrincipalFromContext(r.Context()); ok && principal.User.Id != nil {
+		event.ActorId = *principal.User.Id
+		event.ActorName = principal.User.Name
+	}
+	if err := server.DB.RecordAudit(event, r.Context()); err != nil {
+		log.Printf("Failed to record %s in the audit log: %s", action, err.Error())
+	}
+}
+
+// auditChange adds a field to diff if its value changed.
+func auditChange(diff map[string]interface{}, field string, old interface{}, new interface{}) {
+	if !reflect.DeepEqual(old, new) {
+		diff[field] = map[string]interface{}{"old": old, "new": new}
+	}
+}
+
+// Get the audit log (admin)
+// (GET /audit)
+func (server Server) GetAudit(w http.ResponseWriter, r *http.Request, params gen.GetAuditParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	if !authorize(w, principal.User, authz.AuditRead) {
+		return
+	}
+	entries, err := server.DB.GetAuditLog(params, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// Check the hash chain of the audit log (admin)
+// (GET /audit/verify)
+func (server Server) GetAuditVerify(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	if !authorize(w, principal.User, authz.AuditRead) {
+		return
+	}
+	result, err := server.DB.VerifyAuditLog(r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(result)
+}
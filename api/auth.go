@@ -3,12 +3,79 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
 )
 
+type loginResponse struct {
+	Token        string
+	RefreshToken string
+	ExpiresAt    time.Time
+	User         gen.User
+	// Only set when the login confirmed a TOTP enrollment.
+	RecoveryCodes []string `json:",omitempty"`
+	// Token to send in the X-CSRF-Token header with cookie authenticated requests, see config.CookieConfig.
+	CsrfToken string `json:",omitempty"`
+}
+
+// partialLoginResponse is returned by PostLogin if the user has to send a second factor to POST /login/totp.
+type partialLoginResponse struct {
+	PartialToken string
+	// The user has to enroll TOTP with POST /login/totp/enroll first.
+	EnrollmentRequired bool
+}
+
+// setSessionCookies stores the access token and the refresh token as cookies and returns the CSRF token of the session.
+// The refresh token cookie is only sent to the refresh endpoint.
+func setSessionCookies(w http.ResponseWriter, r *http.Request, tokens db.SessionTokens) string {
+	sameSite := http.SameSiteNoneMode
+	if config.Config.Cookies.Hardened {
+		sameSite = http.SameSiteLaxMode
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    tokens.AccessToken,
+		Path:     "/",
+		Domain:   config.Config.Cookies.Domain,
+		HttpOnly: config.Config.Cookies.Hardened, // This makes the cookie inaccessible via JavaScript
+		Secure:   true,                           // Set to true if you're using HTTPS
+		SameSite: sameSite,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    tokens.RefreshToken,
+		Path:     "/token/refresh",
+		Domain:   config.Config.Cookies.Domain,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: sameSite,
+	})
+	return setCSRFCookie(w, r)
+}
+
+// clearSessionCookies removes the cookies set by setSessionCookies.
+func clearSessionCookies(w http.ResponseWriter) {
+	for name, path := range map[string]string{"session_token": "/", "refresh_token": "/token/refresh", csrfCookieName: "/"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     path,
+			Domain:   config.Config.Cookies.Domain,
+			MaxAge:   -1,
+			HttpOnly: name != csrfCookieName,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
 // Login and get a token
 // (POST /login)
 func (server Server) PostLogin(w http.ResponseWriter, r *http.Request) {
@@ -18,48 +85,120 @@ func (server Server) PostLogin(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	type respStruct struct {
-		Token string
-		User  gen.User
+	if body.Username == nil || body.Password == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	accountKey := "user:" + strings.ToLower(*body.Username)
+	throttleKeys := []string{accountKey, "ip:" + clientIP(r)}
+	if server.checkThrottle(w, r, "login", throttleKeys) != nil {
+		return
 	}
-	var resp respStruct
 
-	resp.Token, resp.User, err = server.DB.CreateSession(body, r.Context())
+	tokens, user, err := server.DB.CreateSession(body, r.UserAgent(), clientIP(r), r.Context())
 	if err != nil {
 		if errors.Is(err, dbModels.ErrUserNotFound) || errors.Is(err, dbModels.ErrInvalidPassword) {
+			server.recordFailure(r, "login", throttleKeys)
 			http.Error(w, "Wrong credentials!", http.StatusUnauthorized)
 		} else {
 			http.Error(w, "Internal server error."+err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
+	server.recordSuccess(r, "login", []string{accountKey})
+	if tokens.PartialToken != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(partialLoginResponse{
+			PartialToken:       tokens.PartialToken,
+			EnrollmentRequired: tokens.EnrollmentRequired,
+		})
+		return
+	}
 
-	// Set the session token as a cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    resp.Token,
-		Path:     "/",
-		HttpOnly: false, // This makes the cookie inaccessible via JavaScript
-		Secure:   true,  // Set to true if you're using HTTPS
-		SameSite: http.SameSiteNoneMode,
+	// Set the session tokens as cookies
+	csrfToken := setSessionCookies(w, r, tokens)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(loginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		User:         user,
+		CsrfToken:    csrfToken,
 	})
+}
+
+// Exchange a refresh token for a new access and refresh token
+// (POST /token/refresh)
+func (server Server) PostTokenRefresh(w http.ResponseWriter, r *http.Request) {
+	var body gen.PostTokenRefreshJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	refreshToken := ""
+	if body.RefreshToken != nil {
+		refreshToken = *body.RefreshToken
+	} else if cookie, err := r.Cookie("refresh_token"); err == nil {
+		refreshToken = cookie.Value
+	}
+	var tokens db.SessionTokens
+	var user gen.User
+	if refreshToken != "" {
+		tokens, user, err = server.DB.RefreshSession(refreshToken, r.Context())
+	} else if accessToken, cookieErr := tokenFromRequest(r); cookieErr == nil && config.Config.Crypto.AcceptLegacyCryptoKeyClaim {
+		// Tokens issued before refresh tokens existed are exchanged once for a session
+		tokens, user, err = server.DB.ExchangeLegacyToken(accessToken, r.UserAgent(), clientIP(r), r.Context())
+	} else {
+		http.Error(w, "No refresh token provided.", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, dbModels.ErrRefreshTokenInvalid) ||
+			errors.Is(err, dbModels.ErrRefreshTokenReused) ||
+			errors.Is(err, dbModels.ErrUserNotFound) {
+			http.Error(w, "Invalid refresh token.", http.StatusUnauthorized)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	if tokens.PartialToken != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(partialLoginResponse{
+			PartialToken:       tokens.PartialToken,
+			EnrollmentRequired: tokens.EnrollmentRequired,
+		})
+		return
+	}
+
+	csrfToken := setSessionCookies(w, r, tokens)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(resp)
+	_ = json.NewEncoder(w).Encode(loginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		User:         user,
+		CsrfToken:    csrfToken,
+	})
 }
 
 // Logout and invalidate token
 // (POST /logout)
 func (server Server) PostLogout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: false, // This makes the cookie inaccessible via JavaScript
-		Secure:   true,  // Set to true if you're using HTTPS
-		SameSite: http.SameSiteNoneMode,
-	})
+	token, err := tokenFromRequest(r)
+	if err == nil {
+		err = server.DB.EndSession(token, r.Context())
+		if err != nil {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	clearSessionCookies(w)
 	w.WriteHeader(http.StatusOK)
 	// _ = json.NewEncoder(w).Encode(resp)
 }
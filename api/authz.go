@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+)
+
+// userRole returns the role of a user, "" if it has none.
+func userRole(user gen.User) string {
+	if user.Role == nil {
+		return ""
+	}
+	return string(*user.Role)
+}
+
+// operationPermissions are the permissions the secured operations need, by the pattern of their route.
+// The role of the user has to grant one of them. Operations without an entry are left to the handler.
+var operationPermissions = map[string][]authz.Permission{
+	"GET /audit":              {authz.AuditRead},
+	"GET /audit/verify":       {authz.AuditRead},
+	"GET /changes":            {authz.ViewRead},
+	"GET /stream":             {authz.ViewRead},
+	"PUT /view":               {authz.ViewRead},
+	"PUT /view/user/{userId}": {authz.ViewRead},
+
+	"POST /guardians/accept":                    {authz.GuardiansManage},
+	"GET /users/{userId}/guardians":             {authz.GuardiansManage},
+	"POST /users/{userId}/guardians":            {authz.GuardiansManage},
+	"DELETE /users/{userId}/guardians/{linkId}": {authz.GuardiansManage},
+	"GET /users/{userId}/wards":                 {authz.GuardiansManage},
+	"DELETE /users/{userId}/wards/{linkId}":     {authz.GuardiansManage},
+
+	"GET /lockouts":                {authz.LockoutsManage},
+	"DELETE /lockouts/{lockoutId}": {authz.LockoutsManage},
+
+	"GET /untis/classes":  {authz.UntisRead},
+	"GET /untis/rooms":    {authz.UntisRead},
+	"GET /untis/subjects": {authz.UntisRead},
+	"GET /untis/teachers": {authz.UntisRead},
+	"GET /untis/fetch":    {authz.UntisFetch},
+	"GET /untis/sync":     {authz.UntisFetch},
+
+	"GET /users":             {authz.UsersRead},
+	"GET /users/{userId}":    {authz.UsersRead},
+	"PUT /users/{userId}":    {authz.UsersWrite},
+	"DELETE /users/{userId}": {authz.UsersDelete},
+
+	"GET /users/{userId}/choices":             {authz.ChoicesRead},
+	"GET /users/{userId}/choices/{choiceId}":  {authz.ChoicesRead},
+	"POST /users/{userId}/choices/{choiceId}": {authz.ChoicesWrite},
+
+	"GET /users/{userId}/calendars":                 {authz.CalendarsManage},
+	"POST /users/{userId}/calendars":                {authz.CalendarsManage},
+	"DELETE /users/{userId}/calendars/{calendarId}": {authz.CalendarsManage},
+
+	"GET /users/{userId}/identities":                      {authz.CredentialsManage},
+	"DELETE /users/{userId}/identities/{identityId}":      {authz.CredentialsManage},
+	"POST /users/{userId}/passwordReset":                  {authz.CredentialsManage},
+	"GET /users/{userId}/sessions":                        {authz.CredentialsManage},
+	"DELETE /users/{userId}/sessions":                     {authz.CredentialsManage},
+	"DELETE /users/{userId}/sessions/{sessionId}":         {authz.CredentialsManage},
+	"GET /users/{userId}/tokens":                          {authz.CredentialsManage},
+	"POST /users/{userId}/tokens":                         {authz.CredentialsManage},
+	"DELETE /users/{userId}/tokens/{tokenId}":             {authz.CredentialsManage},
+	"DELETE /users/{userId}/totp":                         {authz.CredentialsManage},
+	"GET /users/{userId}/appPasswords":                    {authz.CredentialsManage},
+	"POST /users/{userId}/appPasswords":                   {authz.CredentialsManage},
+	"DELETE /users/{userId}/appPasswords/{appPasswordId}": {authz.CredentialsManage},
+
+	"GET /users/{userId}/push/preferences":                       {authz.NotificationsManage},
+	"PUT /users/{userId}/push/preferences":                       {authz.NotificationsManage},
+	"GET /users/{userId}/push/subscriptions":                     {authz.NotificationsManage},
+	"POST /users/{userId}/push/subscriptions":                    {authz.NotificationsManage},
+	"DELETE /users/{userId}/push/subscriptions/{subscriptionId}": {authz.NotificationsManage},
+
+	"GET /users/{userId}/webhooks":                                            {authz.WebhooksManage},
+	"POST /users/{userId}/webhooks":                                           {authz.WebhooksManage},
+	"PUT /users/{userId}/webhooks/{webhookId}":                                {authz.WebhooksManage},
+	"DELETE /users/{userId}/webhooks/{webhookId}":                             {authz.WebhooksManage},
+	"POST /users/{userId}/webhooks/{webhookId}/secret":                        {authz.WebhooksManage},
+	"GET /users/{userId}/webhooks/{webhookId}/deliveries":                     {authz.WebhooksManage},
+	"POST /users/{userId}/webhooks/{webhookId}/deliveries/{deliveryId}/retry": {authz.WebhooksManage},
+}
+
+// requiredPermissions is the handler of a route in permissionRoutes, it only carries the permissions.
+type requiredPermissions []authz.Permission
+
+func (requiredPermissions) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+// permissionRoutes matches requests to operationPermissions the same way the router matches them to the operations.
+var permissionRoutes = func() *http.ServeMux {
+	mux := http.NewServeMux()
+	for pattern, permissions := range operationPermissions {
+		mux.Handle(pattern, requiredPermissions(permissions))
+	}
+	return mux
+}()
+
+// permissionsOf returns the permissions the operation of the request needs, nil if it needs none.
+func permissionsOf(r *http.Request) []authz.Permission {
+	handler, _ := permissionRoutes.Handler(r)
+	permissions, _ := handler.(requiredPermissions)
+	return permissions
+}
+
+// PermissionMiddleware rejects requests to operations that need permissions the role of the user does not grant.
+// The required permissions are listed in operationPermissions. Operations without an entry are left to the handler.
+// It runs after AuthMiddleware, which already rejected unauthenticated requests to secured operations.
+func (server Server) PermissionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := permissionsOf(r)
+		if len(required) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, ok := requirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		if !authz.CanAny(userRole(principal.User), required) {
+			http.Error(w, "Insufficient permission.", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize writes a 403 response and returns false if the role of the user does not grant the permission on every account.
+func authorize(w http.ResponseWriter, user gen.User, permission authz.Permission) bool {
+	if !authz.Can(userRole(user), permission) {
+		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// authorizeFor writes a 403 response and returns false if the role of the user does not grant the permission on the account userId.
+func authorizeFor(w http.ResponseWriter, user gen.User, permission authz.Permission, userId int) bool {
+	if !authz.CanFor(userRole(user), permission, user.Id != nil && *user.Id == userId) {
+		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+		return false
+	}
+	return true
+}
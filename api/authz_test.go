@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+)
+
+// requestFor returns a request that matches the route pattern, with 1 for every wildcard.
+func requestFor(pattern string) *http.Request {
+	method, path, _ := strings.Cut(pattern, " ")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") {
+			segments[i] = "1"
+		}
+	}
+	return httptest.NewRequest(method, strings.Join(segments, "/"), nil)
+}
+
+func TestOperationPermissionsMatchRoutes(t *testing.T) {
+	router := http.NewServeMux()
+	gen.HandlerWithOptions(Server{}, gen.StdHTTPServerOptions{BaseRouter: router})
+	for pattern, permissions := range operationPermissions {
+		request := requestFor(pattern)
+		if _, route := router.Handler(request); route != pattern {
+			t.Errorf("%q is not a route of the API, the request matches %q", pattern, route)
+		}
+		if got := permissionsOf(request); !slices.Equal(got, permissions) {
+			t.Errorf("permissionsOf(%q) = %v, want %v", pattern, got, permissions)
+		}
+	}
+}
+
+func TestPermissionsOf(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   []authz.Permission
+	}{
+		{http.MethodGet, "/users/-1/sessions", []authz.Permission{authz.CredentialsManage}},
+		{http.MethodDelete, "/users/3", []authz.Permission{authz.UsersDelete}},
+		{http.MethodHead, "/audit", []authz.Permission{authz.AuditRead}},
+		{http.MethodPost, "/login", nil},
+		{http.MethodGet, "/cafeteria", nil},
+		{http.MethodPost, "/audit", nil},
+		{http.MethodGet, "/unknown", nil},
+	}
+	for _, test := range tests {
+		got := permissionsOf(httptest.NewRequest(test.method, test.path, nil))
+		if !slices.Equal(got, test.want) {
+			t.Errorf("permissionsOf(%s %s) = %v, want %v", test.method, test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,628 @@
+package api
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/caldav"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/ical"
+)
+
+const (
+	// caldavPrefix is the path of the CalDAV server. Every user has a principal at /caldav/{userId}/ with the
+	// calendar /caldav/{userId}/timetable/ of the lessons of their default choice.
+	caldavPrefix = "/caldav/"
+	// caldavMethods are the methods of the read-only server.
+	caldavMethods = "OPTIONS, GET, HEAD, PROPFIND, REPORT"
+	// lessonContentType is the content type of a lesson resource.
+	lessonContentType = "text/calendar; charset=utf-8; component=VEVENT"
+)
+
+func principalHref(userId int) string {
+	return caldavPrefix + strconv.Itoa(userId) + "/"
+}
+
+func calendarHref(userId int) string {
+	return principalHref(userId) + "timetable/"
+}
+
+func lessonHref(userId int, lessonId int) string {
+	return calendarHref(userId) + "lesson-" + strconv.Itoa(lessonId) + ".ics"
+}
+
+// lessonIdOfHref returns the lesson of a resource href of the user, ok is false for any other href.
+func lessonIdOfHref(userId int, href string) (int, bool) {
+	if parsed, err := url.Parse(href); err == nil {
+		href = parsed.Path
+	}
+	name, found := strings.CutPrefix(href, calendarHref(userId))
+	if !found {
+		return 0, false
+	}
+	name, found = strings.CutPrefix(name, "lesson-")
+	if !found {
+		return 0, false
+	}
+	name, found = strings.CutSuffix(name, ".ics")
+	if !found {
+		return 0, false
+	}
+	lessonId, err := strconv.Atoi(name)
+	return lessonId, err == nil && lessonId > 0
+}
+
+// lessonETag returns the ETag of a lesson resource. It changes with Lesson.LastUpdate, which a fetch only sets when
+// the lesson changed.
+func lessonETag(lesson gen.Lesson) string {
+	var lastUpdate int64
+	if lesson.LastUpdate != nil {
+		lastUpdate = lesson.LastUpdate.UnixNano()
+	}
+	return "\"" + strconv.FormatInt(lastUpdate, 10) + "\""
+}
+
+// syncToken is the state of a calendar a client synchronized: the last LessonChange, the CalendarWindow and the
+// scope of the lessons. The window moves forward every day, the lessons entering and leaving it are found by date.
+type syncToken struct {
+	seq   int64
+	start time.Time
+	end   time.Time
+	scope string
+}
+
+// syncTokenDate is the format of the window in a sync-token.
+const syncTokenDate = "20060102"
+
+func (token syncToken) String() string {
+	return fmt.Sprintf("data:,%d-%s-%s-%s", token.seq, token.start.Format(syncTokenDate), token.end.Format(syncTokenDate), token.scope)
+}
+
+// parseSyncToken reads a sync-token written by syncToken.String. The days of the window are in the local time zone,
+// like the CalendarWindow.
+func parseSyncToken(value string) (syncToken, bool) {
+	value, found := strings.CutPrefix(value, "data:,")
+	if !found {
+		return syncToken{}, false
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 {
+		return syncToken{}, false
+	}
+	seq, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return syncToken{}, false
+	}
+	start, err := time.ParseInLocation(syncTokenDate, parts[1], time.Local)
+	if err != nil {
+		return syncToken{}, false
+	}
+	end, err := time.ParseInLocation(syncTokenDate, parts[2], time.Local)
+	if err != nil {
+		return syncToken{}, false
+	}
+	return syncToken{seq: seq, start: start, end: end, scope: parts[3]}, true
+}
+
+// calendarSyncToken returns the current sync-token of the calendar of the user. It changes when a fetch changes
+// lessons, when the window moves every day, and with the choice of the user.
+func (server Server) calendarSyncToken(user gen.User, ctx context.Context) (syncToken, error) {
+	seq, err := server.DB.LessonChangeSeq(ctx)
+	if err != nil {
+		return syncToken{}, err
+	}
+	scope, err := server.DB.CalendarScope(*user.Id, ctx)
+	if err != nil {
+		return syncToken{}, err
+	}
+	start, end := db.CalendarWindow()
+	return syncToken{seq: seq, start: start, end: end, scope: scope}, nil
+}
+
+// CalDAVHandler serves the read-only CalDAV calendars of the users under /caldav/ and their discovery at
+// /.well-known/caldav. Clients sign in with the name of the user and an app password.
+func (server Server) CalDAVHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Config.CalDAV.Enabled {
+			http.NotFound(w, r)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, caldavPrefix) {
+			http.Redirect(w, r, caldavPrefix, http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", caldavMethods)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		user, ok := server.caldavAuthenticate(w, r)
+		if !ok {
+			return
+		}
+		server.serveCalDAV(w, r, user)
+	})
+}
+
+// caldavAuthenticate checks the basic authentication of a CalDAV request and writes a 401 response if it fails.
+// Failed attempts are throttled like logins.
+func (server Server) caldavAuthenticate(w http.ResponseWriter, r *http.Request) (gen.User, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Basic realm=\"TMF Timetable\", charset=\"UTF-8\"")
+		http.Error(w, "Sign in with your user name and an app password.", http.StatusUnauthorized)
+		return gen.User{}, false
+	}
+	throttleKeys := []string{"user:" + strings.ToLower(username), "ip:" + clientIP(r)}
+	if server.checkThrottle(w, r, "caldav", throttleKeys) != nil {
+		return gen.User{}, false
+	}
+	user, err := server.DB.VerifyAppPassword(username, password, clientIP(r), r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrAppPasswordInvalid) || errors.Is(err, dbModels.ErrUserNotFound) {
+			server.recordFailure(r, "caldav", throttleKeys)
+			w.Header().Set("WWW-Authenticate", "Basic realm=\"TMF Timetable\", charset=\"UTF-8\"")
+			http.Error(w, "Wrong credentials!", http.StatusUnauthorized)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+		}
+		return gen.User{}, false
+	}
+	server.recordSuccess(r, "caldav", throttleKeys[:1])
+	return user, true
+}
+
+// serveCalDAV answers a request of an authenticated user. Users only see their own principal and calendar.
+func (server Server) serveCalDAV(w http.ResponseWriter, r *http.Request, user gen.User) {
+	userId := *user.Id
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, caldavPrefix), "/")
+	segments := strings.Split(path, "/")
+	if path != "" && segments[0] != strconv.Itoa(userId) {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case path == "":
+		server.caldavCollection(w, r, user, caldavPrefix, caldavRootProperties(userId), false)
+	case len(segments) == 1:
+		server.caldavCollection(w, r, user, principalHref(userId), caldavPrincipalProperties(user), true)
+	case len(segments) == 2 && segments[1] == "timetable":
+		server.caldavCalendar(w, r, user)
+	case len(segments) == 3 && segments[1] == "timetable":
+		lessonId, ok := lessonIdOfHref(userId, r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		server.caldavLesson(w, r, user, lessonId)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func caldavRootProperties(userId int) caldav.Properties {
+	return caldav.Properties{
+		caldav.ResourceType:         caldav.Element(xmlDAV("collection"), ""),
+		caldav.CurrentUserPrincipal: caldav.Href(principalHref(userId)),
+	}
+}
+
+func caldavPrincipalProperties(user gen.User) caldav.Properties {
+	return caldav.Properties{
+		caldav.ResourceType:         caldav.Element(xmlDAV("collection"), "") + caldav.Element(xmlDAV("principal"), ""),
+		caldav.DisplayName:          caldav.Text(user.Name),
+		caldav.CurrentUserPrincipal: caldav.Href(principalHref(*user.Id)),
+		caldav.PrincipalURL:         caldav.Href(principalHref(*user.Id)),
+		caldav.CalendarHomeSet:      caldav.Href(principalHref(*user.Id)),
+	}
+}
+
+func caldavCalendarProperties(userId int, syncToken string) caldav.Properties {
+	reports := ""
+	for _, report := range []string{
+		caldav.Element(caldav.CalendarQuery, ""),
+		caldav.Element(caldav.CalendarMultiget, ""),
+		caldav.Element(caldav.SyncCollection, ""),
+	} {
+		reports += caldav.Element(xmlDAV("supported-report"), caldav.Element(xmlDAV("report"), report))
+	}
+	return caldav.Properties{
+		caldav.ResourceType:                  caldav.Element(xmlDAV("collection"), "") + caldav.Element(xmlCalDAV("calendar"), ""),
+		caldav.DisplayName:                   caldav.Text("Timetable"),
+		caldav.CurrentUserPrincipal:          caldav.Href(principalHref(userId)),
+		caldav.Owner:                         caldav.Href(principalHref(userId)),
+		caldav.CurrentUserPrivilegeSet:       caldav.Element(xmlDAV("privilege"), caldav.Element(xmlDAV("read"), "")),
+		caldav.SupportedCalendarComponentSet: "<C:comp name=\"VEVENT\"/>",
+		caldav.SupportedReportSet:            reports,
+		caldav.GetCTag:                       caldav.Text(syncToken),
+		caldav.SyncToken:                     caldav.Text(syncToken),
+	}
+}
+
+// caldavCollection answers PROPFIND on the root or the principal. The calendar is the only member of the principal.
+func (server Server) caldavCollection(w http.ResponseWriter, r *http.Request, user gen.User, href string, properties caldav.Properties, hasCalendar bool) {
+	switch r.Method {
+	case "PROPFIND":
+		request, ok := parseCalDAVRequest(w, r)
+		if !ok {
+			return
+		}
+		responses := []caldav.Response{request.Response(href, properties)}
+		if r.Header.Get("Depth") != "0" && hasCalendar {
+			token, err := server.calendarSyncToken(user, r.Context())
+			if err != nil {
+				caldavError(w, err)
+				return
+			}
+			responses = append(responses, request.Response(calendarHref(*user.Id), caldavCalendarProperties(*user.Id, token.String())))
+		}
+		caldav.WriteMultistatus(w, responses, "")
+	default:
+		w.Header().Set("Allow", "OPTIONS, PROPFIND")
+		http.Error(w, "The CalDAV server is read-only.", http.StatusMethodNotAllowed)
+	}
+}
+
+// caldavLessons returns the lessons of the default choice of the user, or of its classes without one. The filter limits
+// them to a time range or to lesson ids, without either the lessons of the CalendarWindow are returned.
+func (server Server) caldavLessons(user gen.User, filter dbModels.LessonFilter, ctx context.Context) ([]gen.Lesson, error) {
+	filter.User = dbModels.User{Id: *user.Id}
+	filter.ClassFallback = true
+	if filter.StartDate.IsZero() && len(filter.LessonIds) == 0 {
+		filter.StartDate, filter.EndDate = db.CalendarWindow()
+	}
+	return server.DB.GetLesson(filter, ctx)
+}
+
+// lessonResponses returns the resources of the lessons with the properties the request asks for.
+func (server Server) lessonResponses(request caldav.Request, userId int, lessons []gen.Lesson, ctx context.Context) ([]caldav.Response, error) {
+	var events map[string]ical.Event
+	if request.Wants(caldav.CalendarData) {
+		lessonEvents, err := server.DB.LessonEvents(lessons, ctx)
+		if err != nil {
+			return nil, err
+		}
+		events = make(map[string]ical.Event, len(lessonEvents))
+		for _, event := range lessonEvents {
+			events[event.UID] = event
+		}
+	}
+	responses := make([]caldav.Response, 0, len(lessons))
+	for _, lesson := range lessons {
+		if lesson.Id == nil {
+			continue
+		}
+		properties := caldav.Properties{
+			caldav.ResourceType:   "",
+			caldav.GetETag:        caldav.Text(lessonETag(lesson)),
+			caldav.GetContentType: caldav.Text(lessonContentType),
+		}
+		if event, ok := events[db.LessonUID(*lesson.Id)]; ok {
+			properties[caldav.CalendarData] = caldav.Text(string(lessonCalendar(event).Encode()))
+		}
+		responses = append(responses, request.Response(lessonHref(userId, *lesson.Id), properties))
+	}
+	return responses, nil
+}
+
+// lessonCalendar returns the calendar object resource of a lesson.
+func lessonCalendar(event ical.Event) ical.Calendar {
+	return ical.Calendar{Stamp: time.Now(), Events: []ical.Event{event}}
+}
+
+// caldavCalendar answers the requests on the calendar of the user.
+func (server Server) caldavCalendar(w http.ResponseWriter, r *http.Request, user gen.User) {
+	userId := *user.Id
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		lessons, err := server.caldavLessons(user, dbModels.LessonFilter{}, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		events, err := server.DB.LessonEvents(lessons, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		body := ical.Calendar{Name: "Timetable", Stamp: time.Now(), Events: events}.Encode()
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	case "PROPFIND":
+		request, ok := parseCalDAVRequest(w, r)
+		if !ok {
+			return
+		}
+		token, err := server.calendarSyncToken(user, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		responses := []caldav.Response{request.Response(calendarHref(userId), caldavCalendarProperties(userId, token.String()))}
+		if r.Header.Get("Depth") != "0" {
+			lessons, err := server.caldavLessons(user, dbModels.LessonFilter{}, ctx)
+			if err != nil {
+				caldavError(w, err)
+				return
+			}
+			members, err := server.lessonResponses(request, userId, lessons, ctx)
+			if err != nil {
+				caldavError(w, err)
+				return
+			}
+			responses = append(responses, members...)
+		}
+		caldav.WriteMultistatus(w, responses, "")
+	case "REPORT":
+		request, ok := parseCalDAVRequest(w, r)
+		if !ok {
+			return
+		}
+		switch request.Type {
+		case caldav.CalendarQuery:
+			server.calendarQuery(w, r, user, request)
+		case caldav.CalendarMultiget:
+			server.calendarMultiget(w, r, user, request)
+		case caldav.SyncCollection:
+			server.syncCollection(w, r, user, request)
+		default:
+			caldav.WriteError(w, http.StatusForbidden, caldav.SupportedReport)
+		}
+	default:
+		w.Header().Set("Allow", caldavMethods)
+		http.Error(w, "The CalDAV server is read-only.", http.StatusMethodNotAllowed)
+	}
+}
+
+// calendarQuery answers a calendar-query report. The time-range is limited to CalDAV.MaxQueryRange, without one the
+// lessons of the CalendarWindow match.
+func (server Server) calendarQuery(w http.ResponseWriter, r *http.Request, user gen.User, request caldav.Request) {
+	var filter dbModels.LessonFilter
+	if !request.Start.IsZero() || !request.End.IsZero() {
+		start, end := request.Start, request.End
+		if start.IsZero() {
+			start = end.Add(-config.Config.CalDAV.MaxQueryRange)
+		}
+		if end.IsZero() || end.Sub(start) > config.Config.CalDAV.MaxQueryRange {
+			end = start.Add(config.Config.CalDAV.MaxQueryRange)
+		}
+		request.Start, request.End = start, end
+		// The lessons are loaded by containment, lessons overlapping the range are matched below
+		filter.StartDate, filter.EndDate = start.AddDate(0, 0, -1), end.AddDate(0, 0, 1)
+	}
+	lessons, err := server.caldavLessons(user, filter, r.Context())
+	if err != nil {
+		caldavError(w, err)
+		return
+	}
+	matching := make([]gen.Lesson, 0, len(lessons))
+	for _, lesson := range lessons {
+		if request.MatchesEvent(lesson.StartTime, lesson.EndTime) {
+			matching = append(matching, lesson)
+		}
+	}
+	responses, err := server.lessonResponses(request, *user.Id, matching, r.Context())
+	if err != nil {
+		caldavError(w, err)
+		return
+	}
+	caldav.WriteMultistatus(w, responses, "")
+}
+
+// calendarMultiget answers a calendar-multiget report. Hrefs of other lessons are not found.
+func (server Server) calendarMultiget(w http.ResponseWriter, r *http.Request, user gen.User, request caldav.Request) {
+	var responses []caldav.Response
+	var lessonIds []int
+	for _, href := range request.Hrefs {
+		if lessonId, ok := lessonIdOfHref(*user.Id, href); ok {
+			lessonIds = append(lessonIds, lessonId)
+		} else {
+			responses = append(responses, caldav.Response{Href: href, Status: http.StatusNotFound})
+		}
+	}
+	var lessons []gen.Lesson
+	if len(lessonIds) > 0 {
+		var err error
+		lessons, err = server.caldavLessons(user, dbModels.LessonFilter{LessonIds: lessonIds}, r.Context())
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+	}
+	found := make(map[int]bool, len(lessons))
+	for _, lesson := range lessons {
+		if lesson.Id != nil {
+			found[*lesson.Id] = true
+		}
+	}
+	for _, lessonId := range lessonIds {
+		if !found[lessonId] {
+			responses = append(responses, caldav.Response{Href: lessonHref(*user.Id, lessonId), Status: http.StatusNotFound})
+		}
+	}
+	members, err := server.lessonResponses(request, *user.Id, lessons, r.Context())
+	if err != nil {
+		caldavError(w, err)
+		return
+	}
+	caldav.WriteMultistatus(w, append(members, responses...), "")
+}
+
+// syncCollection answers a sync-collection report with the lessons changed since the sync-token, the lessons that
+// entered the window since and a 404 for the lessons that left it. Tokens of another scope are rejected, the client
+// then synchronizes from the start.
+func (server Server) syncCollection(w http.ResponseWriter, r *http.Request, user gen.User, request caldav.Request) {
+	ctx := r.Context()
+	// The token is read before the lessons, a change in between is sent again with the next sync
+	current, err := server.calendarSyncToken(user, ctx)
+	if err != nil {
+		caldavError(w, err)
+		return
+	}
+	if request.SyncToken == "" {
+		lessons, err := server.caldavLessons(user, dbModels.LessonFilter{}, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		responses, err := server.lessonResponses(request, *user.Id, lessons, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		caldav.WriteMultistatus(w, responses, current.String())
+		return
+	}
+
+	since, ok := parseSyncToken(request.SyncToken)
+	if !ok || since.scope != current.scope || since.seq > current.seq || current.start.Before(since.start) || current.end.Before(since.end) {
+		caldav.WriteError(w, http.StatusForbidden, caldav.ValidSyncToken)
+		return
+	}
+	changed := []gen.Lesson{}
+	if since.seq < current.seq {
+		changed, err = server.caldavLessons(user, dbModels.LessonFilter{ChangedAfter: since.seq}, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+	}
+	if since.end.Before(current.end) {
+		entered, err := server.caldavLessons(user, dbModels.LessonFilter{StartDate: maxTime(since.end, current.start), EndDate: current.end}, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		known := make(map[int]bool, len(changed))
+		for _, lesson := range changed {
+			known[*lesson.Id] = true
+		}
+		for _, lesson := range entered {
+			if !known[*lesson.Id] {
+				changed = append(changed, lesson)
+			}
+		}
+	}
+	responses, err := server.lessonResponses(request, *user.Id, changed, ctx)
+	if err != nil {
+		caldavError(w, err)
+		return
+	}
+	if since.start.Before(current.start) {
+		left, err := server.caldavLessons(user, dbModels.LessonFilter{StartDate: since.start, EndDate: minTime(current.start, since.end)}, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		for _, lesson := range left {
+			responses = append(responses, caldav.Response{Href: lessonHref(*user.Id, *lesson.Id), Status: http.StatusNotFound})
+		}
+	}
+	caldav.WriteMultistatus(w, responses, current.String())
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// caldavLesson answers the requests on the resource of a lesson.
+func (server Server) caldavLesson(w http.ResponseWriter, r *http.Request, user gen.User, lessonId int) {
+	ctx := r.Context()
+	lessons, err := server.caldavLessons(user, dbModels.LessonFilter{LessonIds: []int{lessonId}}, ctx)
+	if err != nil {
+		caldavError(w, err)
+		return
+	}
+	if len(lessons) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		events, err := server.DB.LessonEvents(lessons, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		if len(events) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		etag := lessonETag(lessons[0])
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", lessonContentType)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(lessonCalendar(events[0]).Encode())
+		}
+	case "PROPFIND":
+		request, ok := parseCalDAVRequest(w, r)
+		if !ok {
+			return
+		}
+		responses, err := server.lessonResponses(request, *user.Id, lessons, ctx)
+		if err != nil {
+			caldavError(w, err)
+			return
+		}
+		caldav.WriteMultistatus(w, responses, "")
+	default:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+		http.Error(w, "The CalDAV server is read-only.", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseCalDAVRequest reads the body of a PROPFIND or REPORT and writes a 400 response if it is invalid.
+func parseCalDAVRequest(w http.ResponseWriter, r *http.Request) (caldav.Request, bool) {
+	request, err := caldav.ParseRequest(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return request, false
+	}
+	return request, true
+}
+
+// caldavError writes the response of an error of the database.
+func caldavError(w http.ResponseWriter, err error) {
+	log.Printf("Error type: %T, Details: %s", err, err.Error())
+	http.Error(w, "Internal server error.", http.StatusInternalServerError)
+}
+
+func xmlDAV(local string) xml.Name {
+	return xml.Name{Space: caldav.NamespaceDAV, Local: local}
+}
+
+func xmlCalDAV(local string) xml.Name {
+	return xml.Name{Space: caldav.NamespaceCalDAV, Local: local}
+}
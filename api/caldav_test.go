@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSyncToken(t *testing.T) {
+	token := syncToken{
+		seq:   42,
+		start: time.Date(2024, time.September, 2, 0, 0, 0, 0, time.Local),
+		end:   time.Date(2024, time.November, 4, 0, 0, 0, 0, time.Local),
+		scope: "0123456789abcdef",
+	}
+	if token.String() != "data:,42-20240902-20241104-0123456789abcdef" {
+		t.Errorf("String() = %q", token.String())
+	}
+	parsed, ok := parseSyncToken(token.String())
+	if !ok || parsed.seq != token.seq || !parsed.start.Equal(token.start) || !parsed.end.Equal(token.end) || parsed.scope != token.scope {
+		t.Errorf("parseSyncToken(%q) = %+v, %t, want %+v", token.String(), parsed, ok, token)
+	}
+
+	tests := []string{
+		"",
+		"42-20240902-20241104-0123456789abcdef",
+		"data:,",
+		"data:,42-20240902-20241104",
+		"data:,42-20240902-20241104-0123-4567",
+		"data:,x-20240902-20241104-0123456789abcdef",
+		"data:,42-2024-09-02-20241104-0123456789abcdef",
+		"data:,42-20240902-20241304-0123456789abcdef",
+		// A token of the earlier format that hashed the lesson ids
+		"data:,3f2a9c0d1e5b7a64",
+	}
+	for _, value := range tests {
+		if _, ok := parseSyncToken(value); ok {
+			t.Errorf("parseSyncToken(%q) accepted a malformed token", value)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// Get the lessons of a calendar feed as iCalendar file, the token may end with .ics
+// (GET /calendar/{token})
+func (server Server) GetCalendarToken(w http.ResponseWriter, r *http.Request, token string) {
+	if !config.Config.Calendar.Enabled {
+		http.Error(w, "Calendar feeds are disabled.", http.StatusNotFound)
+		return
+	}
+	token = strings.TrimSuffix(token, ".ics")
+	ctx := r.Context()
+	feed, err := server.DB.GetCalendarFeedByToken(token, ctx)
+	if err != nil {
+		if errors.Is(err, dbModels.ErrCalendarFeedNotFound) {
+			http.Error(w, "Calendar not found.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	calendar, err := server.DB.GetCalendar(feed, ctx)
+	if err != nil {
+		if errors.Is(err, dbModels.ErrChoiceNotFound) {
+			http.Error(w, "The choice of the calendar does not exist anymore.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+		}
+		return
+	}
+	body := calendar.Encode()
+	hash := sha256.Sum256(body)
+	etag := "\"" + hex.EncodeToString(hash[:16]) + "\""
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", "inline; filename=\"timetable.ics\"")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// Get the calendar feeds of a user
+// (GET /users/{userId}/calendars)
+func (server Server) GetUsersUserIdCalendars(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CalendarsManage, userId) {
+		return
+	}
+	feeds, err := server.DB.GetCalendarFeeds(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(feeds)
+}
+
+// Create a calendar feed for a user
+// (POST /users/{userId}/calendars)
+func (server Server) PostUsersUserIdCalendars(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CalendarsManage, userId) {
+		return
+	}
+	if !config.Config.Calendar.Enabled {
+		http.Error(w, "Calendar feeds are disabled.", http.StatusNotFound)
+		return
+	}
+	var body gen.PostUsersUserIdCalendarsJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	feed := dbModels.CalendarFeed{UserId: userId}
+	if body.Name != nil {
+		feed.Name = *body.Name
+	}
+	if body.IncludeMenus != nil {
+		feed.IncludeMenus = *body.IncludeMenus
+	}
+	if body.IncludeWeeks != nil {
+		feed.IncludeWeeks = *body.IncludeWeeks
+	}
+	if body.ChoiceId != nil {
+		feed.ChoiceId = *body.ChoiceId
+	}
+	newFeed, err := server.DB.CreateCalendarFeed(feed, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrUserNotFound) {
+			http.Error(w, "User not found.", http.StatusNotFound)
+		} else if errors.Is(err, dbModels.ErrChoiceNotFound) {
+			http.Error(w, "Choice not found.", http.StatusNotFound)
+		} else if errors.Is(err, dbModels.ErrCalendarFeedLimitReached) {
+			http.Error(w, "The maximum number of calendar feeds is reached. Revoke a feed first.", http.StatusConflict)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+		}
+		return
+	}
+	server.audit(r, "calendar.create", "user", userId, map[string]interface{}{"calendarId": newFeed.Feed.Id, "choiceId": feed.ChoiceId})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(newFeed)
+}
+
+// Revoke a calendar feed
+// (DELETE /users/{userId}/calendars/{calendarId})
+func (server Server) DeleteUsersUserIdCalendarsCalendarId(w http.ResponseWriter, r *http.Request, userId int, calendarId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CalendarsManage, userId) {
+		return
+	}
+	err := server.DB.DeleteCalendarFeed(userId, calendarId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrCalendarFeedNotFound) {
+			http.Error(w, "Calendar not found.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	server.audit(r, "calendar.revoke", "user", userId, map[string]interface{}{"calendarId": calendarId})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// Get the changes of the lessons of the active user since a time
+// (GET /changes)
+func (server Server) GetChanges(w http.ResponseWriter, r *http.Request, params gen.GetChangesParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	filter := dbModels.LessonFilter{
+		User:          (&dbModels.User{}).FromGen(user),
+		ClassFallback: true,
+	}
+	if params.ChoiceId != nil {
+		filter.Choice.Id = *params.ChoiceId
+	}
+	limit := 200
+	if params.Limit != nil && *params.Limit > 0 && *params.Limit <= 1000 {
+		limit = *params.Limit
+	}
+	var after int64
+	if params.After != nil {
+		after = *params.After
+	}
+	changes, err := server.DB.GetLessonChanges(filter, params.Since, after, limit, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrChoiceNotFound) {
+			http.Error(w, "Choice not found.", http.StatusNotFound)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(changes)
+}
@@ -7,23 +7,22 @@ import (
 	"net/http"
 
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
 )
 
 // Get choices by userId
 // (GET /users/{userId}/choices)
 func (server Server) GetUsersUserIdChoices(w http.ResponseWriter, r *http.Request, userId int) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
+	user := principal.User
 	if userId == -1 {
 		userId = *user.Id
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		if userId != *user.Id {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
+	if !authorizeFor(w, user, authz.ChoicesRead, userId) {
+		return
 	}
 	choices, err := server.DB.GetChoicesByUserId(userId, r.Context())
 	if err != nil {
@@ -36,18 +35,16 @@ func (server Server) GetUsersUserIdChoices(w http.ResponseWriter, r *http.Reques
 // Get a choice by userId and choiceId
 // (GET /users/{userId}/choices/{choiceId})
 func (server Server) GetUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *http.Request, userId int, choiceId int) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
+	user := principal.User
 	if userId == -1 {
 		userId = *user.Id
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		if userId != *user.Id {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
+	if !authorizeFor(w, user, authz.ChoicesRead, userId) {
+		return
 	}
 	choice, err := server.DB.GetChoiceByUserIdAndChoiceId(userId, choiceId, r.Context())
 	if errors.Is(err, sql.ErrNoRows) {
@@ -65,22 +62,20 @@ func (server Server) GetUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *htt
 // Modify or create a choice by userId and choiceId
 // (POST /users/{userId}/choices/{choiceId})
 func (server Server) PostUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *http.Request, userId int, choiceId int) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
+	user := principal.User
 	if userId == -1 {
 		userId = *user.Id
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		if userId != *user.Id {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
+	if !authorizeFor(w, user, authz.ChoicesWrite, userId) {
+		return
 	}
 
 	var choice gen.Choice
-	err = json.NewDecoder(r.Body).Decode(&choice)
+	err := json.NewDecoder(r.Body).Decode(&choice)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
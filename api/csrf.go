@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+)
+
+const (
+	csrfCookieName = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// setCSRFCookie sets the csrf_token cookie in hardened cookie mode and returns its value.
+// An existing token of the browser is kept, so requests running during a token refresh stay valid.
+func setCSRFCookie(w http.ResponseWriter, r *http.Request) string {
+	if !config.Config.Cookies.Hardened {
+		return ""
+	}
+	var token string
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) >= 32 {
+		token = cookie.Value
+	} else {
+		data := make([]byte, 32)
+		if _, err := rand.Read(data); err != nil {
+			panic(err)
+		}
+		token = base64.RawURLEncoding.EncodeToString(data)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		Domain:   config.Config.Cookies.Domain,
+		HttpOnly: false, // The frontend reads it and sends it back in the X-CSRF-Token header
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// usesSessionCookie reports if the request is authenticated by a cookie the browser adds on its own.
+// Requests with an Authorization header can not be forged by another site.
+func usesSessionCookie(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for _, name := range []string{"session_token", "refresh_token"} {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedOrigin reports if origin is one of the allowed origins or the api itself.
+func trustedOrigin(r *http.Request, origin string) bool {
+	if slices.Contains(config.Config.AllowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// CSRFMiddleware rejects state changing requests authenticated by cookie that another site could have sent.
+// The Origin (or Referer) has to be trusted if the browser sends one. In hardened cookie mode the
+// X-CSRF-Token header also has to match the csrf_token cookie (double submit).
+func CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !usesSessionCookie(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if !trustedOrigin(r, origin) {
+				http.Error(w, "Cross site request rejected.", http.StatusForbidden)
+				return
+			}
+		} else if referer, err := url.Parse(r.Referer()); err == nil && referer.Host != "" {
+			if !trustedOrigin(r, referer.Scheme+"://"+referer.Host) {
+				http.Error(w, "Cross site request rejected.", http.StatusForbidden)
+				return
+			}
+		}
+		if config.Config.Cookies.Hardened {
+			cookie, err := r.Cookie(csrfCookieName)
+			header := r.Header.Get(csrfHeaderName)
+			if err != nil || header == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+				http.Error(w, "Missing or invalid CSRF token.", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	origins, hardened := config.Config.AllowedOrigins, config.Config.Cookies.Hardened
+	t.Cleanup(func() {
+		config.Config.AllowedOrigins, config.Config.Cookies.Hardened = origins, hardened
+	})
+	config.Config.AllowedOrigins = []string{"https://timetable.example"}
+
+	const token = "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name     string
+		hardened bool
+		method   string
+		cookies  []string
+		header   map[string]string
+		want     int
+	}{
+		{"safe method", true, http.MethodGet, []string{"session_token"}, nil, http.StatusOK},
+		{"no cookie", true, http.MethodPost, nil, map[string]string{"Origin": "https://evil.example"}, http.StatusOK},
+		{"bearer token", true, http.MethodPost, []string{"session_token"}, map[string]string{"Authorization": "Bearer x", "Origin": "https://evil.example"}, http.StatusOK},
+		{"allowed origin", false, http.MethodPost, []string{"session_token"}, map[string]string{"Origin": "https://timetable.example"}, http.StatusOK},
+		{"same host origin", false, http.MethodPut, []string{"session_token"}, map[string]string{"Origin": "https://api.example"}, http.StatusOK},
+		{"foreign origin", false, http.MethodPost, []string{"session_token"}, map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"foreign referer", false, http.MethodDelete, []string{"refresh_token"}, map[string]string{"Referer": "https://evil.example/page"}, http.StatusForbidden},
+		{"allowed referer", false, http.MethodPost, []string{"session_token"}, map[string]string{"Referer": "https://timetable.example/login"}, http.StatusOK},
+		{"no origin", false, http.MethodPost, []string{"session_token"}, nil, http.StatusOK},
+		{"hardened matching token", true, http.MethodPost, []string{"session_token", csrfCookieName}, map[string]string{"Origin": "https://timetable.example", csrfHeaderName: token}, http.StatusOK},
+		{"hardened missing header", true, http.MethodPost, []string{"session_token", csrfCookieName}, map[string]string{"Origin": "https://timetable.example"}, http.StatusForbidden},
+		{"hardened missing cookie", true, http.MethodPost, []string{"session_token"}, map[string]string{csrfHeaderName: token}, http.StatusForbidden},
+		{"hardened wrong token", true, http.MethodPost, []string{"session_token", csrfCookieName}, map[string]string{csrfHeaderName: token + "x"}, http.StatusForbidden},
+	}
+	handler := CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, test := range tests {
+		config.Config.Cookies.Hardened = test.hardened
+		r := httptest.NewRequest(test.method, "https://api.example/users/-1", nil)
+		for _, name := range test.cookies {
+			r.AddCookie(&http.Cookie{Name: name, Value: token})
+		}
+		for name, value := range test.header {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.want)
+		}
+	}
+}
@@ -15,6 +15,12 @@ const (
 	BearerAuthScopes = "BearerAuth.Scopes"
 )
 
+// Defines values for LessonChangeKind.
+const (
+	LessonChangeKindCreated LessonChangeKind = "created"
+	LessonChangeKindUpdated LessonChangeKind = "updated"
+)
+
 // Defines values for LessonLessonType.
 const (
 	Bs LessonLessonType = "bs"
@@ -24,11 +30,44 @@ const (
 	Sb LessonLessonType = "sb"
 )
 
+// Defines values for SyncRunStatus.
+const (
+	SyncRunStatusFailed    SyncRunStatus = "failed"
+	SyncRunStatusSkipped   SyncRunStatus = "skipped"
+	SyncRunStatusSucceeded SyncRunStatus = "succeeded"
+	SyncRunStatusTimeout   SyncRunStatus = "timeout"
+)
+
+// Defines values for TokenScope.
+const (
+	TokenScopeAdmin        TokenScope = "admin"
+	TokenScopeChoicesRead  TokenScope = "choices:read"
+	TokenScopeChoicesWrite TokenScope = "choices:write"
+	TokenScopeUntisRead    TokenScope = "untis:read"
+	TokenScopeUserRead     TokenScope = "user:read"
+	TokenScopeViewRead     TokenScope = "view:read"
+)
+
 // Defines values for UserRole.
 const (
-	UserRoleAdmin   UserRole = "admin"
-	UserRoleStudent UserRole = "student"
-	UserRoleTeacher UserRole = "teacher"
+	UserRoleAdmin    UserRole = "admin"
+	UserRoleGuardian UserRole = "guardian"
+	UserRoleStudent  UserRole = "student"
+	UserRoleTeacher  UserRole = "teacher"
+)
+
+// Defines values for WebhookDeliveryStatus.
+const (
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "dead"
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+)
+
+// Defines values for WebhookEvent.
+const (
+	WebhookEventLessonChanged       WebhookEvent = "lesson.changed"
+	WebhookEventMenuUpdated         WebhookEvent = "menu.updated"
+	WebhookEventWeekSubtitleCreated WebhookEvent = "week.subtitle.created"
 )
 
 // Defines values for PutViewJSONBodyProvider.
@@ -45,6 +84,61 @@ const (
 	PutViewUserUserIdJSONBodyProviderWeek      PutViewUserUserIdJSONBodyProvider = "week"
 )
 
+// AppPassword A password for CalDAV clients, it can only read the timetable.
+type AppPassword struct {
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	Id         int        `json:"id"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIp *string    `json:"lastUsedIp,omitempty"`
+	Name       *string    `json:"name,omitempty"`
+
+	// Prefix The first characters of the password to recognize it.
+	Prefix string `json:"prefix"`
+}
+
+// AuditEntry An administrative or security relevant action. hash covers the entry and prevHash, so changed or removed entries break the chain.
+type AuditEntry struct {
+	// Action E.g. "user.delete" or "untis.credentials.update".
+	Action    string    `json:"action"`
+	ActorId   *int      `json:"actorId,omitempty"`
+	ActorName *string   `json:"actorName,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Diff Changed fields as {"field": {"old": ..., "new": ...}}. Secrets are never included.
+	Diff       *map[string]interface{} `json:"diff,omitempty"`
+	Hash       string                  `json:"hash"`
+	Id         int64                   `json:"id"`
+	Ip         *string                 `json:"ip,omitempty"`
+	PrevHash   string                  `json:"prevHash"`
+	TargetId   *string                 `json:"targetId,omitempty"`
+	TargetType *string                 `json:"targetType,omitempty"`
+}
+
+// AuditVerification Result of checking the hash chain of the audit log.
+type AuditVerification struct {
+	// Checked Number of entries checked.
+	Checked int `json:"checked"`
+
+	// FirstInvalidId First entry that does not match its hash or the previous entry. Missing if the chain is intact.
+	FirstInvalidId *int64 `json:"firstInvalidId,omitempty"`
+	Valid          bool   `json:"valid"`
+}
+
+// CalendarFeed A secret link to the lessons of a user as iCalendar file.
+type CalendarFeed struct {
+	// ChoiceId The choice whose lessons are shown, unset for the default choice of the user.
+	ChoiceId     *int       `json:"choiceId,omitempty"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	Id           int        `json:"id"`
+	IncludeMenus bool       `json:"includeMenus"`
+	IncludeWeeks bool       `json:"includeWeeks"`
+	LastUsedAt   *time.Time `json:"lastUsedAt,omitempty"`
+	Name         *string    `json:"name,omitempty"`
+
+	// Prefix The first characters of the token to recognize the feed.
+	Prefix string `json:"prefix"`
+}
+
 // Choice Choice of subjects for the classes. {class:[subjects]}
 // - If a class has a empty array as a choice all subjects should be shown.
 // - If the Class ID is negative it the the choice is a blacklist.
@@ -66,6 +160,47 @@ type Class struct {
 	SecondaryTeacherId     *int    `json:"secondaryTeacherId,omitempty"`
 }
 
+// GuardianInvite A new guardian invite. The code is only returned once, the guardian sends it to POST /guardians/accept.
+type GuardianInvite struct {
+	Code      string       `json:"code"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	Link      GuardianLink `json:"link"`
+}
+
+// GuardianLink Read access of a guardian to the timetable of a student.
+type GuardianLink struct {
+	AcceptedAt   *time.Time `json:"acceptedAt,omitempty"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	GuardianId   *int       `json:"guardianId,omitempty"`
+	GuardianName *string    `json:"guardianName,omitempty"`
+	Id           *int       `json:"id,omitempty"`
+
+	// InviteExpiresAt Only set while the invite is pending.
+	InviteExpiresAt *time.Time `json:"inviteExpiresAt,omitempty"`
+
+	// Pending The invite was not accepted yet.
+	Pending     *bool   `json:"pending,omitempty"`
+	StudentId   *int    `json:"studentId,omitempty"`
+	StudentName *string `json:"studentName,omitempty"`
+}
+
+// Jwk A public key used to verify tokens, as JSON Web Key (RFC 7517).
+type Jwk struct {
+	Alg *string `json:"alg,omitempty"`
+	Crv *string `json:"crv,omitempty"`
+	E   *string `json:"e,omitempty"`
+	Kid string  `json:"kid"`
+	Kty string  `json:"kty"`
+	N   *string `json:"n,omitempty"`
+	Use *string `json:"use,omitempty"`
+	X   *string `json:"x,omitempty"`
+}
+
+// JwkSet defines model for JwkSet.
+type JwkSet struct {
+	Keys []Jwk `json:"keys"`
+}
+
 // Lesson defines model for Lesson.
 type Lesson struct {
 	AdditionalInformation *string    `json:"additionalInformation,omitempty"`
@@ -96,6 +231,48 @@ type Lesson struct {
 // LessonLessonType //„ls“ (lesson) | „oh“ (office hour) | „sb“ (standby) | „bs“ (break supervision) | „ex“(examination)  omitted if lesson
 type LessonLessonType string
 
+// LessonChange A change of a lesson found while fetching from Untis.
+type LessonChange struct {
+	ChangedAt time.Time `json:"changedAt"`
+
+	// Diff Changed fields as {"field": {"old": ..., "new": ...}}. old is null for created lessons.
+	Diff map[string]interface{} `json:"diff"`
+	Id   int64                  `json:"id"`
+
+	// Kind "created" for a new lesson that is cancelled or irregular, "updated" for a changed lesson.
+	Kind     LessonChangeKind `json:"kind"`
+	Lesson   *Lesson          `json:"lesson,omitempty"`
+	LessonId int              `json:"lessonId"`
+}
+
+// LessonChangeKind "created" for a new lesson that is cancelled or irregular, "updated" for a changed lesson.
+type LessonChangeKind string
+
+// LessonChanges A page of lesson changes.
+type LessonChanges struct {
+	Changes []LessonChange `json:"changes"`
+
+	// Cursor Id of the last change, pass it as after to get the next page. The after of the request if there are no changes.
+	Cursor *int64 `json:"cursor,omitempty"`
+}
+
+// Lockout Failed attempts of an account or ip address.
+type Lockout struct {
+	BlockedUntil *time.Time `json:"blockedUntil,omitempty"`
+	Failures     *int       `json:"failures,omitempty"`
+	Id           *int       `json:"id,omitempty"`
+
+	// Key "user:<name or id>" or "ip:<address>"
+	Key         *string    `json:"key,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+
+	// Locked The limit of failed attempts was reached and the key is locked until blockedUntil.
+	Locked *bool `json:"locked,omitempty"`
+
+	// Scope "login", "password", "untis", "totp", "guardian" or "passwordReset"
+	Scope *string `json:"scope,omitempty"`
+}
+
 // Menu defines model for Menu.
 type Menu struct {
 	Cookteam    *string            `json:"cookteam,omitempty"`
@@ -106,6 +283,108 @@ type Menu struct {
 	MainDishVeg *string            `json:"mainDishVeg,omitempty"`
 }
 
+// NewAppPassword defines model for NewAppPassword.
+type NewAppPassword struct {
+	// AppPassword A password for CalDAV clients, it can only read the timetable.
+	AppPassword AppPassword `json:"appPassword"`
+
+	// Password The password for the CalDAV client. It is only shown once.
+	Password string `json:"password"`
+
+	// Url The CalDAV server to enter in the client.
+	Url *string `json:"url,omitempty"`
+
+	// Username The user name to enter in the CalDAV client.
+	Username string `json:"username"`
+}
+
+// NewCalendarFeed defines model for NewCalendarFeed.
+type NewCalendarFeed struct {
+	// Feed A secret link to the lessons of a user as iCalendar file.
+	Feed CalendarFeed `json:"feed"`
+
+	// Token The secret of the feed url. It is only shown once.
+	Token string `json:"token"`
+
+	// Url The url to subscribe to in a calendar app.
+	Url *string `json:"url,omitempty"`
+}
+
+// NewPersonalAccessToken defines model for NewPersonalAccessToken.
+type NewPersonalAccessToken struct {
+	AccessToken PersonalAccessToken `json:"accessToken"`
+
+	// Token The token. It is only shown once.
+	Token string `json:"token"`
+}
+
+// NewWebhook defines model for NewWebhook.
+type NewWebhook struct {
+	// Secret Key of the HMAC-SHA256 signature in the X-TMF-Signature header. It is only shown once.
+	Secret  string  `json:"secret"`
+	Webhook Webhook `json:"webhook"`
+}
+
+// PersonalAccessToken defines model for PersonalAccessToken.
+type PersonalAccessToken struct {
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// ExpiresAt Tokens without expiry stay valid until they are revoked.
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	Id         *int       `json:"id,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIp *string    `json:"lastUsedIp,omitempty"`
+	Name       *string    `json:"name,omitempty"`
+
+	// Prefix The first characters of the token to recognize it.
+	Prefix *string       `json:"prefix,omitempty"`
+	Scopes *[]TokenScope `json:"scopes,omitempty"`
+}
+
+// PolicyViolation defines model for PolicyViolation.
+type PolicyViolation struct {
+	// Field "password" or "username".
+	Field   string `json:"field"`
+	Message string `json:"message"`
+
+	// Rule The violated rule, e.g. "minLength", "common" or "containsUsername".
+	Rule string `json:"rule"`
+}
+
+// PolicyViolations defines model for PolicyViolations.
+type PolicyViolations struct {
+	Message    string            `json:"message"`
+	Violations []PolicyViolation `json:"violations"`
+}
+
+// PushPreferences Which lesson changes a user is notified about. Notifications during the quiet hours are dropped.
+type PushPreferences struct {
+	Cancelled bool `json:"cancelled"`
+
+	// QuietHoursEnd End of the quiet hours as "HH:MM" in the time zone of the school.
+	QuietHoursEnd *string `json:"quietHoursEnd,omitempty"`
+
+	// QuietHoursStart Start of the quiet hours as "HH:MM" in the time zone of the school. The quiet hours may span midnight.
+	// Notifications during the quiet hours are sent when they end.
+	QuietHoursStart *string `json:"quietHoursStart,omitempty"`
+	Rooms           bool    `json:"rooms"`
+	Substitutions   bool    `json:"substitutions"`
+	Teachers        bool    `json:"teachers"`
+}
+
+// PushSubscription A browser subscribed to the push notifications of a user.
+type PushSubscription struct {
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Endpoint  string     `json:"endpoint"`
+	Id        int        `json:"id"`
+	UserAgent *string    `json:"userAgent,omitempty"`
+}
+
+// RecoveryCodes One time codes to log in without the TOTP device. They are only shown once.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 // Room defines model for Room.
 type Room struct {
 	AdditionalInformation *string `json:"additionalInformation,omitempty"`
@@ -113,6 +392,19 @@ type Room struct {
 	Name                  *string `json:"name,omitempty"`
 }
 
+// Session defines model for Session.
+type Session struct {
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// Current true if this is the session the request was made with
+	Current   *bool      `json:"current,omitempty"`
+	Device    *string    `json:"device,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Id        *string    `json:"id,omitempty"`
+	Ip        *string    `json:"ip,omitempty"`
+	LastSeen  *time.Time `json:"lastSeen,omitempty"`
+}
+
 // Subject defines model for Subject.
 type Subject struct {
 	Id        *int    `json:"id,omitempty"`
@@ -120,6 +412,24 @@ type Subject struct {
 	ShortName *string `json:"shortName,omitempty"`
 }
 
+// SyncRun A run of a scheduled Untis sync job.
+type SyncRun struct {
+	// Error Error of a failed run.
+	Error      *string    `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Id         int64      `json:"id"`
+
+	// Job "teachers", "rooms", "subjects", "classes" or "timetables".
+	Job       string    `json:"job"`
+	StartedAt time.Time `json:"startedAt"`
+
+	// Status "skipped" if the previous run of the job was still running.
+	Status SyncRunStatus `json:"status"`
+}
+
+// SyncRunStatus "skipped" if the previous run of the job was still running.
+type SyncRunStatus string
+
 // Teacher defines model for Teacher.
 type Teacher struct {
 	FirstName *string `json:"firstName,omitempty"`
@@ -131,6 +441,39 @@ type Teacher struct {
 	UserId    *int    `json:"userId,omitempty"`
 }
 
+// TokenScope defines model for TokenScope.
+type TokenScope string
+
+// TotpEnrollment defines model for TotpEnrollment.
+type TotpEnrollment struct {
+	// ProvisioningUri otpauth:// uri to show as QR code.
+	ProvisioningUri string `json:"provisioningUri"`
+
+	// Secret The base32 encoded secret for manual entry.
+	Secret string `json:"secret"`
+}
+
+// TotpStatus defines model for TotpStatus.
+type TotpStatus struct {
+	Enabled bool `json:"enabled"`
+
+	// RecoveryCodesLeft Number of unused recovery codes.
+	RecoveryCodesLeft *int `json:"recoveryCodesLeft,omitempty"`
+
+	// Required The role of the user requires a second factor.
+	Required bool `json:"required"`
+}
+
+// UntisAccStatus defines model for UntisAccStatus.
+type UntisAccStatus struct {
+	// Linked An Untis account is connected to the user.
+	Linked *bool `json:"linked,omitempty"`
+
+	// ReconnectRequired The stored Untis credentials were removed by a password reset and have to be entered again.
+	ReconnectRequired *bool   `json:"reconnectRequired,omitempty"`
+	UserName          *string `json:"userName,omitempty"`
+}
+
 // User defines model for User.
 type User struct {
 	Classes *[]int `json:"classes,omitempty"`
@@ -146,6 +489,16 @@ type User struct {
 	Role          *UserRole `json:"role,omitempty"`
 }
 
+// UserIdentity An account at an external identity provider linked to a user.
+type UserIdentity struct {
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Email     *string    `json:"email,omitempty"`
+	Id        *int       `json:"id,omitempty"`
+	Issuer    *string    `json:"issuer,omitempty"`
+	LastLogin *time.Time `json:"lastLogin,omitempty"`
+	Subject   *string    `json:"subject,omitempty"`
+}
+
 // UserRole defines model for User.Role.
 type UserRole string
 
@@ -162,15 +515,107 @@ type UserSettings struct {
 	AdditionalProperties map[string]interface{} `json:"-"`
 }
 
+// VapidKey The public key of the server for PushManager.subscribe (applicationServerKey).
+type VapidKey struct {
+	// PublicKey Base64url encoded uncompressed P-256 public key.
+	PublicKey string `json:"publicKey"`
+}
+
+// Webhook An url that receives the subscribed events as signed POST requests.
+type Webhook struct {
+	// Active Inactive webhooks receive no events.
+	Active      bool           `json:"active"`
+	CreatedAt   *time.Time     `json:"createdAt,omitempty"`
+	Description *string        `json:"description,omitempty"`
+	Events      []WebhookEvent `json:"events"`
+	Id          int            `json:"id"`
+	Url         string         `json:"url"`
+
+	// UserId The owner. Webhooks of admins receive the changes of all lessons, the others those of the choice of the owner.
+	UserId int `json:"userId"`
+}
+
+// WebhookDelivery An event queued for a webhook.
+type WebhookDelivery struct {
+	Attempts    int          `json:"attempts"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	DeliveredAt *time.Time   `json:"deliveredAt,omitempty"`
+	EventId     int64        `json:"eventId"`
+	EventType   WebhookEvent `json:"eventType"`
+	Id          int64        `json:"id"`
+	LastError   *string      `json:"lastError,omitempty"`
+
+	// LastStatusCode Http status of the last attempt, unset if the request failed.
+	LastStatusCode *int       `json:"lastStatusCode,omitempty"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+
+	// Status "pending" until delivered, "dead" after the last failed attempt.
+	Status    WebhookDeliveryStatus `json:"status"`
+	WebhookId int                   `json:"webhookId"`
+}
+
+// WebhookDeliveryStatus "pending" until delivered, "dead" after the last failed attempt.
+type WebhookDeliveryStatus string
+
+// WebhookEvent "lesson.changed", "menu.updated" or "week.subtitle.created".
+type WebhookEvent string
+
 // Week Week subtitle for the Week the date(startDate) is in.
 type Week = string
 
+// GetAuditParams defines parameters for GetAudit.
+type GetAuditParams struct {
+	// ActorId Only actions of this user.
+	ActorId *int `form:"actorId,omitempty" json:"actorId,omitempty"`
+
+	// Action Only this action. A trailing "*" matches every action with the prefix, e.g. "user.*".
+	Action     *string `form:"action,omitempty" json:"action,omitempty"`
+	TargetType *string `form:"targetType,omitempty" json:"targetType,omitempty"`
+	TargetId   *string `form:"targetId,omitempty" json:"targetId,omitempty"`
+
+	// From Only entries created at or after this time.
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To Only entries created before this time.
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+
+	// Limit Maximum number of entries, newest first. Defaults to 100.
+	Limit  *int `form:"limit,omitempty" json:"limit,omitempty"`
+	Offset *int `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
 // GetCafeteriaParams defines parameters for GetCafeteria.
 type GetCafeteriaParams struct {
 	Date     *openapi_types.Date `form:"date,omitempty" json:"date,omitempty"`
 	Duration *int                `form:"duration,omitempty" json:"duration,omitempty"`
 }
 
+// GetChangesParams defines parameters for GetChanges.
+type GetChangesParams struct {
+	// Since Only changes after this time.
+	Since time.Time `form:"since" json:"since"`
+
+	// ChoiceId Filter by this choice instead of the default choice of the user.
+	ChoiceId *int `form:"choiceId,omitempty" json:"choiceId,omitempty"`
+
+	// Limit Maximum number of changes, oldest first. Defaults to 200.
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// After Only changes after the change with this id, the cursor of the previous page.
+	After *int64 `form:"after,omitempty" json:"after,omitempty"`
+}
+
+// PostGuardiansAcceptJSONBody defines parameters for PostGuardiansAccept.
+type PostGuardiansAcceptJSONBody struct {
+	Code string `json:"code"`
+}
+
+// GetLockoutsParams defines parameters for GetLockouts.
+type GetLockoutsParams struct {
+	// All Include entries that are not blocked at the moment.
+	All *bool `form:"all,omitempty" json:"all,omitempty"`
+}
+
 // PostLoginJSONBody defines parameters for PostLogin.
 type PostLoginJSONBody struct {
 	// Password yourpassword hashed with SHA256
@@ -178,6 +623,97 @@ type PostLoginJSONBody struct {
 	Username *string `json:"username,omitempty"`
 }
 
+// GetOidcCallbackParams defines parameters for GetOidcCallback.
+type GetOidcCallbackParams struct {
+	Code  *string `form:"code,omitempty" json:"code,omitempty"`
+	Error *string `form:"error,omitempty" json:"error,omitempty"`
+	State string  `form:"state" json:"state"`
+}
+
+// GetOidcLoginParams defines parameters for GetOidcLogin.
+type GetOidcLoginParams struct {
+	// Redirect Path of the frontend the browser is sent to after the login.
+	Redirect *string `form:"redirect,omitempty" json:"redirect,omitempty"`
+}
+
+// PostOidcLinkParams defines parameters for PostOidcLink.
+type PostOidcLinkParams struct {
+	// Redirect Path of the frontend the browser is sent to after the link.
+	Redirect *string `form:"redirect,omitempty" json:"redirect,omitempty"`
+}
+
+// PostLoginTotpJSONBody defines parameters for PostLoginTotp.
+type PostLoginTotpJSONBody struct {
+	// Code A TOTP code or a recovery code.
+	Code         string `json:"code"`
+	PartialToken string `json:"partialToken"`
+}
+
+// PostLoginTotpEnrollJSONBody defines parameters for PostLoginTotpEnroll.
+type PostLoginTotpEnrollJSONBody struct {
+	PartialToken string `json:"partialToken"`
+}
+
+// PostPasswordResetJSONBody defines parameters for PostPasswordReset.
+type PostPasswordResetJSONBody struct {
+	Username *string `json:"username,omitempty"`
+}
+
+// PostPasswordResetConfirmJSONBody defines parameters for PostPasswordResetConfirm.
+type PostPasswordResetConfirmJSONBody struct {
+	NewPassword *string `json:"newPassword,omitempty"`
+
+	// Token The token from the password reset mail.
+	Token *string `json:"token,omitempty"`
+}
+
+// GetStreamParams defines parameters for GetStream.
+type GetStreamParams struct {
+	// LastEventId Resume after this event, like the Last-Event-ID header. The header takes precedence.
+	LastEventId *int64 `form:"lastEventId,omitempty" json:"lastEventId,omitempty"`
+}
+
+// PostTokenRefreshJSONBody defines parameters for PostTokenRefresh.
+type PostTokenRefreshJSONBody struct {
+	// RefreshToken The refresh token. Can be omitted if the refresh_token cookie is set.
+	RefreshToken *string `json:"refreshToken,omitempty"`
+}
+
+// GetUntisSyncParams defines parameters for GetUntisSync.
+type GetUntisSyncParams struct {
+	// Job Only runs of this job.
+	Job *string `form:"job,omitempty" json:"job,omitempty"`
+
+	// Status Only runs with this status.
+	Status *SyncRunStatus `form:"status,omitempty" json:"status,omitempty"`
+
+	// Limit Maximum number of runs, newest first. Defaults to 50.
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// PutUserPasswordJSONBody defines parameters for PutUserPassword.
+type PutUserPasswordJSONBody struct {
+	NewPassword *string `json:"newPassword,omitempty"`
+	OldPassword *string `json:"oldPassword,omitempty"`
+}
+
+// DeleteUserTotpJSONBody defines parameters for DeleteUserTotp.
+type DeleteUserTotpJSONBody struct {
+	// Code A TOTP code or a recovery code.
+	Code string `json:"code"`
+}
+
+// PostUserTotpConfirmJSONBody defines parameters for PostUserTotpConfirm.
+type PostUserTotpConfirmJSONBody struct {
+	Code string `json:"code"`
+}
+
+// PostUserTotpRecoveryCodesJSONBody defines parameters for PostUserTotpRecoveryCodes.
+type PostUserTotpRecoveryCodesJSONBody struct {
+	// Code A TOTP code or a recovery code.
+	Code string `json:"code"`
+}
+
 // PutUserUntisAccJSONBody defines parameters for PutUserUntisAcc.
 type PutUserUntisAccJSONBody struct {
 	Forename *string `json:"forename,omitempty"`
@@ -192,6 +728,74 @@ type PostUsersJSONBody struct {
 	UserData *User   `json:"userData,omitempty"`
 }
 
+// PostUsersUserIdAppPasswordsJSONBody defines parameters for PostUsersUserIdAppPasswords.
+type PostUsersUserIdAppPasswordsJSONBody struct {
+	// Name Where the password is used, e.g. the name of the device.
+	Name *string `json:"name,omitempty"`
+}
+
+// PostUsersUserIdCalendarsJSONBody defines parameters for PostUsersUserIdCalendars.
+type PostUsersUserIdCalendarsJSONBody struct {
+	// ChoiceId The choice whose lessons are shown. Unset for the default choice of the user.
+	ChoiceId *int `json:"choiceId,omitempty"`
+
+	// IncludeMenus Add the cafeteria menus as all day events.
+	IncludeMenus *bool `json:"includeMenus,omitempty"`
+
+	// IncludeWeeks Add the week subtitles as all day events.
+	IncludeWeeks *bool   `json:"includeWeeks,omitempty"`
+	Name         *string `json:"name,omitempty"`
+}
+
+// PostUsersUserIdPushSubscriptionsJSONBody defines parameters for PostUsersUserIdPushSubscriptions.
+type PostUsersUserIdPushSubscriptionsJSONBody struct {
+	// Endpoint The endpoint of the PushSubscription of the browser.
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		Auth   string `json:"auth"`
+		P256dh string `json:"p256dh"`
+	} `json:"keys"`
+}
+
+// DeleteUsersUserIdSessionsParams defines parameters for DeleteUsersUserIdSessions.
+type DeleteUsersUserIdSessionsParams struct {
+	// KeepCurrent Do not revoke the session the request was made with.
+	KeepCurrent *bool `form:"keepCurrent,omitempty" json:"keepCurrent,omitempty"`
+}
+
+// PostUsersUserIdTokensJSONBody defines parameters for PostUsersUserIdTokens.
+type PostUsersUserIdTokensJSONBody struct {
+	ExpiresAt *time.Time   `json:"expiresAt,omitempty"`
+	Name      string       `json:"name"`
+	Scopes    []TokenScope `json:"scopes"`
+}
+
+// PostUsersUserIdWebhooksJSONBody defines parameters for PostUsersUserIdWebhooks.
+type PostUsersUserIdWebhooksJSONBody struct {
+	Description *string        `json:"description,omitempty"`
+	Events      []WebhookEvent `json:"events"`
+
+	// Url The https url the events are posted to.
+	Url string `json:"url"`
+}
+
+// PutUsersUserIdWebhooksWebhookIdJSONBody defines parameters for PutUsersUserIdWebhooksWebhookId.
+type PutUsersUserIdWebhooksWebhookIdJSONBody struct {
+	Active      *bool           `json:"active,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Events      *[]WebhookEvent `json:"events,omitempty"`
+	Url         *string         `json:"url,omitempty"`
+}
+
+// GetUsersUserIdWebhooksWebhookIdDeliveriesParams defines parameters for GetUsersUserIdWebhooksWebhookIdDeliveries.
+type GetUsersUserIdWebhooksWebhookIdDeliveriesParams struct {
+	// Status Only deliveries with this status, "dead" for the dead letters.
+	Status *WebhookDeliveryStatus `form:"status,omitempty" json:"status,omitempty"`
+
+	// Limit Maximum number of deliveries, newest first. Defaults to 100.
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
 // PutViewJSONBody defines parameters for PutView.
 type PutViewJSONBody struct {
 	Provider []PutViewJSONBodyProvider `json:"provider"`
@@ -234,9 +838,39 @@ type PutViewUserUserIdParams struct {
 // PutViewUserUserIdJSONBodyProvider defines parameters for PutViewUserUserId.
 type PutViewUserUserIdJSONBodyProvider string
 
+// PostGuardiansAcceptJSONRequestBody defines body for PostGuardiansAccept for application/json ContentType.
+type PostGuardiansAcceptJSONRequestBody PostGuardiansAcceptJSONBody
+
 // PostLoginJSONRequestBody defines body for PostLogin for application/json ContentType.
 type PostLoginJSONRequestBody PostLoginJSONBody
 
+// PostLoginTotpJSONRequestBody defines body for PostLoginTotp for application/json ContentType.
+type PostLoginTotpJSONRequestBody PostLoginTotpJSONBody
+
+// PostLoginTotpEnrollJSONRequestBody defines body for PostLoginTotpEnroll for application/json ContentType.
+type PostLoginTotpEnrollJSONRequestBody PostLoginTotpEnrollJSONBody
+
+// PostPasswordResetJSONRequestBody defines body for PostPasswordReset for application/json ContentType.
+type PostPasswordResetJSONRequestBody PostPasswordResetJSONBody
+
+// PostPasswordResetConfirmJSONRequestBody defines body for PostPasswordResetConfirm for application/json ContentType.
+type PostPasswordResetConfirmJSONRequestBody PostPasswordResetConfirmJSONBody
+
+// PostTokenRefreshJSONRequestBody defines body for PostTokenRefresh for application/json ContentType.
+type PostTokenRefreshJSONRequestBody PostTokenRefreshJSONBody
+
+// PutUserPasswordJSONRequestBody defines body for PutUserPassword for application/json ContentType.
+type PutUserPasswordJSONRequestBody PutUserPasswordJSONBody
+
+// DeleteUserTotpJSONRequestBody defines body for DeleteUserTotp for application/json ContentType.
+type DeleteUserTotpJSONRequestBody DeleteUserTotpJSONBody
+
+// PostUserTotpConfirmJSONRequestBody defines body for PostUserTotpConfirm for application/json ContentType.
+type PostUserTotpConfirmJSONRequestBody PostUserTotpConfirmJSONBody
+
+// PostUserTotpRecoveryCodesJSONRequestBody defines body for PostUserTotpRecoveryCodes for application/json ContentType.
+type PostUserTotpRecoveryCodesJSONRequestBody PostUserTotpRecoveryCodesJSONBody
+
 // PutUserUntisAccJSONRequestBody defines body for PutUserUntisAcc for application/json ContentType.
 type PutUserUntisAccJSONRequestBody PutUserUntisAccJSONBody
 
@@ -246,9 +880,30 @@ type PostUsersJSONRequestBody PostUsersJSONBody
 // PutUsersUserIdJSONRequestBody defines body for PutUsersUserId for application/json ContentType.
 type PutUsersUserIdJSONRequestBody = UserSettings
 
+// PostUsersUserIdAppPasswordsJSONRequestBody defines body for PostUsersUserIdAppPasswords for application/json ContentType.
+type PostUsersUserIdAppPasswordsJSONRequestBody PostUsersUserIdAppPasswordsJSONBody
+
+// PostUsersUserIdCalendarsJSONRequestBody defines body for PostUsersUserIdCalendars for application/json ContentType.
+type PostUsersUserIdCalendarsJSONRequestBody PostUsersUserIdCalendarsJSONBody
+
 // PostUsersUserIdChoicesChoiceIdJSONRequestBody defines body for PostUsersUserIdChoicesChoiceId for application/json ContentType.
 type PostUsersUserIdChoicesChoiceIdJSONRequestBody = Choice
 
+// PutUsersUserIdPushPreferencesJSONRequestBody defines body for PutUsersUserIdPushPreferences for application/json ContentType.
+type PutUsersUserIdPushPreferencesJSONRequestBody = PushPreferences
+
+// PostUsersUserIdPushSubscriptionsJSONRequestBody defines body for PostUsersUserIdPushSubscriptions for application/json ContentType.
+type PostUsersUserIdPushSubscriptionsJSONRequestBody PostUsersUserIdPushSubscriptionsJSONBody
+
+// PostUsersUserIdTokensJSONRequestBody defines body for PostUsersUserIdTokens for application/json ContentType.
+type PostUsersUserIdTokensJSONRequestBody PostUsersUserIdTokensJSONBody
+
+// PostUsersUserIdWebhooksJSONRequestBody defines body for PostUsersUserIdWebhooks for application/json ContentType.
+type PostUsersUserIdWebhooksJSONRequestBody PostUsersUserIdWebhooksJSONBody
+
+// PutUsersUserIdWebhooksWebhookIdJSONRequestBody defines body for PutUsersUserIdWebhooksWebhookId for application/json ContentType.
+type PutUsersUserIdWebhooksWebhookIdJSONRequestBody PutUsersUserIdWebhooksWebhookIdJSONBody
+
 // PutViewJSONRequestBody defines body for PutView for application/json ContentType.
 type PutViewJSONRequestBody PutViewJSONBody
 
@@ -16,18 +16,72 @@ import (
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// Public keys to verify issued tokens
+	// (GET /.well-known/jwks.json)
+	GetWellKnownJwksJson(w http.ResponseWriter, r *http.Request)
+	// Get the audit log (admin)
+	// (GET /audit)
+	GetAudit(w http.ResponseWriter, r *http.Request, params GetAuditParams)
+	// Check the hash chain of the audit log (admin)
+	// (GET /audit/verify)
+	GetAuditVerify(w http.ResponseWriter, r *http.Request)
 	// Get Menu in a defined time frame.
 	// (GET /cafeteria)
 	GetCafeteria(w http.ResponseWriter, r *http.Request, params GetCafeteriaParams)
+	// Get the lessons of a calendar feed as iCalendar file, the token may end with .ics
+	// (GET /calendar/{token})
+	GetCalendarToken(w http.ResponseWriter, r *http.Request, token string)
+	// Get the changes of the lessons of the active user since a time
+	// (GET /changes)
+	GetChanges(w http.ResponseWriter, r *http.Request, params GetChangesParams)
+	// Stream lesson changes of the choice of the user, menu updates and week subtitles as Server-Sent Events
+	// (GET /stream)
+	GetStream(w http.ResponseWriter, r *http.Request, params GetStreamParams)
 	// Returns currently logged in user.
 	// (GET /currentUser)
 	GetCurrentUser(w http.ResponseWriter, r *http.Request)
+	// Accept a guardian invite with the current user as guardian
+	// (POST /guardians/accept)
+	PostGuardiansAccept(w http.ResponseWriter, r *http.Request)
+	// Get accounts and ip addresses with failed attempts (admin)
+	// (GET /lockouts)
+	GetLockouts(w http.ResponseWriter, r *http.Request, params GetLockoutsParams)
+	// Clear the failed attempts of an account or ip address (admin)
+	// (DELETE /lockouts/{lockoutId})
+	DeleteLockoutsLockoutId(w http.ResponseWriter, r *http.Request, lockoutId int)
 	// Login and get a token
 	// (POST /login)
 	PostLogin(w http.ResponseWriter, r *http.Request)
+	// Finish a login with a TOTP or recovery code
+	// (POST /login/totp)
+	PostLoginTotp(w http.ResponseWriter, r *http.Request)
+	// Enroll TOTP during a login that requires a second factor
+	// (POST /login/totp/enroll)
+	PostLoginTotpEnroll(w http.ResponseWriter, r *http.Request)
 	// Logout and invalidate token
 	// (POST /logout)
 	PostLogout(w http.ResponseWriter, r *http.Request)
+	// Callback of the identity provider
+	// (GET /oidc/callback)
+	GetOidcCallback(w http.ResponseWriter, r *http.Request, params GetOidcCallbackParams)
+	// Start linking an account at the identity provider to the active user
+	// (POST /oidc/link)
+	PostOidcLink(w http.ResponseWriter, r *http.Request, params PostOidcLinkParams)
+	// Login through the identity provider of the school
+	// (GET /oidc/login)
+	GetOidcLogin(w http.ResponseWriter, r *http.Request, params GetOidcLoginParams)
+	// Request a password reset link for a user
+	// (POST /passwordReset)
+	PostPasswordReset(w http.ResponseWriter, r *http.Request)
+	// Set a new password with a password reset token
+	// (POST /passwordReset/confirm)
+	PostPasswordResetConfirm(w http.ResponseWriter, r *http.Request)
+	// Get the VAPID public key to subscribe to push notifications
+	// (GET /push/vapidKey)
+	GetPushVapidKey(w http.ResponseWriter, r *http.Request)
+	// Exchange a refresh token for a new access and refresh token
+	// (POST /token/refresh)
+	PostTokenRefresh(w http.ResponseWriter, r *http.Request)
 	// Get all classes
 	// (GET /untis/classes)
 	GetUntisClasses(w http.ResponseWriter, r *http.Request)
@@ -40,9 +94,33 @@ type ServerInterface interface {
 	// Get all subjects
 	// (GET /untis/subjects)
 	GetUntisSubjects(w http.ResponseWriter, r *http.Request)
+	// Get the recent runs of the scheduled Untis sync (admin)
+	// (GET /untis/sync)
+	GetUntisSync(w http.ResponseWriter, r *http.Request, params GetUntisSyncParams)
 	// Get all teachers
 	// (GET /untis/teachers)
 	GetUntisTeachers(w http.ResponseWriter, r *http.Request)
+	// Change the password of the active user
+	// (PUT /user/password)
+	PutUserPassword(w http.ResponseWriter, r *http.Request)
+	// Disable TOTP for the active user
+	// (DELETE /user/totp)
+	DeleteUserTotp(w http.ResponseWriter, r *http.Request)
+	// Get the TOTP status of the active user
+	// (GET /user/totp)
+	GetUserTotp(w http.ResponseWriter, r *http.Request)
+	// Start the TOTP enrollment of the active user
+	// (POST /user/totp)
+	PostUserTotp(w http.ResponseWriter, r *http.Request)
+	// Confirm the TOTP enrollment with a code
+	// (POST /user/totp/confirm)
+	PostUserTotpConfirm(w http.ResponseWriter, r *http.Request)
+	// Replace the recovery codes of the active user
+	// (POST /user/totp/recoveryCodes)
+	PostUserTotpRecoveryCodes(w http.ResponseWriter, r *http.Request)
+	// Get the untisAcc status of the active user
+	// (GET /user/untisAcc)
+	GetUserUntisAcc(w http.ResponseWriter, r *http.Request)
 	// Update the untisAcc of the active user
 	// (PUT /user/untisAcc)
 	PutUserUntisAcc(w http.ResponseWriter, r *http.Request)
@@ -61,6 +139,24 @@ type ServerInterface interface {
 	// Update a user by ID
 	// (PUT /users/{userId})
 	PutUsersUserId(w http.ResponseWriter, r *http.Request, userId int)
+	// Get the CalDAV app passwords of a user
+	// (GET /users/{userId}/appPasswords)
+	GetUsersUserIdAppPasswords(w http.ResponseWriter, r *http.Request, userId int)
+	// Create an app password for CalDAV clients
+	// (POST /users/{userId}/appPasswords)
+	PostUsersUserIdAppPasswords(w http.ResponseWriter, r *http.Request, userId int)
+	// Revoke an app password
+	// (DELETE /users/{userId}/appPasswords/{appPasswordId})
+	DeleteUsersUserIdAppPasswordsAppPasswordId(w http.ResponseWriter, r *http.Request, userId int, appPasswordId int)
+	// Get the calendar feeds of a user
+	// (GET /users/{userId}/calendars)
+	GetUsersUserIdCalendars(w http.ResponseWriter, r *http.Request, userId int)
+	// Create a calendar feed for a user
+	// (POST /users/{userId}/calendars)
+	PostUsersUserIdCalendars(w http.ResponseWriter, r *http.Request, userId int)
+	// Revoke a calendar feed
+	// (DELETE /users/{userId}/calendars/{calendarId})
+	DeleteUsersUserIdCalendarsCalendarId(w http.ResponseWriter, r *http.Request, userId int, calendarId int)
 	// Get choices by userId
 	// (GET /users/{userId}/choices)
 	GetUsersUserIdChoices(w http.ResponseWriter, r *http.Request, userId int)
@@ -70,6 +166,87 @@ type ServerInterface interface {
 	// Modify or create a choice by userId and choiceId
 	// (POST /users/{userId}/choices/{choiceId})
 	PostUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *http.Request, userId int, choiceId int)
+	// Get the guardians and open guardian invites of a student
+	// (GET /users/{userId}/guardians)
+	GetUsersUserIdGuardians(w http.ResponseWriter, r *http.Request, userId int)
+	// Invite a guardian to read the timetable of a student
+	// (POST /users/{userId}/guardians)
+	PostUsersUserIdGuardians(w http.ResponseWriter, r *http.Request, userId int)
+	// Revoke a guardian or an open invite
+	// (DELETE /users/{userId}/guardians/{linkId})
+	DeleteUsersUserIdGuardiansLinkId(w http.ResponseWriter, r *http.Request, userId int, linkId int)
+	// Get the linked identities of a user
+	// (GET /users/{userId}/identities)
+	GetUsersUserIdIdentities(w http.ResponseWriter, r *http.Request, userId int)
+	// Unlink an identity from a user
+	// (DELETE /users/{userId}/identities/{identityId})
+	DeleteUsersUserIdIdentitiesIdentityId(w http.ResponseWriter, r *http.Request, userId int, identityId int)
+	// Send a password reset link to a user (admin)
+	// (POST /users/{userId}/passwordReset)
+	PostUsersUserIdPasswordReset(w http.ResponseWriter, r *http.Request, userId int)
+	// Get the push notification preferences of a user
+	// (GET /users/{userId}/push/preferences)
+	GetUsersUserIdPushPreferences(w http.ResponseWriter, r *http.Request, userId int)
+	// Change the push notification preferences of a user
+	// (PUT /users/{userId}/push/preferences)
+	PutUsersUserIdPushPreferences(w http.ResponseWriter, r *http.Request, userId int)
+	// Get the push subscriptions of a user
+	// (GET /users/{userId}/push/subscriptions)
+	GetUsersUserIdPushSubscriptions(w http.ResponseWriter, r *http.Request, userId int)
+	// Subscribe a browser to the push notifications of a user
+	// (POST /users/{userId}/push/subscriptions)
+	PostUsersUserIdPushSubscriptions(w http.ResponseWriter, r *http.Request, userId int)
+	// Unsubscribe a browser from push notifications
+	// (DELETE /users/{userId}/push/subscriptions/{subscriptionId})
+	DeleteUsersUserIdPushSubscriptionsSubscriptionId(w http.ResponseWriter, r *http.Request, userId int, subscriptionId int)
+	// Revoke all sessions of a user
+	// (DELETE /users/{userId}/sessions)
+	DeleteUsersUserIdSessions(w http.ResponseWriter, r *http.Request, userId int, params DeleteUsersUserIdSessionsParams)
+	// Get the active sessions of a user
+	// (GET /users/{userId}/sessions)
+	GetUsersUserIdSessions(w http.ResponseWriter, r *http.Request, userId int)
+	// Revoke a session of a user
+	// (DELETE /users/{userId}/sessions/{sessionId})
+	DeleteUsersUserIdSessionsSessionId(w http.ResponseWriter, r *http.Request, userId int, sessionId string)
+	// Get the personal access tokens of a user
+	// (GET /users/{userId}/tokens)
+	GetUsersUserIdTokens(w http.ResponseWriter, r *http.Request, userId int)
+	// Create a personal access token
+	// (POST /users/{userId}/tokens)
+	PostUsersUserIdTokens(w http.ResponseWriter, r *http.Request, userId int)
+	// Revoke a personal access token
+	// (DELETE /users/{userId}/tokens/{tokenId})
+	DeleteUsersUserIdTokensTokenId(w http.ResponseWriter, r *http.Request, userId int, tokenId int)
+	// Get the webhooks of a user
+	// (GET /users/{userId}/webhooks)
+	GetUsersUserIdWebhooks(w http.ResponseWriter, r *http.Request, userId int)
+	// Register a webhook for a user
+	// (POST /users/{userId}/webhooks)
+	PostUsersUserIdWebhooks(w http.ResponseWriter, r *http.Request, userId int)
+	// Delete a webhook and its queued deliveries
+	// (DELETE /users/{userId}/webhooks/{webhookId})
+	DeleteUsersUserIdWebhooksWebhookId(w http.ResponseWriter, r *http.Request, userId int, webhookId int)
+	// Change a webhook
+	// (PUT /users/{userId}/webhooks/{webhookId})
+	PutUsersUserIdWebhooksWebhookId(w http.ResponseWriter, r *http.Request, userId int, webhookId int)
+	// Get the deliveries of a webhook, status=dead for the dead letters
+	// (GET /users/{userId}/webhooks/{webhookId}/deliveries)
+	GetUsersUserIdWebhooksWebhookIdDeliveries(w http.ResponseWriter, r *http.Request, userId int, webhookId int, params GetUsersUserIdWebhooksWebhookIdDeliveriesParams)
+	// Queue a dead or failed delivery again
+	// (POST /users/{userId}/webhooks/{webhookId}/deliveries/{deliveryId}/retry)
+	PostUsersUserIdWebhooksWebhookIdDeliveriesDeliveryIdRetry(w http.ResponseWriter, r *http.Request, userId int, webhookId int, deliveryId int64)
+	// Replace the signing secret of a webhook
+	// (POST /users/{userId}/webhooks/{webhookId}/secret)
+	PostUsersUserIdWebhooksWebhookIdSecret(w http.ResponseWriter, r *http.Request, userId int, webhookId int)
+	// Reset the TOTP of a user (admin)
+	// (DELETE /users/{userId}/totp)
+	DeleteUsersUserIdTotp(w http.ResponseWriter, r *http.Request, userId int)
+	// Get the students a guardian can read the timetable of
+	// (GET /users/{userId}/wards)
+	GetUsersUserIdWards(w http.ResponseWriter, r *http.Request, userId int)
+	// Give up the read access to the timetable of a student
+	// (DELETE /users/{userId}/wards/{linkId})
+	DeleteUsersUserIdWardsLinkId(w http.ResponseWriter, r *http.Request, userId int, linkId int)
 	// Get events by a user
 	// (PUT /view)
 	PutView(w http.ResponseWriter, r *http.Request, params PutViewParams)
@@ -90,6 +267,129 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(http.Handler) http.Handler
 
+// GetWellKnownJwksJson operation middleware
+func (siw *ServerInterfaceWrapper) GetWellKnownJwksJson(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetWellKnownJwksJson(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetAudit operation middleware
+func (siw *ServerInterfaceWrapper) GetAudit(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetAuditParams
+
+	// ------------- Optional query parameter "actorId" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "actorId", r.URL.Query(), &params.ActorId)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "actorId", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "action" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "action", r.URL.Query(), &params.Action)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "action", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "targetType" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "targetType", r.URL.Query(), &params.TargetType)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "targetType", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "targetId" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "targetId", r.URL.Query(), &params.TargetId)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "targetId", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", r.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "from", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", r.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "to", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "offset" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "offset", r.URL.Query(), &params.Offset)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "offset", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetAudit(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetAuditVerify operation middleware
+func (siw *ServerInterfaceWrapper) GetAuditVerify(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetAuditVerify(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // GetCafeteria operation middleware
 func (siw *ServerInterfaceWrapper) GetCafeteria(w http.ResponseWriter, r *http.Request) {
 
@@ -102,20 +402,1587 @@ func (siw *ServerInterfaceWrapper) GetCafeteria(w http.ResponseWriter, r *http.R
 
 	err = runtime.BindQueryParameter("form", true, false, "date", r.URL.Query(), &params.Date)
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "date", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "date", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "duration" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "duration", r.URL.Query(), &params.Duration)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "duration", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetCafeteria(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetCalendarToken operation middleware
+func (siw *ServerInterfaceWrapper) GetCalendarToken(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "token" -------------
+	var token string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "token", r.PathValue("token"), &token, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "token", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetCalendarToken(w, r, token)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetChanges operation middleware
+func (siw *ServerInterfaceWrapper) GetChanges(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetChangesParams
+
+	// ------------- Required query parameter "since" -------------
+
+	if paramValue := r.URL.Query().Get("since"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "since"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "since", r.URL.Query(), &params.Since)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "since", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "choiceId" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "choiceId", r.URL.Query(), &params.ChoiceId)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "choiceId", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "after" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "after", r.URL.Query(), &params.After)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "after", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetChanges(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetStream operation middleware
+func (siw *ServerInterfaceWrapper) GetStream(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetStreamParams
+
+	// ------------- Optional query parameter "lastEventId" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "lastEventId", r.URL.Query(), &params.LastEventId)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "lastEventId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetStream(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetCurrentUser operation middleware
+func (siw *ServerInterfaceWrapper) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetCurrentUser(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostGuardiansAccept operation middleware
+func (siw *ServerInterfaceWrapper) PostGuardiansAccept(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostGuardiansAccept(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetLockouts operation middleware
+func (siw *ServerInterfaceWrapper) GetLockouts(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetLockoutsParams
+
+	// ------------- Optional query parameter "all" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "all", r.URL.Query(), &params.All)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "all", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetLockouts(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteLockoutsLockoutId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteLockoutsLockoutId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "lockoutId" -------------
+	var lockoutId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "lockoutId", r.PathValue("lockoutId"), &lockoutId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "lockoutId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteLockoutsLockoutId(w, r, lockoutId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostLogin operation middleware
+func (siw *ServerInterfaceWrapper) PostLogin(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostLogin(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostLoginTotp operation middleware
+func (siw *ServerInterfaceWrapper) PostLoginTotp(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostLoginTotp(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostLoginTotpEnroll operation middleware
+func (siw *ServerInterfaceWrapper) PostLoginTotpEnroll(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostLoginTotpEnroll(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostLogout operation middleware
+func (siw *ServerInterfaceWrapper) PostLogout(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostLogout(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetOidcCallback operation middleware
+func (siw *ServerInterfaceWrapper) GetOidcCallback(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetOidcCallbackParams
+
+	// ------------- Optional query parameter "code" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "code", r.URL.Query(), &params.Code)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "code", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "error" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "error", r.URL.Query(), &params.Error)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "error", Err: err})
+		return
+	}
+
+	// ------------- Required query parameter "state" -------------
+
+	if paramValue := r.URL.Query().Get("state"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "state"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "state", r.URL.Query(), &params.State)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "state", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetOidcCallback(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostOidcLink operation middleware
+func (siw *ServerInterfaceWrapper) PostOidcLink(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PostOidcLinkParams
+
+	// ------------- Optional query parameter "redirect" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "redirect", r.URL.Query(), &params.Redirect)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "redirect", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostOidcLink(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetOidcLogin operation middleware
+func (siw *ServerInterfaceWrapper) GetOidcLogin(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetOidcLoginParams
+
+	// ------------- Optional query parameter "redirect" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "redirect", r.URL.Query(), &params.Redirect)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "redirect", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetOidcLogin(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostPasswordReset operation middleware
+func (siw *ServerInterfaceWrapper) PostPasswordReset(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostPasswordReset(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostPasswordResetConfirm operation middleware
+func (siw *ServerInterfaceWrapper) PostPasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostPasswordResetConfirm(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetPushVapidKey operation middleware
+func (siw *ServerInterfaceWrapper) GetPushVapidKey(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetPushVapidKey(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostTokenRefresh operation middleware
+func (siw *ServerInterfaceWrapper) PostTokenRefresh(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostTokenRefresh(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUntisClasses operation middleware
+func (siw *ServerInterfaceWrapper) GetUntisClasses(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUntisClasses(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUntisFetch operation middleware
+func (siw *ServerInterfaceWrapper) GetUntisFetch(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUntisFetch(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUntisRooms operation middleware
+func (siw *ServerInterfaceWrapper) GetUntisRooms(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUntisRooms(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUntisSubjects operation middleware
+func (siw *ServerInterfaceWrapper) GetUntisSubjects(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUntisSubjects(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUntisSync operation middleware
+func (siw *ServerInterfaceWrapper) GetUntisSync(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetUntisSyncParams
+
+	// ------------- Optional query parameter "job" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "job", r.URL.Query(), &params.Job)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "job", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", r.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "status", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUntisSync(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUntisTeachers operation middleware
+func (siw *ServerInterfaceWrapper) GetUntisTeachers(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUntisTeachers(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PutUserPassword operation middleware
+func (siw *ServerInterfaceWrapper) PutUserPassword(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PutUserPassword(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteUserTotp operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUserTotp(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteUserTotp(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUserTotp operation middleware
+func (siw *ServerInterfaceWrapper) GetUserTotp(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUserTotp(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUserTotp operation middleware
+func (siw *ServerInterfaceWrapper) PostUserTotp(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUserTotp(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUserTotpConfirm operation middleware
+func (siw *ServerInterfaceWrapper) PostUserTotpConfirm(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUserTotpConfirm(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUserTotpRecoveryCodes operation middleware
+func (siw *ServerInterfaceWrapper) PostUserTotpRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUserTotpRecoveryCodes(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUserUntisAcc operation middleware
+func (siw *ServerInterfaceWrapper) GetUserUntisAcc(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUserUntisAcc(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PutUserUntisAcc operation middleware
+func (siw *ServerInterfaceWrapper) PutUserUntisAcc(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PutUserUntisAcc(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsers operation middleware
+func (siw *ServerInterfaceWrapper) GetUsers(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsers(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUsers operation middleware
+func (siw *ServerInterfaceWrapper) PostUsers(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUsers(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteUsersUserId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteUsersUserId(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserId operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserId(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PutUsersUserId operation middleware
+func (siw *ServerInterfaceWrapper) PutUsersUserId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PutUsersUserId(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdAppPasswords operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdAppPasswords(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdAppPasswords(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUsersUserIdAppPasswords operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdAppPasswords(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUsersUserIdAppPasswords(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteUsersUserIdAppPasswordsAppPasswordId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdAppPasswordsAppPasswordId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "appPasswordId" -------------
+	var appPasswordId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "appPasswordId", r.PathValue("appPasswordId"), &appPasswordId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "appPasswordId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteUsersUserIdAppPasswordsAppPasswordId(w, r, userId, appPasswordId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdCalendars operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdCalendars(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdCalendars(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUsersUserIdCalendars operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdCalendars(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUsersUserIdCalendars(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteUsersUserIdCalendarsCalendarId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdCalendarsCalendarId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "calendarId" -------------
+	var calendarId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "calendarId", r.PathValue("calendarId"), &calendarId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "calendarId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteUsersUserIdCalendarsCalendarId(w, r, userId, calendarId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdChoices operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdChoices(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdChoices(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdChoicesChoiceId operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "choiceId" -------------
+	var choiceId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "choiceId", r.PathValue("choiceId"), &choiceId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "choiceId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdChoicesChoiceId(w, r, userId, choiceId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUsersUserIdChoicesChoiceId operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "choiceId" -------------
+	var choiceId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "choiceId", r.PathValue("choiceId"), &choiceId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "choiceId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUsersUserIdChoicesChoiceId(w, r, userId, choiceId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdGuardians operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdGuardians(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdGuardians(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUsersUserIdGuardians operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdGuardians(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUsersUserIdGuardians(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteUsersUserIdGuardiansLinkId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdGuardiansLinkId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "linkId" -------------
+	var linkId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "linkId", r.PathValue("linkId"), &linkId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "linkId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteUsersUserIdGuardiansLinkId(w, r, userId, linkId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdIdentities operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdIdentities(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdIdentities(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteUsersUserIdIdentitiesIdentityId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdIdentitiesIdentityId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "identityId" -------------
+	var identityId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "identityId", r.PathValue("identityId"), &identityId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "identityId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteUsersUserIdIdentitiesIdentityId(w, r, userId, identityId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUsersUserIdPasswordReset operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdPasswordReset(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUsersUserIdPasswordReset(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdPushPreferences operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdPushPreferences(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdPushPreferences(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PutUsersUserIdPushPreferences operation middleware
+func (siw *ServerInterfaceWrapper) PutUsersUserIdPushPreferences(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PutUsersUserIdPushPreferences(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetUsersUserIdPushSubscriptions operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdPushSubscriptions(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetUsersUserIdPushSubscriptions(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostUsersUserIdPushSubscriptions operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdPushSubscriptions(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostUsersUserIdPushSubscriptions(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteUsersUserIdPushSubscriptionsSubscriptionId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdPushSubscriptionsSubscriptionId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
 		return
 	}
 
-	// ------------- Optional query parameter "duration" -------------
+	// ------------- Path parameter "subscriptionId" -------------
+	var subscriptionId int
 
-	err = runtime.BindQueryParameter("form", true, false, "duration", r.URL.Query(), &params.Duration)
+	err = runtime.BindStyledParameterWithOptions("simple", "subscriptionId", r.PathValue("subscriptionId"), &subscriptionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "duration", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "subscriptionId", Err: err})
 		return
 	}
 
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetCafeteria(w, r, params)
+		siw.Handler.DeleteUsersUserIdPushSubscriptionsSubscriptionId(w, r, userId, subscriptionId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -125,8 +1992,19 @@ func (siw *ServerInterfaceWrapper) GetCafeteria(w http.ResponseWriter, r *http.R
 	handler.ServeHTTP(w, r)
 }
 
-// GetCurrentUser operation middleware
-func (siw *ServerInterfaceWrapper) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+// DeleteUsersUserIdSessions operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdSessions(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -134,22 +2012,19 @@ func (siw *ServerInterfaceWrapper) GetCurrentUser(w http.ResponseWriter, r *http
 
 	r = r.WithContext(ctx)
 
-	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetCurrentUser(w, r)
-	}))
-
-	for _, middleware := range siw.HandlerMiddlewares {
-		handler = middleware(handler)
-	}
+	// Parameter object where we will unmarshal all parameters from the context
+	var params DeleteUsersUserIdSessionsParams
 
-	handler.ServeHTTP(w, r)
-}
+	// ------------- Optional query parameter "keepCurrent" -------------
 
-// PostLogin operation middleware
-func (siw *ServerInterfaceWrapper) PostLogin(w http.ResponseWriter, r *http.Request) {
+	err = runtime.BindQueryParameter("form", true, false, "keepCurrent", r.URL.Query(), &params.KeepCurrent)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "keepCurrent", Err: err})
+		return
+	}
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostLogin(w, r)
+		siw.Handler.DeleteUsersUserIdSessions(w, r, userId, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -159,8 +2034,19 @@ func (siw *ServerInterfaceWrapper) PostLogin(w http.ResponseWriter, r *http.Requ
 	handler.ServeHTTP(w, r)
 }
 
-// PostLogout operation middleware
-func (siw *ServerInterfaceWrapper) PostLogout(w http.ResponseWriter, r *http.Request) {
+// GetUsersUserIdSessions operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdSessions(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -169,7 +2055,7 @@ func (siw *ServerInterfaceWrapper) PostLogout(w http.ResponseWriter, r *http.Req
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostLogout(w, r)
+		siw.Handler.GetUsersUserIdSessions(w, r, userId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -179,8 +2065,28 @@ func (siw *ServerInterfaceWrapper) PostLogout(w http.ResponseWriter, r *http.Req
 	handler.ServeHTTP(w, r)
 }
 
-// GetUntisClasses operation middleware
-func (siw *ServerInterfaceWrapper) GetUntisClasses(w http.ResponseWriter, r *http.Request) {
+// DeleteUsersUserIdSessionsSessionId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdSessionsSessionId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "sessionId" -------------
+	var sessionId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "sessionId", r.PathValue("sessionId"), &sessionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "sessionId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -189,7 +2095,7 @@ func (siw *ServerInterfaceWrapper) GetUntisClasses(w http.ResponseWriter, r *htt
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUntisClasses(w, r)
+		siw.Handler.DeleteUsersUserIdSessionsSessionId(w, r, userId, sessionId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -199,8 +2105,19 @@ func (siw *ServerInterfaceWrapper) GetUntisClasses(w http.ResponseWriter, r *htt
 	handler.ServeHTTP(w, r)
 }
 
-// GetUntisFetch operation middleware
-func (siw *ServerInterfaceWrapper) GetUntisFetch(w http.ResponseWriter, r *http.Request) {
+// GetUsersUserIdTokens operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdTokens(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -209,7 +2126,7 @@ func (siw *ServerInterfaceWrapper) GetUntisFetch(w http.ResponseWriter, r *http.
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUntisFetch(w, r)
+		siw.Handler.GetUsersUserIdTokens(w, r, userId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -219,8 +2136,19 @@ func (siw *ServerInterfaceWrapper) GetUntisFetch(w http.ResponseWriter, r *http.
 	handler.ServeHTTP(w, r)
 }
 
-// GetUntisRooms operation middleware
-func (siw *ServerInterfaceWrapper) GetUntisRooms(w http.ResponseWriter, r *http.Request) {
+// PostUsersUserIdTokens operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdTokens(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -229,7 +2157,7 @@ func (siw *ServerInterfaceWrapper) GetUntisRooms(w http.ResponseWriter, r *http.
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUntisRooms(w, r)
+		siw.Handler.PostUsersUserIdTokens(w, r, userId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -239,8 +2167,28 @@ func (siw *ServerInterfaceWrapper) GetUntisRooms(w http.ResponseWriter, r *http.
 	handler.ServeHTTP(w, r)
 }
 
-// GetUntisSubjects operation middleware
-func (siw *ServerInterfaceWrapper) GetUntisSubjects(w http.ResponseWriter, r *http.Request) {
+// DeleteUsersUserIdTokensTokenId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdTokensTokenId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "tokenId" -------------
+	var tokenId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "tokenId", r.PathValue("tokenId"), &tokenId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "tokenId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -249,7 +2197,7 @@ func (siw *ServerInterfaceWrapper) GetUntisSubjects(w http.ResponseWriter, r *ht
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUntisSubjects(w, r)
+		siw.Handler.DeleteUsersUserIdTokensTokenId(w, r, userId, tokenId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -259,8 +2207,19 @@ func (siw *ServerInterfaceWrapper) GetUntisSubjects(w http.ResponseWriter, r *ht
 	handler.ServeHTTP(w, r)
 }
 
-// GetUntisTeachers operation middleware
-func (siw *ServerInterfaceWrapper) GetUntisTeachers(w http.ResponseWriter, r *http.Request) {
+// GetUsersUserIdWebhooks operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -269,7 +2228,7 @@ func (siw *ServerInterfaceWrapper) GetUntisTeachers(w http.ResponseWriter, r *ht
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUntisTeachers(w, r)
+		siw.Handler.GetUsersUserIdWebhooks(w, r, userId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -279,8 +2238,19 @@ func (siw *ServerInterfaceWrapper) GetUntisTeachers(w http.ResponseWriter, r *ht
 	handler.ServeHTTP(w, r)
 }
 
-// PutUserUntisAcc operation middleware
-func (siw *ServerInterfaceWrapper) PutUserUntisAcc(w http.ResponseWriter, r *http.Request) {
+// PostUsersUserIdWebhooks operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -289,7 +2259,7 @@ func (siw *ServerInterfaceWrapper) PutUserUntisAcc(w http.ResponseWriter, r *htt
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PutUserUntisAcc(w, r)
+		siw.Handler.PostUsersUserIdWebhooks(w, r, userId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -299,8 +2269,28 @@ func (siw *ServerInterfaceWrapper) PutUserUntisAcc(w http.ResponseWriter, r *htt
 	handler.ServeHTTP(w, r)
 }
 
-// GetUsers operation middleware
-func (siw *ServerInterfaceWrapper) GetUsers(w http.ResponseWriter, r *http.Request) {
+// DeleteUsersUserIdWebhooksWebhookId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdWebhooksWebhookId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "webhookId" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhookId", r.PathValue("webhookId"), &webhookId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhookId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -309,7 +2299,7 @@ func (siw *ServerInterfaceWrapper) GetUsers(w http.ResponseWriter, r *http.Reque
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUsers(w, r)
+		siw.Handler.DeleteUsersUserIdWebhooksWebhookId(w, r, userId, webhookId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -319,8 +2309,28 @@ func (siw *ServerInterfaceWrapper) GetUsers(w http.ResponseWriter, r *http.Reque
 	handler.ServeHTTP(w, r)
 }
 
-// PostUsers operation middleware
-func (siw *ServerInterfaceWrapper) PostUsers(w http.ResponseWriter, r *http.Request) {
+// PutUsersUserIdWebhooksWebhookId operation middleware
+func (siw *ServerInterfaceWrapper) PutUsersUserIdWebhooksWebhookId(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", r.PathValue("userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "webhookId" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhookId", r.PathValue("webhookId"), &webhookId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhookId", Err: err})
+		return
+	}
 
 	ctx := r.Context()
 
@@ -329,7 +2339,7 @@ func (siw *ServerInterfaceWrapper) PostUsers(w http.ResponseWriter, r *http.Requ
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostUsers(w, r)
+		siw.Handler.PutUsersUserIdWebhooksWebhookId(w, r, userId, webhookId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -339,8 +2349,8 @@ func (siw *ServerInterfaceWrapper) PostUsers(w http.ResponseWriter, r *http.Requ
 	handler.ServeHTTP(w, r)
 }
 
-// DeleteUsersUserId operation middleware
-func (siw *ServerInterfaceWrapper) DeleteUsersUserId(w http.ResponseWriter, r *http.Request) {
+// GetUsersUserIdWebhooksWebhookIdDeliveries operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdWebhooksWebhookIdDeliveries(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -353,14 +2363,42 @@ func (siw *ServerInterfaceWrapper) DeleteUsersUserId(w http.ResponseWriter, r *h
 		return
 	}
 
+	// ------------- Path parameter "webhookId" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhookId", r.PathValue("webhookId"), &webhookId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhookId", Err: err})
+		return
+	}
+
 	ctx := r.Context()
 
 	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
 
 	r = r.WithContext(ctx)
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetUsersUserIdWebhooksWebhookIdDeliveriesParams
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", r.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "status", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.DeleteUsersUserId(w, r, userId)
+		siw.Handler.GetUsersUserIdWebhooksWebhookIdDeliveries(w, r, userId, webhookId, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -370,8 +2408,8 @@ func (siw *ServerInterfaceWrapper) DeleteUsersUserId(w http.ResponseWriter, r *h
 	handler.ServeHTTP(w, r)
 }
 
-// GetUsersUserId operation middleware
-func (siw *ServerInterfaceWrapper) GetUsersUserId(w http.ResponseWriter, r *http.Request) {
+// PostUsersUserIdWebhooksWebhookIdDeliveriesDeliveryIdRetry operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdWebhooksWebhookIdDeliveriesDeliveryIdRetry(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -384,6 +2422,24 @@ func (siw *ServerInterfaceWrapper) GetUsersUserId(w http.ResponseWriter, r *http
 		return
 	}
 
+	// ------------- Path parameter "webhookId" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhookId", r.PathValue("webhookId"), &webhookId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhookId", Err: err})
+		return
+	}
+
+	// ------------- Path parameter "deliveryId" -------------
+	var deliveryId int64
+
+	err = runtime.BindStyledParameterWithOptions("simple", "deliveryId", r.PathValue("deliveryId"), &deliveryId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "deliveryId", Err: err})
+		return
+	}
+
 	ctx := r.Context()
 
 	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
@@ -391,7 +2447,7 @@ func (siw *ServerInterfaceWrapper) GetUsersUserId(w http.ResponseWriter, r *http
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUsersUserId(w, r, userId)
+		siw.Handler.PostUsersUserIdWebhooksWebhookIdDeliveriesDeliveryIdRetry(w, r, userId, webhookId, deliveryId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -401,8 +2457,8 @@ func (siw *ServerInterfaceWrapper) GetUsersUserId(w http.ResponseWriter, r *http
 	handler.ServeHTTP(w, r)
 }
 
-// PutUsersUserId operation middleware
-func (siw *ServerInterfaceWrapper) PutUsersUserId(w http.ResponseWriter, r *http.Request) {
+// PostUsersUserIdWebhooksWebhookIdSecret operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersUserIdWebhooksWebhookIdSecret(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -415,6 +2471,15 @@ func (siw *ServerInterfaceWrapper) PutUsersUserId(w http.ResponseWriter, r *http
 		return
 	}
 
+	// ------------- Path parameter "webhookId" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhookId", r.PathValue("webhookId"), &webhookId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhookId", Err: err})
+		return
+	}
+
 	ctx := r.Context()
 
 	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
@@ -422,7 +2487,7 @@ func (siw *ServerInterfaceWrapper) PutUsersUserId(w http.ResponseWriter, r *http
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PutUsersUserId(w, r, userId)
+		siw.Handler.PostUsersUserIdWebhooksWebhookIdSecret(w, r, userId, webhookId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -432,8 +2497,8 @@ func (siw *ServerInterfaceWrapper) PutUsersUserId(w http.ResponseWriter, r *http
 	handler.ServeHTTP(w, r)
 }
 
-// GetUsersUserIdChoices operation middleware
-func (siw *ServerInterfaceWrapper) GetUsersUserIdChoices(w http.ResponseWriter, r *http.Request) {
+// DeleteUsersUserIdTotp operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdTotp(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -453,7 +2518,7 @@ func (siw *ServerInterfaceWrapper) GetUsersUserIdChoices(w http.ResponseWriter,
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUsersUserIdChoices(w, r, userId)
+		siw.Handler.DeleteUsersUserIdTotp(w, r, userId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -463,8 +2528,8 @@ func (siw *ServerInterfaceWrapper) GetUsersUserIdChoices(w http.ResponseWriter,
 	handler.ServeHTTP(w, r)
 }
 
-// GetUsersUserIdChoicesChoiceId operation middleware
-func (siw *ServerInterfaceWrapper) GetUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *http.Request) {
+// GetUsersUserIdWards operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersUserIdWards(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -477,15 +2542,6 @@ func (siw *ServerInterfaceWrapper) GetUsersUserIdChoicesChoiceId(w http.Response
 		return
 	}
 
-	// ------------- Path parameter "choiceId" -------------
-	var choiceId int
-
-	err = runtime.BindStyledParameterWithOptions("simple", "choiceId", r.PathValue("choiceId"), &choiceId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
-	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "choiceId", Err: err})
-		return
-	}
-
 	ctx := r.Context()
 
 	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
@@ -493,7 +2549,7 @@ func (siw *ServerInterfaceWrapper) GetUsersUserIdChoicesChoiceId(w http.Response
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetUsersUserIdChoicesChoiceId(w, r, userId, choiceId)
+		siw.Handler.GetUsersUserIdWards(w, r, userId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -503,8 +2559,8 @@ func (siw *ServerInterfaceWrapper) GetUsersUserIdChoicesChoiceId(w http.Response
 	handler.ServeHTTP(w, r)
 }
 
-// PostUsersUserIdChoicesChoiceId operation middleware
-func (siw *ServerInterfaceWrapper) PostUsersUserIdChoicesChoiceId(w http.ResponseWriter, r *http.Request) {
+// DeleteUsersUserIdWardsLinkId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersUserIdWardsLinkId(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -517,12 +2573,12 @@ func (siw *ServerInterfaceWrapper) PostUsersUserIdChoicesChoiceId(w http.Respons
 		return
 	}
 
-	// ------------- Path parameter "choiceId" -------------
-	var choiceId int
+	// ------------- Path parameter "linkId" -------------
+	var linkId int
 
-	err = runtime.BindStyledParameterWithOptions("simple", "choiceId", r.PathValue("choiceId"), &choiceId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	err = runtime.BindStyledParameterWithOptions("simple", "linkId", r.PathValue("linkId"), &linkId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "choiceId", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "linkId", Err: err})
 		return
 	}
 
@@ -533,7 +2589,7 @@ func (siw *ServerInterfaceWrapper) PostUsersUserIdChoicesChoiceId(w http.Respons
 	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostUsersUserIdChoicesChoiceId(w, r, userId, choiceId)
+		siw.Handler.DeleteUsersUserIdWardsLinkId(w, r, userId, linkId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -648,12 +2704,6 @@ func (siw *ServerInterfaceWrapper) GetWeekDate(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	ctx := r.Context()
-
-	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
-
-	r = r.WithContext(ctx)
-
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		siw.Handler.GetWeekDate(w, r, date)
 	}))
@@ -785,24 +2835,83 @@ func HandlerWithOptions(si ServerInterface, options StdHTTPServerOptions) http.H
 		ErrorHandlerFunc:   options.ErrorHandlerFunc,
 	}
 
+	m.HandleFunc("GET "+options.BaseURL+"/.well-known/jwks.json", wrapper.GetWellKnownJwksJson)
+	m.HandleFunc("GET "+options.BaseURL+"/audit", wrapper.GetAudit)
+	m.HandleFunc("GET "+options.BaseURL+"/audit/verify", wrapper.GetAuditVerify)
 	m.HandleFunc("GET "+options.BaseURL+"/cafeteria", wrapper.GetCafeteria)
+	m.HandleFunc("GET "+options.BaseURL+"/calendar/{token}", wrapper.GetCalendarToken)
+	m.HandleFunc("GET "+options.BaseURL+"/changes", wrapper.GetChanges)
+	m.HandleFunc("GET "+options.BaseURL+"/stream", wrapper.GetStream)
 	m.HandleFunc("GET "+options.BaseURL+"/currentUser", wrapper.GetCurrentUser)
+	m.HandleFunc("POST "+options.BaseURL+"/guardians/accept", wrapper.PostGuardiansAccept)
+	m.HandleFunc("GET "+options.BaseURL+"/lockouts", wrapper.GetLockouts)
+	m.HandleFunc("DELETE "+options.BaseURL+"/lockouts/{lockoutId}", wrapper.DeleteLockoutsLockoutId)
 	m.HandleFunc("POST "+options.BaseURL+"/login", wrapper.PostLogin)
+	m.HandleFunc("POST "+options.BaseURL+"/login/totp", wrapper.PostLoginTotp)
+	m.HandleFunc("POST "+options.BaseURL+"/login/totp/enroll", wrapper.PostLoginTotpEnroll)
 	m.HandleFunc("POST "+options.BaseURL+"/logout", wrapper.PostLogout)
+	m.HandleFunc("GET "+options.BaseURL+"/oidc/callback", wrapper.GetOidcCallback)
+	m.HandleFunc("POST "+options.BaseURL+"/oidc/link", wrapper.PostOidcLink)
+	m.HandleFunc("GET "+options.BaseURL+"/oidc/login", wrapper.GetOidcLogin)
+	m.HandleFunc("POST "+options.BaseURL+"/passwordReset", wrapper.PostPasswordReset)
+	m.HandleFunc("POST "+options.BaseURL+"/passwordReset/confirm", wrapper.PostPasswordResetConfirm)
+	m.HandleFunc("GET "+options.BaseURL+"/push/vapidKey", wrapper.GetPushVapidKey)
+	m.HandleFunc("POST "+options.BaseURL+"/token/refresh", wrapper.PostTokenRefresh)
 	m.HandleFunc("GET "+options.BaseURL+"/untis/classes", wrapper.GetUntisClasses)
 	m.HandleFunc("GET "+options.BaseURL+"/untis/fetch", wrapper.GetUntisFetch)
 	m.HandleFunc("GET "+options.BaseURL+"/untis/rooms", wrapper.GetUntisRooms)
 	m.HandleFunc("GET "+options.BaseURL+"/untis/subjects", wrapper.GetUntisSubjects)
+	m.HandleFunc("GET "+options.BaseURL+"/untis/sync", wrapper.GetUntisSync)
 	m.HandleFunc("GET "+options.BaseURL+"/untis/teachers", wrapper.GetUntisTeachers)
+	m.HandleFunc("PUT "+options.BaseURL+"/user/password", wrapper.PutUserPassword)
+	m.HandleFunc("DELETE "+options.BaseURL+"/user/totp", wrapper.DeleteUserTotp)
+	m.HandleFunc("GET "+options.BaseURL+"/user/totp", wrapper.GetUserTotp)
+	m.HandleFunc("POST "+options.BaseURL+"/user/totp", wrapper.PostUserTotp)
+	m.HandleFunc("POST "+options.BaseURL+"/user/totp/confirm", wrapper.PostUserTotpConfirm)
+	m.HandleFunc("POST "+options.BaseURL+"/user/totp/recoveryCodes", wrapper.PostUserTotpRecoveryCodes)
+	m.HandleFunc("GET "+options.BaseURL+"/user/untisAcc", wrapper.GetUserUntisAcc)
 	m.HandleFunc("PUT "+options.BaseURL+"/user/untisAcc", wrapper.PutUserUntisAcc)
 	m.HandleFunc("GET "+options.BaseURL+"/users", wrapper.GetUsers)
 	m.HandleFunc("POST "+options.BaseURL+"/users", wrapper.PostUsers)
 	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}", wrapper.DeleteUsersUserId)
 	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}", wrapper.GetUsersUserId)
 	m.HandleFunc("PUT "+options.BaseURL+"/users/{userId}", wrapper.PutUsersUserId)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/appPasswords", wrapper.GetUsersUserIdAppPasswords)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/appPasswords", wrapper.PostUsersUserIdAppPasswords)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/appPasswords/{appPasswordId}", wrapper.DeleteUsersUserIdAppPasswordsAppPasswordId)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/calendars", wrapper.GetUsersUserIdCalendars)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/calendars", wrapper.PostUsersUserIdCalendars)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/calendars/{calendarId}", wrapper.DeleteUsersUserIdCalendarsCalendarId)
 	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/choices", wrapper.GetUsersUserIdChoices)
 	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/choices/{choiceId}", wrapper.GetUsersUserIdChoicesChoiceId)
 	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/choices/{choiceId}", wrapper.PostUsersUserIdChoicesChoiceId)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/guardians", wrapper.GetUsersUserIdGuardians)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/guardians", wrapper.PostUsersUserIdGuardians)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/guardians/{linkId}", wrapper.DeleteUsersUserIdGuardiansLinkId)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/identities", wrapper.GetUsersUserIdIdentities)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/identities/{identityId}", wrapper.DeleteUsersUserIdIdentitiesIdentityId)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/passwordReset", wrapper.PostUsersUserIdPasswordReset)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/push/preferences", wrapper.GetUsersUserIdPushPreferences)
+	m.HandleFunc("PUT "+options.BaseURL+"/users/{userId}/push/preferences", wrapper.PutUsersUserIdPushPreferences)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/push/subscriptions", wrapper.GetUsersUserIdPushSubscriptions)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/push/subscriptions", wrapper.PostUsersUserIdPushSubscriptions)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/push/subscriptions/{subscriptionId}", wrapper.DeleteUsersUserIdPushSubscriptionsSubscriptionId)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/sessions", wrapper.DeleteUsersUserIdSessions)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/sessions", wrapper.GetUsersUserIdSessions)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/sessions/{sessionId}", wrapper.DeleteUsersUserIdSessionsSessionId)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/tokens", wrapper.GetUsersUserIdTokens)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/tokens", wrapper.PostUsersUserIdTokens)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/tokens/{tokenId}", wrapper.DeleteUsersUserIdTokensTokenId)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/webhooks", wrapper.GetUsersUserIdWebhooks)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/webhooks", wrapper.PostUsersUserIdWebhooks)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/webhooks/{webhookId}", wrapper.DeleteUsersUserIdWebhooksWebhookId)
+	m.HandleFunc("PUT "+options.BaseURL+"/users/{userId}/webhooks/{webhookId}", wrapper.PutUsersUserIdWebhooksWebhookId)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/webhooks/{webhookId}/deliveries", wrapper.GetUsersUserIdWebhooksWebhookIdDeliveries)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/webhooks/{webhookId}/deliveries/{deliveryId}/retry", wrapper.PostUsersUserIdWebhooksWebhookIdDeliveriesDeliveryIdRetry)
+	m.HandleFunc("POST "+options.BaseURL+"/users/{userId}/webhooks/{webhookId}/secret", wrapper.PostUsersUserIdWebhooksWebhookIdSecret)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/totp", wrapper.DeleteUsersUserIdTotp)
+	m.HandleFunc("GET "+options.BaseURL+"/users/{userId}/wards", wrapper.GetUsersUserIdWards)
+	m.HandleFunc("DELETE "+options.BaseURL+"/users/{userId}/wards/{linkId}", wrapper.DeleteUsersUserIdWardsLinkId)
 	m.HandleFunc("PUT "+options.BaseURL+"/view", wrapper.PutView)
 	m.HandleFunc("PUT "+options.BaseURL+"/view/user/{userId}", wrapper.PutViewUserUserId)
 	m.HandleFunc("GET "+options.BaseURL+"/week/{date}", wrapper.GetWeekDate)
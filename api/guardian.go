@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// Get the guardians and open guardian invites of a student
+// (GET /users/{userId}/guardians)
+func (server Server) GetUsersUserIdGuardians(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.GuardiansManage, userId) {
+		return
+	}
+	links, err := server.DB.GetGuardians(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(links)
+}
+
+// Invite a guardian to read the timetable of a student
+// (POST /users/{userId}/guardians)
+func (server Server) PostUsersUserIdGuardians(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.GuardiansManage, userId) {
+		return
+	}
+	code, expiresAt, link, err := server.DB.CreateGuardianInvite(userId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrUserNotFound) {
+			http.Error(w, "User not found.", http.StatusNotFound)
+		} else if errors.Is(err, dbModels.ErrGuardianLimitReached) {
+			http.Error(w, "The maximum number of guardians is reached. Revoke a guardian or an open invite first.", http.StatusConflict)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.audit(r, "guardian.invite", "user", userId, map[string]interface{}{"linkId": *link.Id})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(gen.GuardianInvite{Code: code, ExpiresAt: expiresAt, Link: link})
+}
+
+// Revoke a guardian or an open invite
+// (DELETE /users/{userId}/guardians/{linkId})
+func (server Server) DeleteUsersUserIdGuardiansLinkId(w http.ResponseWriter, r *http.Request, userId int, linkId int) {
+	server.revokeGuardianLink(w, r, userId, linkId)
+}
+
+// Get the students a guardian can read the timetable of
+// (GET /users/{userId}/wards)
+func (server Server) GetUsersUserIdWards(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.GuardiansManage, userId) {
+		return
+	}
+	links, err := server.DB.GetWards(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(links)
+}
+
+// Give up the read access to the timetable of a student
+// (DELETE /users/{userId}/wards/{linkId})
+func (server Server) DeleteUsersUserIdWardsLinkId(w http.ResponseWriter, r *http.Request, userId int, linkId int) {
+	server.revokeGuardianLink(w, r, userId, linkId)
+}
+
+// revokeGuardianLink deletes a link of the student or the guardian userId.
+func (server Server) revokeGuardianLink(w http.ResponseWriter, r *http.Request, userId int, linkId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.GuardiansManage, userId) {
+		return
+	}
+	link, err := server.DB.RevokeGuardianLink(userId, linkId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrGuardianLinkNotFound) {
+			http.Error(w, "Guardian link not found.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	diff := map[string]interface{}{"linkId": linkId}
+	if link.GuardianId != nil {
+		diff["guardianId"] = *link.GuardianId
+	}
+	server.audit(r, "guardian.revoke", "user", *link.StudentId, diff)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Accept a guardian invite with the current user as guardian
+// (POST /guardians/accept)
+func (server Server) PostGuardiansAccept(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	var body gen.PostGuardiansAcceptJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	throttleKeys := []string{"user:" + strconv.Itoa(*user.Id), "ip:" + clientIP(r)}
+	if server.checkThrottle(w, r, "guardian", throttleKeys) != nil {
+		return
+	}
+	link, err := server.DB.AcceptGuardianInvite(body.Code, *user.Id, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrGuardianInviteInvalid) {
+			server.recordFailure(r, "guardian", throttleKeys)
+			http.Error(w, "Invalid or expired invite.", http.StatusForbidden)
+		} else if errors.Is(err, dbModels.ErrGuardianSelf) {
+			http.Error(w, "You can not be your own guardian.", http.StatusUnprocessableEntity)
+		} else if errors.Is(err, dbModels.ErrGuardianAlreadyLinked) {
+			http.Error(w, "You are already a guardian of this student.", http.StatusConflict)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.recordSuccess(r, "guardian", throttleKeys[:1])
+	server.audit(r, "guardian.accept", "user", *link.StudentId, map[string]interface{}{"linkId": *link.Id, "guardianId": *user.Id})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(link)
+}
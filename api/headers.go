@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+)
+
+// SecurityHeadersMiddleware sets the security headers configured in config.SecurityHeaders on every response.
+func SecurityHeadersMiddleware(next http.Handler) http.Handler {
+	headers := config.Config.SecurityHeaders
+	var hsts string
+	if headers.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(headers.HSTSMaxAge.Seconds()))
+		if headers.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+	frameAncestors := "'none'"
+	if len(headers.FrameAncestors) > 0 {
+		frameAncestors = strings.Join(headers.FrameAncestors, " ")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hsts != "" {
+			w.Header().Set("Strict-Transport-Security", hsts)
+		}
+		w.Header().Set("Content-Security-Policy", "frame-ancestors "+frameAncestors)
+		if len(headers.FrameAncestors) == 0 {
+			// For browsers without support for frame-ancestors
+			w.Header().Set("X-Frame-Options", "DENY")
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		next.ServeHTTP(w, r)
+	})
+}
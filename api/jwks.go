@@ -0,0 +1,15 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Public keys to verify issued tokens
+// (GET /.well-known/jwks.json)
+func (server Server) GetWellKnownJwksJson(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(server.DB.GetJWKS())
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/sso"
+)
+
+type oidcLinkResponse struct {
+	AuthorizationUrl string `json:"authorizationUrl"`
+}
+
+// frontendRedirect returns the frontend url for a redirect path. Only paths are accepted, so the login can not be
+// used to redirect to other sites.
+func frontendRedirect(redirect string) string {
+	base := strings.TrimSuffix(config.Config.OIDC.FrontendURL, "/")
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.Contains(redirect, "\\") {
+		return base + "/"
+	}
+	return base + redirect
+}
+
+// oidcBindingCookieName is the cookie that binds a login at the identity provider to the browser that started it.
+// Without it a callback url of an attacker could log the victim into the attacker's account, or link the victim's
+// identity to the attacker's user.
+const oidcBindingCookieName = "oidc_binding"
+
+// setOIDCBindingCookie stores the binding of a login state in the browser. It is sent with the top level
+// navigation back from the identity provider, but not with cross site requests.
+func setOIDCBindingCookie(w http.ResponseWriter, state db.OIDCLoginState) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcBindingCookieName,
+		Value:    state.Binding,
+		Path:     "/",
+		Domain:   config.Config.Cookies.Domain,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// getOIDCProvider writes an error response if OIDC is disabled or the identity provider is not reachable.
+func getOIDCProvider(w http.ResponseWriter, r *http.Request) (*sso.Provider, error) {
+	provider, err := sso.GetProvider(r.Context())
+	if err != nil {
+		if errors.Is(err, sso.ErrDisabled) {
+			http.Error(w, "OIDC login is disabled on this server.", http.StatusNotFound)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Identity provider not reachable.", http.StatusBadGateway)
+		}
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Login through the identity provider of the school
+// (GET /oidc/login)
+func (server Server) GetOidcLogin(w http.ResponseWriter, r *http.Request, params gen.GetOidcLoginParams) {
+	provider, err := getOIDCProvider(w, r)
+	if err != nil {
+		return
+	}
+	redirect := "/"
+	if params.Redirect != nil {
+		redirect = *params.Redirect
+	}
+	state, err := server.DB.CreateOIDCLoginState(redirect, 0, nil, r.Context())
+	if err != nil {
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	setOIDCBindingCookie(w, state)
+	http.Redirect(w, r, provider.AuthCodeURL(state.State, state.Nonce, state.Verifier), http.StatusFound)
+}
+
+// Start linking an account at the identity provider to the active user
+// (POST /oidc/link)
+func (server Server) PostOidcLink(w http.ResponseWriter, r *http.Request, params gen.PostOidcLinkParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user, claims := principal.User, principal.Claims
+	provider, err := getOIDCProvider(w, r)
+	if err != nil {
+		return
+	}
+	key, err := claims.UnwrapCryptoKey()
+	if err != nil {
+		if errors.Is(err, dbModels.ErrNoCryptoKey) {
+			http.Error(w, "Token carries no crypto key. Please log in again.", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	redirect := "/"
+	if params.Redirect != nil {
+		redirect = *params.Redirect
+	}
+	state, err := server.DB.CreateOIDCLoginState(redirect, *user.Id, key, r.Context())
+	if err != nil {
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	setOIDCBindingCookie(w, state)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(oidcLinkResponse{
+		AuthorizationUrl: provider.AuthCodeURL(state.State, state.Nonce, state.Verifier),
+	})
+}
+
+// Callback of the identity provider. Users with a second factor are redirected with a partial token, see partialLoginRedirect.
+// The callback is only accepted from the browser that started the login, a link also needs the session cookie of the
+// user that started it.
+// (GET /oidc/callback)
+func (server Server) GetOidcCallback(w http.ResponseWriter, r *http.Request, params gen.GetOidcCallbackParams) {
+	provider, err := getOIDCProvider(w, r)
+	if err != nil {
+		return
+	}
+	binding := ""
+	if cookie, err := r.Cookie(oidcBindingCookieName); err == nil {
+		binding = cookie.Value
+	}
+	loginState, err := server.DB.ConsumeOIDCLoginState(params.State, binding, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrOIDCStateInvalid) {
+			http.Error(w, "Invalid or expired login. Please try again.", http.StatusBadRequest)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcBindingCookieName,
+		Value:    "",
+		Path:     "/",
+		Domain:   config.Config.Cookies.Domain,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if params.Error != nil || params.Code == nil {
+		http.Error(w, "Login at the identity provider failed.", http.StatusUnauthorized)
+		return
+	}
+	identity, err := provider.Exchange(r.Context(), *params.Code, loginState.Verifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %s", err.Error())
+		http.Error(w, "Login at the identity provider failed.", http.StatusUnauthorized)
+		return
+	}
+
+	if loginState.LinkUserId != 0 {
+		// The link has to be finished by the user that started it
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok || *principal.User.Id != loginState.LinkUserId {
+			http.Error(w, "Log in as the user that started the link and try again.", http.StatusForbidden)
+			return
+		}
+		_, err = server.DB.LinkIdentity(identity, loginState, params.State, r.Context())
+		if err != nil {
+			if errors.Is(err, dbModels.ErrIdentityLinked) {
+				http.Error(w, "The account is already linked to another user.", http.StatusConflict)
+			} else if errors.Is(err, dbModels.ErrUserNotFound) {
+				http.Error(w, "User not found.", http.StatusNotFound)
+			} else {
+				log.Printf("Error type: %T, Details: %s", err, err.Error())
+				http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			}
+			return
+		}
+		server.audit(r, "identity.link", "user", loginState.LinkUserId, map[string]interface{}{"issuer": identity.Issuer, "subject": identity.Subject})
+		http.Redirect(w, r, frontendRedirect(loginState.Redirect), http.StatusFound)
+		return
+	}
+
+	tokens, _, err := server.DB.LoginWithIdentity(identity, r.UserAgent(), clientIP(r), r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrIdentityNotLinked) {
+			http.Error(w, "The account is not linked to any user. Log in with your password and link it first.", http.StatusForbidden)
+		} else if errors.Is(err, dbModels.ErrUsernameTaken) {
+			http.Error(w, "A user with this name already exists. Log in with your password and link the account.", http.StatusConflict)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	if tokens.PartialToken != "" {
+		http.Redirect(w, r, partialLoginRedirect(frontendRedirect(loginState.Redirect), tokens), http.StatusFound)
+		return
+	}
+	setSessionCookies(w, r, tokens)
+	http.Redirect(w, r, frontendRedirect(loginState.Redirect), http.StatusFound)
+}
+
+// partialLoginRedirect adds the partial token of a login that needs a second factor to the fragment of the frontend
+// url, which the browser does not send to any server. The frontend finishes the login with POST /login/totp.
+func partialLoginRedirect(target string, tokens db.SessionTokens) string {
+	fragment := url.Values{}
+	fragment.Set("partialToken", tokens.PartialToken)
+	if tokens.EnrollmentRequired {
+		fragment.Set("enrollmentRequired", "true")
+	}
+	separator := "#"
+	if strings.Contains(target, "#") {
+		separator = "&"
+	}
+	return target + separator + fragment.Encode()
+}
+
+// Get the linked identities of a user
+// (GET /users/{userId}/identities)
+func (server Server) GetUsersUserIdIdentities(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	resp, err := server.DB.GetIdentities(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Unlink an identity from a user
+// (DELETE /users/{userId}/identities/{identityId})
+func (server Server) DeleteUsersUserIdIdentitiesIdentityId(w http.ResponseWriter, r *http.Request, userId int, identityId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	err := server.DB.UnlinkIdentity(userId, identityId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrIdentityNotFound) || errors.Is(err, dbModels.ErrUserNotFound) {
+			http.Error(w, "Identity not found.", http.StatusNotFound)
+		} else if errors.Is(err, dbModels.ErrLastLoginMethod) {
+			http.Error(w, "The user has no password, the last identity can not be removed.", http.StatusConflict)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.audit(r, "identity.unlink", "user", userId, map[string]interface{}{"identityId": identityId})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/mailer"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/policy"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sendPasswordResetMail creates a reset token for a user and mails the reset link.
+func (server Server) sendPasswordResetMail(userId int, requestedBy int, ctx context.Context) error {
+	token, user, err := server.DB.CreatePasswordResetToken(userId, requestedBy, ctx)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"a password reset was requested for your account. Open the following link to set a new password:\n\n"+
+		"%s\n\n"+
+		"The link is valid for %s. After the reset you have to connect your Untis account again.\n"+
+		"If you did not request this, you can ignore this mail.\n",
+		user.Name,
+		fmt.Sprintf(config.Config.PasswordReset.ResetURL, token),
+		config.Config.PasswordReset.TokenLifetime,
+	)
+	return mailer.DefaultMailer.Send(*user.Email, "Password reset", body)
+}
+
+// Request a password reset link for a user
+// (POST /passwordReset)
+func (server Server) PostPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body gen.PostPasswordResetJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Username == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// Every request counts, so nobody can flood a user with mails or keep invalidating their reset link
+	throttleKeys := []string{"user:" + strings.ToLower(*body.Username), "ip:" + clientIP(r)}
+	if server.checkThrottle(w, r, "passwordReset", throttleKeys) != nil {
+		return
+	}
+	server.recordFailure(r, "passwordReset", throttleKeys)
+	// The response is the same for unknown users and users without email and is sent before the lookup and the mail,
+	// so neither the answer nor its timing can be used to probe accounts.
+	go func(username string) {
+		ctx := context.Background()
+		userId, err := server.DB.GetUserIdByName(username, ctx)
+		if err == nil {
+			err = server.sendPasswordResetMail(userId, userId, ctx)
+		}
+		if err != nil && !errors.Is(err, dbModels.ErrUserNotFound) && !errors.Is(err, dbModels.ErrNoEmail) {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+		}
+	}(*body.Username)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Set a new password with a password reset token
+// (POST /passwordReset/confirm)
+func (server Server) PostPasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	var body gen.PostPasswordResetConfirmJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Token == nil || body.NewPassword == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userId, err := server.DB.ResetPassword(*body.Token, *body.NewPassword, r.Context())
+	if err != nil {
+		var policyErr *policy.Error
+		if errors.Is(err, dbModels.ErrResetTokenInvalid) {
+			http.Error(w, "Invalid or expired reset token.", http.StatusForbidden)
+		} else if errors.As(err, &policyErr) {
+			writePolicyError(w, policyErr)
+		} else if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			http.Error(w, "Password to long.", http.StatusUnprocessableEntity)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.audit(r, "password.reset", "user", userId, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Send a password reset link to a user (admin)
+// (POST /users/{userId}/passwordReset)
+func (server Server) PostUsersUserIdPasswordReset(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	err := server.sendPasswordResetMail(userId, *user.Id, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrUserNotFound) {
+			http.Error(w, "User not found.", http.StatusNotFound)
+		} else if errors.Is(err, dbModels.ErrNoEmail) {
+			http.Error(w, "The user has no email address.", http.StatusUnprocessableEntity)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.audit(r, "password.reset.request", "user", userId, nil)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db/dbtest"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/mailer"
+)
+
+// waitForMail returns the content of the first mail written to dir by a FileMailer.
+func waitForMail(t *testing.T, dir string) string {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) > 0 {
+			content, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(content)
+		}
+	}
+	t.Fatal("no mail was sent")
+	return ""
+}
+
+// postJSON calls a handler with body encoded as JSON and returns the status code.
+func postJSON(handler http.HandlerFunc, path string, body interface{}) int {
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data)))
+	return w.Code
+}
+
+func TestPasswordReset(t *testing.T) {
+	database := db.NewDatabase(dbtest.Config(t))
+	t.Cleanup(func() { database.DB.Close() })
+	server := NewServer(database)
+
+	dir := t.TempDir()
+	previousMailer, resetURL := mailer.DefaultMailer, config.Config.PasswordReset.ResetURL
+	t.Cleanup(func() {
+		mailer.DefaultMailer, config.Config.PasswordReset.ResetURL = previousMailer, resetURL
+	})
+	mailer.DefaultMailer = mailer.FileMailer{Dir: dir, From: "timetable@school.example"}
+	config.Config.PasswordReset.ResetURL = "https://timetable.example/reset?token=%s"
+
+	name, email := "reset.student", "student@school.example"
+	ctx := context.Background()
+	if _, err := database.CreateUser(gen.User{Name: name, Email: &email}, "correct horse battery", ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := postJSON(server.PostPasswordReset, "/passwordReset", gen.PostPasswordResetJSONBody{Username: &name}); code != http.StatusAccepted {
+		t.Fatalf("POST /passwordReset: status %d, want %d", code, http.StatusAccepted)
+	}
+	mail := waitForMail(t, dir)
+	match := regexp.MustCompile(`https://timetable\.example/reset\?token=([A-Za-z0-9_-]+)`).FindStringSubmatch(mail)
+	if match == nil {
+		t.Fatalf("the mail contains no reset link:\n%s", mail)
+	}
+	token := match[1]
+
+	newPassword := "staple battery horse"
+	confirm := gen.PostPasswordResetConfirmJSONBody{Token: &token, NewPassword: &newPassword}
+	if code := postJSON(server.PostPasswordResetConfirm, "/passwordReset/confirm", confirm); code != http.StatusNoContent {
+		t.Fatalf("POST /passwordReset/confirm: status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := postJSON(server.PostPasswordResetConfirm, "/passwordReset/confirm", confirm); code != http.StatusForbidden {
+		t.Errorf("second POST /passwordReset/confirm: status %d, want %d", code, http.StatusForbidden)
+	}
+	if _, _, err := database.CreateSession(gen.PostLoginJSONBody{Username: &name, Password: &newPassword}, "test", "127.0.0.1", ctx); err != nil {
+		t.Errorf("login with the new password failed: %s", err)
+	}
+}
+
+func TestPasswordResetThrottle(t *testing.T) {
+	database := db.NewDatabase(dbtest.Config(t))
+	t.Cleanup(func() { database.DB.Close() })
+	server := NewServer(database)
+
+	previousMailer := mailer.DefaultMailer
+	t.Cleanup(func() { mailer.DefaultMailer = previousMailer })
+	mailer.DefaultMailer = mailer.LogMailer{}
+
+	name := "unknown.user"
+	body := gen.PostPasswordResetJSONBody{Username: &name}
+	// The request after the free attempts is answered, but delays the next one
+	for i := 0; i <= config.Config.Throttle.FreeAttempts; i++ {
+		if code := postJSON(server.PostPasswordReset, "/passwordReset", body); code != http.StatusAccepted {
+			t.Fatalf("request %d: status %d, want %d", i+1, code, http.StatusAccepted)
+		}
+	}
+	if code := postJSON(server.PostPasswordReset, "/passwordReset", body); code != http.StatusTooManyRequests {
+		t.Errorf("delayed request: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
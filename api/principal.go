@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Principal is the authenticated user of a request.
+type Principal struct {
+	User   gen.User
+	Claims *db.Claims
+}
+
+type principalContextKey struct{}
+
+// PrincipalFromContext returns the principal AuthMiddleware stored in the context.
+// ok is false if the request is not authenticated.
+func PrincipalFromContext(ctx context.Context) (principal Principal, ok bool) {
+	principal, ok = ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+// requirePrincipal returns the principal of the request and writes a 401 response if there is none.
+// AuthMiddleware already rejects unauthenticated requests to secured operations, so this only fails if an operation is wrongly declared public.
+func requirePrincipal(w http.ResponseWriter, r *http.Request) (Principal, bool) {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "No token provided.", http.StatusUnauthorized)
+	}
+	return principal, ok
+}
+
+// scopeError is returned if a personal access token can not be used for a request.
+type scopeError struct {
+	// scope the token lacks, "" if the operation can only be used with a session.
+	scope gen.TokenScope
+}
+
+func (e *scopeError) Error() string {
+	if e.scope == "" {
+		return "operation requires a session"
+	}
+	return "token lacks the scope " + string(e.scope)
+}
+
+func (e *scopeError) Unwrap() error {
+	return dbModels.ErrInsufficientScope
+}
+
+// AuthMiddleware authenticates the request once and stores the Principal in the request context.
+//
+// Operations are secured if their security requirement in the spec includes BearerAuth, which the generated
+// wrappers declare by setting gen.BearerAuthScopes. Those are rejected without a valid token.
+// All other operations are public: a token is used if one is sent and valid, otherwise the request continues unauthenticated.
+func (server Server) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, secured := r.Context().Value(gen.BearerAuthScopes).([]string)
+		principal, err := server.authenticate(r)
+		if err != nil {
+			if secured {
+				writeAuthError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal)))
+	})
+}
+
+// authenticate verifies the session or personal access token of the request. It does not write a response.
+func (server Server) authenticate(r *http.Request) (Principal, error) {
+	token, err := tokenFromRequest(r)
+	if err != nil {
+		return Principal{}, err
+	}
+	if db.IsAccessToken(token) {
+		user, claims, err := server.DB.VerifyAccessToken(token, clientIP(r), r.Context())
+		if err != nil {
+			return Principal{}, err
+		}
+		scope, ok := accessTokenScope(r)
+		if !ok {
+			return Principal{}, &scopeError{}
+		}
+		if !claims.HasScope(string(scope)) {
+			return Principal{}, &scopeError{scope: scope}
+		}
+		return Principal{User: user, Claims: claims}, nil
+	}
+	user, claims, err := server.DB.VerifySession(token, r.Context())
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{User: user, Claims: claims}, nil
+}
+
+// writeAuthError writes the response for an error of authenticate.
+func writeAuthError(w http.ResponseWriter, err error) {
+	var scopeErr *scopeError
+	if errors.As(err, &scopeErr) {
+		if scopeErr.scope == "" {
+			http.Error(w, "This endpoint can not be used with a personal access token.", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+string(scopeErr.scope)+`"`)
+		http.Error(w, "The token lacks the scope "+string(scopeErr.scope)+".", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, http.ErrNoCookie) {
+		http.Error(w, "No token provided.", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, dbModels.ErrInvalidPassword) ||
+		errors.Is(err, dbModels.ErrUserNotFound) ||
+		errors.Is(err, dbModels.ErrSessionRevoked) ||
+		errors.Is(err, dbModels.ErrSecondFactorRequired) ||
+		errors.Is(err, dbModels.ErrAccessTokenInvalid) {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	var jwterr *jwt.ValidationError
+	if errors.As(err, &jwterr) {
+		errCode := jwterr.Errors
+		if errCode&jwt.ValidationErrorMalformed != 0 ||
+			errCode&jwt.ValidationErrorUnverifiable != 0 ||
+			errCode&jwt.ValidationErrorSignatureInvalid != 0 {
+			http.Error(w, "Token malformed or Signature Invalid.", http.StatusBadRequest)
+		} else if errCode&jwt.ValidationErrorExpired != 0 ||
+			errCode&jwt.ValidationErrorNotValidYet != 0 {
+			// Tell the client to use its refresh token (POST /token/refresh)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="The access token expired"`)
+			http.Error(w, "Token currently not Valid.", http.StatusUnauthorized)
+		} else if errCode&jwt.ValidationErrorId != 0 ||
+			errCode&jwt.ValidationErrorIssuedAt != 0 ||
+			errCode&jwt.ValidationErrorIssuer != 0 ||
+			errCode&jwt.ValidationErrorClaimsInvalid != 0 {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		} else {
+			http.Error(w, "Malformed Authorization", http.StatusUnauthorized)
+		}
+		return
+	}
+	log.Printf("Error type: %T, Details: %s", err, err.Error())
+	http.Error(w, "Internal server error.", http.StatusInternalServerError)
+}
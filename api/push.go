@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// Get the VAPID public key to subscribe to push notifications
+// (GET /push/vapidKey)
+func (server Server) GetPushVapidKey(w http.ResponseWriter, r *http.Request) {
+	if !config.Config.WebPush.Enabled {
+		http.Error(w, "Push notifications are disabled.", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(gen.VapidKey{PublicKey: config.Config.WebPush.VAPIDPublicKey})
+}
+
+// Get the push subscriptions of a user
+// (GET /users/{userId}/push/subscriptions)
+func (server Server) GetUsersUserIdPushSubscriptions(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.NotificationsManage, userId) {
+		return
+	}
+	subscriptions, err := server.DB.GetPushSubscriptions(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(subscriptions)
+}
+
+// Subscribe a browser to the push notifications of a user
+// (POST /users/{userId}/push/subscriptions)
+func (server Server) PostUsersUserIdPushSubscriptions(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.NotificationsManage, userId) {
+		return
+	}
+	if !config.Config.WebPush.Enabled {
+		http.Error(w, "Push notifications are disabled.", http.StatusNotFound)
+		return
+	}
+	var body gen.PostUsersUserIdPushSubscriptionsJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Keys.P256dh == "" || body.Keys.Auth == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// The server sends requests to the endpoint, only accept push services reachable over https
+	endpoint, err := url.Parse(body.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		http.Error(w, "The endpoint has to be a https url.", http.StatusBadRequest)
+		return
+	}
+	subscription, err := server.DB.CreatePushSubscription(dbModels.PushSubscription{
+		UserId:    userId,
+		Endpoint:  body.Endpoint,
+		P256dh:    body.Keys.P256dh,
+		Auth:      body.Keys.Auth,
+		UserAgent: r.UserAgent(),
+	}, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrPushSubscriptionTaken) {
+			http.Error(w, "The endpoint is subscribed by another user.", http.StatusConflict)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(subscription)
+}
+
+// Unsubscribe a browser from push notifications
+// (DELETE /users/{userId}/push/subscriptions/{subscriptionId})
+func (server Server) DeleteUsersUserIdPushSubscriptionsSubscriptionId(w http.ResponseWriter, r *http.Request, userId int, subscriptionId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.NotificationsManage, userId) {
+		return
+	}
+	err := server.DB.DeletePushSubscription(userId, subscriptionId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrPushSubscriptionNotFound) {
+			http.Error(w, "Push subscription not found.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Get the push notification preferences of a user
+// (GET /users/{userId}/push/preferences)
+func (server Server) GetUsersUserIdPushPreferences(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.NotificationsManage, userId) {
+		return
+	}
+	preferences, err := server.DB.GetPushPreferences(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(preferences.ToGen())
+}
+
+// Change the push notification preferences of a user
+// (PUT /users/{userId}/push/preferences)
+func (server Server) PutUsersUserIdPushPreferences(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.NotificationsManage, userId) {
+		return
+	}
+	var body gen.PutUsersUserIdPushPreferencesJSONRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if (body.QuietHoursStart == nil) != (body.QuietHoursEnd == nil) {
+		http.Error(w, "Set both quietHoursStart and quietHoursEnd or none.", http.StatusBadRequest)
+		return
+	}
+	if body.QuietHoursStart != nil {
+		_, startErr := time.Parse("15:04", *body.QuietHoursStart)
+		_, endErr := time.Parse("15:04", *body.QuietHoursEnd)
+		if startErr != nil || endErr != nil {
+			http.Error(w, "The quiet hours have to be formatted as HH:MM.", http.StatusBadRequest)
+			return
+		}
+	}
+	preferences := dbModels.PushPreferences{UserId: userId}
+	preferences.FromGen(body)
+	err = server.DB.UpdatePushPreferences(preferences, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(preferences.ToGen())
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// Get the active sessions of a user
+// (GET /users/{userId}/sessions)
+func (server Server) GetUsersUserIdSessions(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user, claims := principal.User, principal.Claims
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	sessions, err := server.DB.GetSessions(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	for i := range sessions {
+		current := sessions[i].Id != nil && *sessions[i].Id == claims.ID
+		sessions[i].Current = &current
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(sessions)
+}
+
+// Revoke all sessions of a user
+// (DELETE /users/{userId}/sessions)
+func (server Server) DeleteUsersUserIdSessions(w http.ResponseWriter, r *http.Request, userId int, params gen.DeleteUsersUserIdSessionsParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user, claims := principal.User, principal.Claims
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	keepSessionId := ""
+	if params.KeepCurrent != nil && *params.KeepCurrent && userId == *user.Id {
+		keepSessionId = claims.ID
+	}
+	err := server.DB.RevokeSessions(userId, keepSessionId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	server.audit(r, "sessions.revoke", "user", userId, map[string]interface{}{"keptCurrent": keepSessionId != ""})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Revoke a session of a user
+// (DELETE /users/{userId}/sessions/{sessionId})
+func (server Server) DeleteUsersUserIdSessionsSessionId(w http.ResponseWriter, r *http.Request, userId int, sessionId string) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.CredentialsManage, userId) {
+		return
+	}
+	err := server.DB.RevokeSession(userId, sessionId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrSessionNotFound) {
+			http.Error(w, "Session not found.", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	server.audit(r, "session.revoke", "user", userId, map[string]interface{}{"sessionId": sessionId})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/events"
+)
+
+// openStreams counts the open streams of every user.
+var openStreams = struct {
+	sync.Mutex
+	perUser map[int]int
+}{perUser: map[int]int{}}
+
+// acquireStream counts a new stream of the user. ok is false if the user has Stream.MaxConnectionsPerUser open.
+func acquireStream(userId int) bool {
+	openStreams.Lock()
+	defer openStreams.Unlock()
+	if config.Config.Stream.MaxConnectionsPerUser > 0 && openStreams.perUser[userId] >= config.Config.Stream.MaxConnectionsPerUser {
+		return false
+	}
+	openStreams.perUser[userId]++
+	return true
+}
+
+func releaseStream(userId int) {
+	openStreams.Lock()
+	defer openStreams.Unlock()
+	openStreams.perUser[userId]--
+	if openStreams.perUser[userId] <= 0 {
+		delete(openStreams.perUser, userId)
+	}
+}
+
+// writeStreamEvent writes an event in the text/event-stream format. The data is compact JSON without line breaks.
+func writeStreamEvent(w http.ResponseWriter, event events.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, event.Data)
+	return err
+}
+
+// Stream lesson changes of the choice of the user, menu updates and week subtitles as Server-Sent Events
+// (GET /stream)
+func (server Server) GetStream(w http.ResponseWriter, r *http.Request, params gen.GetStreamParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if !config.Config.Stream.Enabled {
+		http.Error(w, "The stream is disabled.", http.StatusNotFound)
+		return
+	}
+	var lastId int64
+	resume := false
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, "Invalid Last-Event-ID.", http.StatusBadRequest)
+			return
+		}
+		lastId, resume = id, true
+	} else if params.LastEventId != nil && *params.LastEventId >= 0 {
+		lastId, resume = *params.LastEventId, true
+	}
+	if !acquireStream(*user.Id) {
+		http.Error(w, "Too many open streams.", http.StatusTooManyRequests)
+		return
+	}
+	defer releaseStream(*user.Id)
+
+	// Subscribe before the missed events are loaded, so no event falls between both. Only the events the hub
+	// already published are replayed, it holds back the events after an id that is not committed yet.
+	subscriber := events.DefaultHub.Subscribe(config.Config.Stream.ClientBuffer)
+	defer events.DefaultHub.Unsubscribe(subscriber)
+	publishedId, ready := events.DefaultHub.LastId()
+	if !ready {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "The stream is starting.", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	var replay []events.Event
+	reset := false
+	if resume {
+		oldestId, err := server.DB.OldestEventId(ctx)
+		if err != nil {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+			return
+		}
+		replay, err = server.DB.GetEventsAfter(lastId, publishedId, config.Config.Stream.MaxReplay+1, ctx)
+		if err != nil {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+			return
+		}
+		// Events after lastId were already deleted, or too many were missed to replay them
+		if (oldestId != 0 && oldestId > lastId+1) || len(replay) > config.Config.Stream.MaxReplay {
+			reset = true
+			replay = nil
+		}
+		replay, err = server.DB.FilterEventsForUser(*user.Id, replay, ctx)
+		if err != nil {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+			return
+		}
+	}
+
+	// The stream outlives the write timeout of the server, it ends with the access token instead
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Time{})
+	deadline := time.Now().Add(config.Config.Stream.MaxConnectionDuration)
+	if principal.Claims != nil && principal.Claims.ExpiresAt != nil && principal.Claims.ExpiresAt.Before(deadline) {
+		deadline = principal.Claims.ExpiresAt.Time
+	}
+	end := time.NewTimer(time.Until(deadline))
+	defer end.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, "retry: 5000\n\n")
+	if reset {
+		// The client missed events that can not be replayed and reloads its view
+		_, _ = fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		if err := writeStreamEvent(w, event); err != nil {
+			return
+		}
+	}
+	if len(replay) > 0 {
+		lastId = replay[len(replay)-1].Id
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(config.Config.Stream.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-end.C:
+			// EventSource reconnects with the Last-Event-ID and a fresh token
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		case event, open := <-subscriber.C:
+			if !open {
+				// Fell behind, the client resumes from the last event it got
+				return
+			}
+			// Take everything already buffered, the choice of the user is matched once per batch
+			batch := []events.Event{event}
+		drain:
+			for len(batch) < cap(subscriber.C) {
+				select {
+				case event, open = <-subscriber.C:
+					if !open {
+						break drain
+					}
+					batch = append(batch, event)
+				default:
+					break drain
+				}
+			}
+			filtered, err := server.DB.FilterEventsForUser(*user.Id, batch, ctx)
+			if err != nil {
+				log.Printf("Failed to filter the stream of user %d: %s", *user.Id, err.Error())
+				return
+			}
+			for _, event := range filtered {
+				// Replayed events can arrive again from the hub
+				if event.Id <= lastId {
+					continue
+				}
+				if err := writeStreamEvent(w, event); err != nil {
+					return
+				}
+				lastId = event.Id
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+			if !open {
+				return
+			}
+			heartbeat.Reset(config.Config.Stream.HeartbeatInterval)
+		}
+	}
+}
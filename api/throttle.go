@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// checkThrottle writes a 429 response with Retry-After if one of the keys is blocked in the scope.
+func (server Server) checkThrottle(w http.ResponseWriter, r *http.Request, scope string, keys []string) error {
+	retryAfter, err := server.DB.CheckThrottle(scope, keys, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrThrottled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too many failed attempts. Try again later.", http.StatusTooManyRequests)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return err
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt. Errors are only logged, the response is already decided.
+func (server Server) recordFailure(r *http.Request, scope string, keys []string) {
+	if err := server.DB.RecordFailure(scope, keys, r.Context()); err != nil {
+		log.Printf("Failed to record failed %s attempt: %s", scope, err.Error())
+	}
+}
+
+// recordSuccess resets the failed attempts of the keys.
+func (server Server) recordSuccess(r *http.Request, scope string, keys []string) {
+	if err := server.DB.RecordSuccess(scope, keys, r.Context()); err != nil {
+		log.Printf("Failed to reset failed %s attempts: %s", scope, err.Error())
+	}
+}
+
+// Get accounts and ip addresses with failed attempts (admin)
+// (GET /lockouts)
+func (server Server) GetLockouts(w http.ResponseWriter, r *http.Request, params gen.GetLockoutsParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if !authorize(w, user, authz.LockoutsManage) {
+		return
+	}
+	resp, err := server.DB.GetLockouts(params.All != nil && *params.All, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Clear the failed attempts of an account or ip address (admin)
+// (DELETE /lockouts/{lockoutId})
+func (server Server) DeleteLockoutsLockoutId(w http.ResponseWriter, r *http.Request, lockoutId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if !authorize(w, user, authz.LockoutsManage) {
+		return
+	}
+	err := server.DB.ClearLockout(lockoutId, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrLockoutNotFound) {
+			http.Error(w, "Lockout not found.", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			log.Print(err.Error())
+		}
+		return
+	}
+	server.audit(r, "lockout.clear", "lockout", lockoutId, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// isPartialTokenError reports if an error means the partial token of a login is invalid or expired.
+func isPartialTokenError(err error) bool {
+	var jwterr *jwt.ValidationError
+	return errors.As(err, &jwterr) ||
+		errors.Is(err, dbModels.ErrInvalidToken) ||
+		errors.Is(err, dbModels.ErrUserNotFound) ||
+		errors.Is(err, dbModels.ErrUnknownSigningKey)
+}
+
+// Finish a login with a TOTP or recovery code
+// (POST /login/totp)
+func (server Server) PostLoginTotp(w http.ResponseWriter, r *http.Request) {
+	var body gen.PostLoginTotpJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.PartialToken == "" || body.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userId, err := db.PartialTokenUserId(body.PartialToken)
+	if err != nil {
+		http.Error(w, "Login expired. Please log in again.", http.StatusUnauthorized)
+		return
+	}
+	throttleKeys := []string{"user:" + strconv.Itoa(userId), "ip:" + clientIP(r)}
+	if server.checkThrottle(w, r, "totp", throttleKeys) != nil {
+		return
+	}
+
+	tokens, user, recoveryCodes, err := server.DB.CompleteLogin(body.PartialToken, body.Code, r.UserAgent(), clientIP(r), r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrInvalidSecondFactor) {
+			server.recordFailure(r, "totp", throttleKeys)
+			http.Error(w, "Wrong code.", http.StatusUnauthorized)
+		} else if errors.Is(err, dbModels.ErrTOTPNotEnabled) {
+			http.Error(w, "TOTP is not set up. Enroll with POST /login/totp/enroll first.", http.StatusForbidden)
+		} else if isPartialTokenError(err) {
+			http.Error(w, "Login expired. Please log in again.", http.StatusUnauthorized)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.recordSuccess(r, "totp", throttleKeys[:1])
+
+	csrfToken := setSessionCookies(w, r, tokens)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(loginResponse{
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresAt:     tokens.ExpiresAt,
+		User:          user,
+		RecoveryCodes: recoveryCodes,
+		CsrfToken:     csrfToken,
+	})
+}
+
+// Set up TOTP during a login that requires a second factor
+// (POST /login/totp/enroll)
+func (server Server) PostLoginTotpEnroll(w http.ResponseWriter, r *http.Request) {
+	var body gen.PostLoginTotpEnrollJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.PartialToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	enrollment, err := server.DB.StartTOTPEnrollmentWithPartialToken(body.PartialToken, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrTOTPEnabled) {
+			http.Error(w, "TOTP is already set up.", http.StatusConflict)
+		} else if isPartialTokenError(err) {
+			http.Error(w, "Login expired. Please log in again.", http.StatusUnauthorized)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(enrollment)
+}
+
+// Get the TOTP status of the current user
+// (GET /user/totp)
+func (server Server) GetUserTotp(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	status, err := server.DB.GetTOTPStatus(user, r.Context())
+	if err != nil {
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// Start the TOTP enrollment of the current user
+// (POST /user/totp)
+func (server Server) PostUserTotp(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	enrollment, err := server.DB.StartTOTPEnrollment(*user.Id, user.Name, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrTOTPEnabled) {
+			http.Error(w, "TOTP is already set up.", http.StatusConflict)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(enrollment)
+}
+
+// Confirm the TOTP enrollment with a first code
+// (POST /user/totp/confirm)
+func (server Server) PostUserTotpConfirm(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	var body gen.PostUserTotpConfirmJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	codes, err := server.DB.ConfirmTOTP(*user.Id, body.Code, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrInvalidSecondFactor) {
+			http.Error(w, "Wrong code.", http.StatusUnprocessableEntity)
+		} else if errors.Is(err, dbModels.ErrTOTPEnabled) {
+			http.Error(w, "TOTP is already set up.", http.StatusConflict)
+		} else if errors.Is(err, dbModels.ErrTOTPNotEnabled) {
+			http.Error(w, "No TOTP enrollment started.", http.StatusNotFound)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.audit(r, "totp.enable", "user", *user.Id, nil)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(gen.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// verifyCurrentSecondFactor checks the code the current user sent to change the TOTP settings. Wrong codes are throttled like logins.
+func (server Server) verifyCurrentSecondFactor(w http.ResponseWriter, r *http.Request, userId int, code string) error {
+	throttleKeys := []string{"user:" + strconv.Itoa(userId)}
+	if err := server.checkThrottle(w, r, "totp", throttleKeys); err != nil {
+		return err
+	}
+	err := server.DB.VerifySecondFactor(userId, code, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrInvalidSecondFactor) {
+			server.recordFailure(r, "totp", throttleKeys)
+			http.Error(w, "Wrong code.", http.StatusForbidden)
+		} else if errors.Is(err, dbModels.ErrTOTPNotEnabled) {
+			http.Error(w, "TOTP is not enabled.", http.StatusNotFound)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return err
+	}
+	server.recordSuccess(r, "totp", throttleKeys)
+	return nil
+}
+
+// Disable TOTP for the current user
+// (DELETE /user/totp)
+func (server Server) DeleteUserTotp(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	var body gen.DeleteUserTotpJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	status, err := server.DB.GetTOTPStatus(user, r.Context())
+	if err != nil {
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if status.Required {
+		http.Error(w, "Your role requires a second factor.", http.StatusForbidden)
+		return
+	}
+	if server.verifyCurrentSecondFactor(w, r, *user.Id, body.Code) != nil {
+		return
+	}
+	err = server.DB.DisableTOTP(*user.Id, r.Context())
+	if err != nil {
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	server.audit(r, "totp.disable", "user", *user.Id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Replace the recovery codes of the current user
+// (POST /user/totp/recoveryCodes)
+func (server Server) PostUserTotpRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	var body gen.PostUserTotpRecoveryCodesJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if server.verifyCurrentSecondFactor(w, r, *user.Id, body.Code) != nil {
+		return
+	}
+	codes, err := server.DB.RegenerateRecoveryCodes(*user.Id, r.Context())
+	if err != nil {
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	server.audit(r, "totp.recoveryCodes.regenerate", "user", *user.Id, nil)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(gen.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// Reset the TOTP of a user who lost the device and the recovery codes (admin)
+// (DELETE /users/{userId}/totp)
+func (server Server) DeleteUsersUserIdTotp(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if !authorize(w, user, authz.CredentialsManage) {
+		return
+	}
+	err := server.DB.DisableTOTP(userId, r.Context())
+	if err != nil {
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	server.audit(r, "totp.reset", "user", userId, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,14 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
 )
 
 func (server Server) GetUntisClasses(w http.ResponseWriter, r *http.Request) {
-	_, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	if _, ok := requirePrincipal(w, r); !ok {
 		return
 	}
 	classes, err := server.DB.GetClasses(r.Context())
@@ -24,8 +25,7 @@ func (server Server) GetUntisClasses(w http.ResponseWriter, r *http.Request) {
 }
 
 func (server Server) GetUntisRooms(w http.ResponseWriter, r *http.Request) {
-	_, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	if _, ok := requirePrincipal(w, r); !ok {
 		return
 	}
 	rooms, err := server.DB.GetRooms(r.Context())
@@ -40,8 +40,7 @@ func (server Server) GetUntisRooms(w http.ResponseWriter, r *http.Request) {
 }
 
 func (server Server) GetUntisSubjects(w http.ResponseWriter, r *http.Request) {
-	_, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	if _, ok := requirePrincipal(w, r); !ok {
 		return
 	}
 	subjects, err := server.DB.GetSubjects(r.Context())
@@ -55,8 +54,7 @@ func (server Server) GetUntisSubjects(w http.ResponseWriter, r *http.Request) {
 }
 
 func (server Server) GetUntisTeachers(w http.ResponseWriter, r *http.Request) {
-	_, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	if _, ok := requirePrincipal(w, r); !ok {
 		return
 	}
 	teachers, err := server.DB.GetTeachers(r.Context())
@@ -69,15 +67,16 @@ func (server Server) GetUntisTeachers(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(teachers)
 }
 func (server Server) GetUntisFetch(w http.ResponseWriter, r *http.Request) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+	user := principal.User
+	if !authorize(w, user, authz.UntisFetch) {
 		return
 	}
-	err = server.DB.FetchTeachers(r.Context())
+	server.audit(r, "untis.fetch", "", 0, nil)
+	err := server.DB.FetchTeachers(r.Context())
 	if err != nil {
 		http.Error(w, "Internal server error.", http.StatusInternalServerError)
 		return
@@ -100,3 +99,24 @@ func (server Server) GetUntisFetch(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+// Get the recent runs of the scheduled Untis sync (admin)
+// (GET /untis/sync)
+func (server Server) GetUntisSync(w http.ResponseWriter, r *http.Request, params gen.GetUntisSyncParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	if !authorize(w, principal.User, authz.UntisFetch) {
+		return
+	}
+	runs, err := server.DB.GetSyncRuns(params, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(runs)
+}
@@ -1,17 +1,19 @@
 package api
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	untisApiStructs "github.com/Mr-Comand/goUntisAPI/structs"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/dataCollectors/untisDataCollectors"
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/policy"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -19,16 +21,16 @@ import (
 // Get all users
 // (GET /users)
 func (server Server) GetUsers(w http.ResponseWriter, r *http.Request) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+	user := principal.User
+	if !authorize(w, user, authz.UsersRead) {
 		return
 	}
 	var resp []gen.User
-	resp, err = server.DB.GetUsers(r.Context())
+	resp, err := server.DB.GetUsers(r.Context())
 	if err != nil {
 		http.Error(w, "Internal server error.", http.StatusInternalServerError)
 		log.Print(err.Error())
@@ -39,18 +41,30 @@ func (server Server) GetUsers(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// writePolicyError writes the violated password or username rules as a 422 response.
+func writePolicyError(w http.ResponseWriter, policyErr *policy.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(policyErr.ToGen())
+}
+
 // Create a new user
 // (POST /users)
 func (server Server) PostUsers(w http.ResponseWriter, r *http.Request) {
+	// Only set if sign up is disabled and the user was created by someone with the users.create permission
+	var creatorRole string
 	if !config.Config.CanSignUp {
-		user, _, err := server.isLoggedIn(w, r)
-		if err != nil {
+		// POST /users is public, the middleware only sets the principal if a valid token was sent.
+		principal, ok := requirePrincipal(w, r)
+		if !ok {
 			return
 		}
-		if user.Role == nil || *user.Role != gen.UserRoleAdmin {
+		user := principal.User
+		if !authz.Can(userRole(user), authz.UsersCreate) {
 			http.Error(w, "SignUp is currently disabled on this server.", http.StatusForbidden)
 			return
 		}
+		creatorRole = userRole(user)
 	}
 	var userWithPW gen.PostUsersJSONRequestBody
 	err := json.NewDecoder(r.Body).Decode(&userWithPW)
@@ -68,14 +82,20 @@ func (server Server) PostUsers(w http.ResponseWriter, r *http.Request) {
 		(*userWithPW.UserData.Role !=
 			gen.UserRole("student")) &&
 		(*userWithPW.UserData.Role !=
-			gen.UserRole("teacher")) {
-
-		http.Error(w, "Invalid role", http.StatusBadRequest)
-		return
+			gen.UserRole("teacher")) &&
+		(*userWithPW.UserData.Role !=
+			gen.UserRoleGuardian) {
+		// Other roles can only be given by creators, and only if they do not grant more than the creator has
+		role := string(*userWithPW.UserData.Role)
+		if creatorRole == "" || !authz.RoleExists(role) || (authz.Can(role, authz.All) && !authz.Can(creatorRole, authz.All)) {
+			http.Error(w, "Invalid role", http.StatusBadRequest)
+			return
+		}
 	}
 	resp, err := server.DB.CreateUser(*userWithPW.UserData, *userWithPW.Password, r.Context())
 
 	if err != nil {
+		var policyErr *policy.Error
 		var pgErr pgdriver.Error
 		if errors.As(err, &pgErr) {
 			switch pgErr.Field('C') {
@@ -85,10 +105,8 @@ func (server Server) PostUsers(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "Internal server error."+pgErr.Field('C'), http.StatusInternalServerError)
 				log.Printf("Error type: %T, Details: %s", err, err.Error())
 			}
-		} else if errors.Is(err, dbModels.ErrUsernameNotMachRequirements) {
-			http.Error(w, " Username dose not match the requirements.", http.StatusUnprocessableEntity)
-		} else if errors.Is(err, dbModels.ErrPasswordNotMachRequirements) {
-			http.Error(w, " Password dose not match the requirements.", http.StatusUnprocessableEntity)
+		} else if errors.As(err, &policyErr) {
+			writePolicyError(w, policyErr)
 		} else if errors.Is(err, bcrypt.ErrPasswordTooLong) {
 			http.Error(w, "Password to long.", http.StatusUnprocessableEntity)
 		} else {
@@ -97,6 +115,7 @@ func (server Server) PostUsers(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	server.audit(r, "user.create", "user", *resp.Id, map[string]interface{}{"name": resp.Name, "role": userRole(resp)})
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(resp)
@@ -105,31 +124,35 @@ func (server Server) PostUsers(w http.ResponseWriter, r *http.Request) {
 // Delete a user by ID
 // (DELETE /users/{userId})
 func (server Server) DeleteUsersUserId(w http.ResponseWriter, r *http.Request, userId int) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+	user := principal.User
+	if !authorize(w, user, authz.UsersDelete) {
 		return
 	}
-	err = server.DB.DeleteUserByID(userId, r.Context())
+	err := server.DB.DeleteUserByID(userId, r.Context())
 	if err != nil {
 		http.Error(w, "Internal server error.", http.StatusInternalServerError)
 		return
 	}
+	server.audit(r, "user.delete", "user", userId, nil)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // Get a user by ID
 // (GET /users/{userId})
 func (server Server) GetUsersUserId(w http.ResponseWriter, r *http.Request, userId int) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		http.Error(w, "Insufficient permission.", http.StatusForbidden)
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.UsersRead, userId) {
 		return
 	}
 	resp, err := server.DB.GetUserByID(userId, r.Context())
@@ -149,26 +172,41 @@ func (server Server) GetUsersUserId(w http.ResponseWriter, r *http.Request, user
 // Update a user by ID
 // (PUT /users/{userId})
 func (server Server) PutUsersUserId(w http.ResponseWriter, r *http.Request, userId int) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
+	user := principal.User
 	if userId == -1 {
 		userId = *user.Id
 	}
-	if user.Role == nil || *user.Role != gen.UserRoleAdmin {
-		if userId != *user.Id {
-			http.Error(w, "Insufficient permission.", http.StatusForbidden)
-			return
-		}
+	if !authorizeFor(w, user, authz.UsersWrite, userId) {
+		return
 	}
 	var JSONRequestBody gen.PutUsersUserIdJSONRequestBody
-	err = json.NewDecoder(r.Body).Decode(&JSONRequestBody)
+	err := json.NewDecoder(r.Body).Decode(&JSONRequestBody)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		log.Println(err.Error())
 		return
 	}
+	if userId != *user.Id {
+		user, err = server.DB.GetUserByID(userId, r.Context())
+		if err != nil {
+			if errors.Is(err, dbModels.ErrUserNotFound) {
+				http.Error(w, "User not found.", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	diff := map[string]interface{}{}
+	oldName, oldEmail := user.Name, user.Email
+	var oldDefaultChoice *int
+	if user.DefaultChoice != nil {
+		oldDefaultChoice = user.DefaultChoice.Id
+	}
 	update_user := false
 	if JSONRequestBody.Name != nil {
 		update_user = true
@@ -185,9 +223,20 @@ func (server Server) PutUsersUserId(w http.ResponseWriter, r *http.Request, user
 	if update_user {
 		err = server.DB.UpdateUser(user, r.Context())
 		if err != nil {
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			var policyErr *policy.Error
+			if errors.As(err, &policyErr) {
+				writePolicyError(w, policyErr)
+			} else {
+				http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			}
 			return
 		}
+		auditChange(diff, "name", oldName, user.Name)
+		auditChange(diff, "email", oldEmail, user.Email)
+		auditChange(diff, "defaultChoice", oldDefaultChoice, user.DefaultChoice.Id)
+		if len(diff) > 0 {
+			server.audit(r, "user.update", "user", userId, diff)
+		}
 	}
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(JSONRequestBody)
@@ -196,10 +245,11 @@ func (server Server) PutUsersUserId(w http.ResponseWriter, r *http.Request, user
 // Returns currently logged in user.
 // (GET /currentUser)
 func (server Server) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
-	user, _, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
+	user := principal.User
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(user)
@@ -208,22 +258,36 @@ func (server Server) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 // Update the untisAcc of the active user
 // (PUT /user/untisAcc)
 func (server Server) PutUserUntisAcc(w http.ResponseWriter, r *http.Request) {
-	user, claims, err := server.isLoggedIn(w, r)
-	if err != nil {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
+	user, claims := principal.User, principal.Claims
 	var JSONRequestBody gen.PutUserUntisAccJSONBody
-	err = json.NewDecoder(r.Body).Decode(&JSONRequestBody)
+	err := json.NewDecoder(r.Body).Decode(&JSONRequestBody)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		log.Println(err.Error())
 		return
 	}
-	key, err := base64.StdEncoding.DecodeString(claims.CryptoKey)
+	if JSONRequestBody.UserName == nil || JSONRequestBody.Forename == nil || JSONRequestBody.Surname == nil || JSONRequestBody.UntisPWD == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	key, err := claims.UnwrapCryptoKey()
 	if err != nil {
+		if errors.Is(err, dbModels.ErrNoCryptoKey) {
+			http.Error(w, "Token carries no crypto key. Please log in again.", http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, "Internal server error.", http.StatusInternalServerError)
 		return
 	}
+	// Every attempt is a login at the WebUntis of the school, which blocks the school after too many failures.
+	throttleKeys := []string{"user:" + strconv.Itoa(*user.Id), "ip:" + clientIP(r)}
+	if server.checkThrottle(w, r, "untis", throttleKeys) != nil {
+		return
+	}
 	err = server.DB.UpdateUntisLogin(user, *JSONRequestBody.UserName, *JSONRequestBody.Forename, *JSONRequestBody.Surname, *JSONRequestBody.UntisPWD, key, r.Context())
 	if err != nil {
 		if errors.Is(err, dbModels.ErrUserNotFound) {
@@ -231,12 +295,14 @@ func (server Server) PutUserUntisAcc(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if errors.Is(err, untisDataCollectors.ErrStudentNotFound) {
+			server.recordFailure(r, "untis", throttleKeys)
 			http.Error(w, "Student not found!", http.StatusNotFound)
 			return
 		}
 		var rpcError *untisApiStructs.RPCError
 		if errors.As(err, &rpcError) {
 			if rpcError.Code == -8504 {
+				server.recordFailure(r, "untis", throttleKeys)
 				http.Error(w, "Bad Untis credentials.", http.StatusUnprocessableEntity)
 			} else {
 				http.Error(w, "Internal server error.", http.StatusInternalServerError)
@@ -247,6 +313,77 @@ func (server Server) PutUserUntisAcc(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error.", http.StatusInternalServerError)
 		return
 	}
+	server.recordSuccess(r, "untis", throttleKeys[:1])
+	server.audit(r, "untis.credentials.update", "user", *user.Id, map[string]interface{}{"untisName": *JSONRequestBody.UserName})
+	w.WriteHeader(http.StatusOK)
+
+}
+
+// Get the untisAcc status of the active user
+// (GET /user/untisAcc)
+func (server Server) GetUserUntisAcc(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	resp, err := server.DB.GetUntisAccStatus(*user.Id, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
 
+// Change the password of the active user
+// (PUT /user/password)
+func (server Server) PutUserPassword(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user, claims := principal.User, principal.Claims
+	var JSONRequestBody gen.PutUserPasswordJSONBody
+	err := json.NewDecoder(r.Body).Decode(&JSONRequestBody)
+	if err != nil || JSONRequestBody.OldPassword == nil || JSONRequestBody.NewPassword == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	throttleKeys := []string{"user:" + strconv.Itoa(*user.Id)}
+	if server.checkThrottle(w, r, "password", throttleKeys) != nil {
+		return
+	}
+	tokens, updatedUser, err := server.DB.ChangePassword(*user.Id, *JSONRequestBody.OldPassword, *JSONRequestBody.NewPassword, claims.ID, r.Context())
+	if err != nil {
+		var policyErr *policy.Error
+		if errors.Is(err, dbModels.ErrInvalidPassword) {
+			server.recordFailure(r, "password", throttleKeys)
+			http.Error(w, "Wrong password.", http.StatusForbidden)
+		} else if errors.As(err, &policyErr) {
+			writePolicyError(w, policyErr)
+		} else if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			http.Error(w, "Password to long.", http.StatusUnprocessableEntity)
+		} else if errors.Is(err, dbModels.ErrSessionRevoked) {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		} else {
+			log.Printf("Error type: %T, Details: %s", err, err.Error())
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		}
+		return
+	}
+	server.recordSuccess(r, "password", throttleKeys)
+	server.audit(r, "password.change", "user", *user.Id, nil)
+	csrfToken := setSessionCookies(w, r, tokens)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(loginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		User:         updatedUser,
+		CsrfToken:    csrfToken,
+	})
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
 )
@@ -18,17 +19,14 @@ type UntisProviderSettings struct {
 	Choice *gen.Choice `json:"Choice,omitempty"`
 }
 
+// UntisView returns the lessons of the user. With fetchLesson the lessons are fetched from Untis first,
+// depending on Timetables.LessonSource with the service account and/or the Untis login of the user,
+// which needs the claims of its own token. Without, the stored lessons are returned.
 func (server Server) UntisView(user gen.User, claims *db.Claims, providerSettings UntisProviderSettings, startdate time.Time, enddate time.Time, fetchLesson bool, ctx context.Context) ([]gen.Lesson, error) {
-	_, untis_pwd, err := server.DB.GetUntisLoginByCryptoKey(claims.CryptoKey, user, ctx)
-	if err != nil {
-		return nil, err
-	}
 	if fetchLesson {
-		for _, classId := range *user.Classes {
-			err = server.DB.FetchLesson(user, untis_pwd, classId, startdate, enddate, ctx)
-			if err != nil {
-				fmt.Println("Failed to FetchLesson: " + err.Error())
-			}
+		err := server.fetchLessons(user, claims, startdate, enddate, ctx)
+		if err != nil {
+			return nil, err
 		}
 	}
 	lessonFilter := dbModels.LessonFilter{
@@ -45,3 +43,49 @@ func (server Server) UntisView(user gen.User, claims *db.Claims, providerSetting
 	}
 	return resp, nil
 }
+
+// fetchLessons fetches the lessons of the user from Untis according to Timetables.LessonSource.
+// Only a missing Untis login in the "personal" source is an error, failed fetches are logged.
+func (server Server) fetchLessons(user gen.User, claims *db.Claims, startdate time.Time, enddate time.Time, ctx context.Context) error {
+	var classes []int
+	if user.Classes != nil {
+		classes = *user.Classes
+	}
+	source := config.Config.Timetables.LessonSource
+	if source != "personal" {
+		for _, classId := range classes {
+			err := server.DB.FetchClassLessons(classId, startdate, enddate, ctx)
+			if err != nil {
+				fmt.Println("Failed to FetchClassLessons: " + err.Error())
+			}
+		}
+		if source == "serviceAccount" || claims == nil {
+			return nil
+		}
+	}
+	if claims == nil {
+		return dbModels.ErrNoCryptoKey
+	}
+	_, untis_pwd, err := server.DB.GetUntisLoginByClaims(claims, user, ctx)
+	if err != nil {
+		if source == "hybrid" {
+			// The class timetables are enough for users that did not link Untis
+			return nil
+		}
+		return err
+	}
+	if source == "hybrid" {
+		err = server.DB.FetchOwnLessons(user, untis_pwd, startdate, enddate, ctx)
+		if err != nil {
+			fmt.Println("Failed to FetchOwnLessons: " + err.Error())
+		}
+		return nil
+	}
+	for _, classId := range classes {
+		err = server.DB.FetchLesson(user, untis_pwd, classId, startdate, enddate, ctx)
+		if err != nil {
+			fmt.Println("Failed to FetchLesson: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -2,16 +2,21 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
 )
 
 type ViewOutput struct {
-	Untis     interface{} `json:untis`
-	Cafeteria interface{} `json:cafeteria`
-	Week      interface{} `json:week`
+	Untis     interface{} `json:"untis"`
+	Cafeteria interface{} `json:"cafeteria"`
+	Week      interface{} `json:"week"`
 }
 
 // Get events by a user
@@ -30,11 +35,11 @@ func (server Server) PutView(w http.ResponseWriter, r *http.Request, params gen.
 		http.Error(w, "Invalid request body.", http.StatusBadRequest)
 		return
 	}
-	user, claims, err := server.isLoggedIn(w, r)
-	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
+	user, claims := principal.User, principal.Claims
 	startdate := time.Now().Truncate(24 * time.Hour)
 	if params.Date != nil && !params.Date.IsZero() {
 		startdate = params.Date.Time
@@ -46,7 +51,11 @@ func (server Server) PutView(w http.ResponseWriter, r *http.Request, params gen.
 	for _, provider := range body.Provider {
 		switch provider {
 		case gen.PutViewJSONBodyProviderUntis:
-			lessons, err := server.UntisView(user, claims, *body.Untis, startdate, enddate, true, r.Context())
+			var settings UntisProviderSettings
+			if body.Untis != nil {
+				settings.Choice = body.Untis.Choice
+			}
+			lessons, err := server.UntisView(user, claims, settings, startdate, enddate, true, r.Context())
 			if err != nil {
 				http.Error(w, "Internal server error."+err.Error(), http.StatusInternalServerError)
 				return
@@ -82,7 +91,10 @@ func (server Server) PutView(w http.ResponseWriter, r *http.Request, params gen.
 // Get events of a week by a user
 // (PUT /view/user/{userId})
 func (server Server) PutViewUserUserId(w http.ResponseWriter, r *http.Request, userId int, params gen.PutViewUserUserIdParams) {
-	if *params.Duration < 1 || *params.Duration > 7 {
+	if params.Duration == nil {
+		params.Duration = new(int)
+		*params.Duration = 1
+	} else if *params.Duration < 1 || *params.Duration > 7 {
 		http.Error(w, "Invalid request body. Duration out of bounce.", http.StatusBadRequest)
 		return
 	}
@@ -92,14 +104,40 @@ func (server Server) PutViewUserUserId(w http.ResponseWriter, r *http.Request, u
 		http.Error(w, "Invalid request body.", http.StatusBadRequest)
 		return
 	}
-	user, _, err := server.isLoggedIn(w, r)
-	if *user.Id != userId && *user.Role != gen.UserRoleAdmin {
-		http.Error(w, "Unauthorized to access this data.", http.StatusUnauthorized)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
 		return
 	}
-	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
-		return
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	// Fetching the view of someone else with its Untis login needs the crypto key of the owner, so it is
+	// only fetched with the service account (Timetables.LessonSource) or read from the stored lessons.
+	target, claims := user, principal.Claims
+	if userId != *user.Id {
+		if !authz.CanFor(userRole(user), authz.ViewRead, false) {
+			isGuardian, err := server.DB.IsGuardianOf(*user.Id, userId, r.Context())
+			if err != nil {
+				http.Error(w, "Internal server error.", http.StatusInternalServerError)
+				log.Print(err.Error())
+				return
+			}
+			if !isGuardian {
+				http.Error(w, "Insufficient permission.", http.StatusForbidden)
+				return
+			}
+		}
+		target, err = server.DB.GetUserByID(userId, r.Context())
+		if err != nil {
+			if errors.Is(err, dbModels.ErrUserNotFound) {
+				http.Error(w, "User not found.", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+		claims = nil
 	}
 	startdate := time.Now().Truncate(24 * time.Hour)
 	if params.Date != nil && !params.Date.IsZero() {
@@ -112,8 +150,15 @@ func (server Server) PutViewUserUserId(w http.ResponseWriter, r *http.Request, u
 	for _, provider := range body.Provider {
 		switch provider {
 		case gen.PutViewUserUserIdJSONBodyProviderUntis:
-			lessons, err := server.UntisView(user, nil, *body.Untis, startdate, enddate, true, r.Context())
-			http.Error(w, "Internal server error."+err.Error(), http.StatusInternalServerError)
+			var settings UntisProviderSettings
+			if body.Untis != nil {
+				settings.Choice = body.Untis.Choice
+			}
+			lessons, err := server.UntisView(target, claims, settings, startdate, enddate, claims != nil || config.Config.Timetables.LessonSource != "personal", r.Context())
+			if err != nil {
+				http.Error(w, "Internal server error."+err.Error(), http.StatusInternalServerError)
+				return
+			}
 			out.Untis = lessons
 		case gen.PutViewUserUserIdJSONBodyProviderCafeteria:
 			menus, err := server.CafeteriaView(startdate, *params.Duration, r.Context())
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// validWebhookURL reports if the server may post events to the url. Addresses inside the network of the server are
+// rejected when the delivery connects, as the name can resolve to another address later.
+func validWebhookURL(rawURL string) bool {
+	webhookURL, err := url.Parse(rawURL)
+	if err != nil || webhookURL.Host == "" || webhookURL.User != nil {
+		return false
+	}
+	return webhookURL.Scheme == "https" || (webhookURL.Scheme == "http" && config.Config.Webhooks.AllowPrivateNetworks)
+}
+
+// webhookEvents checks the subscribed events and removes duplicates. ok is false for an empty or unknown event.
+func webhookEvents(events []gen.WebhookEvent) (result []string, ok bool) {
+	knownEvents := []gen.WebhookEvent{gen.WebhookEventLessonChanged, gen.WebhookEventMenuUpdated, gen.WebhookEventWeekSubtitleCreated}
+	for _, event := range events {
+		if !slices.Contains(knownEvents, event) {
+			return nil, false
+		}
+		if !slices.Contains(result, string(event)) {
+			result = append(result, string(event))
+		}
+	}
+	return result, len(result) > 0
+}
+
+// webhookError writes the response of an error of the webhook functions of the database.
+func webhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dbModels.ErrWebhookNotFound):
+		http.Error(w, "Webhook not found.", http.StatusNotFound)
+	case errors.Is(err, dbModels.ErrWebhookDeliveryNotFound):
+		http.Error(w, "Webhook delivery not found.", http.StatusNotFound)
+	default:
+		log.Printf("Error type: %T, Details: %s", err, err.Error())
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+	}
+}
+
+// Get the webhooks of a user
+// (GET /users/{userId}/webhooks)
+func (server Server) GetUsersUserIdWebhooks(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.WebhooksManage, userId) {
+		return
+	}
+	webhooks, err := server.DB.GetWebhooks(userId, r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		log.Print(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(webhooks)
+}
+
+// Register a webhook for a user
+// (POST /users/{userId}/webhooks)
+func (server Server) PostUsersUserIdWebhooks(w http.ResponseWriter, r *http.Request, userId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.WebhooksManage, userId) {
+		return
+	}
+	if !config.Config.Webhooks.Enabled {
+		http.Error(w, "Webhooks are disabled.", http.StatusNotFound)
+		return
+	}
+	var body gen.PostUsersUserIdWebhooksJSONBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validWebhookURL(body.Url) {
+		http.Error(w, "The url has to be a https url.", http.StatusBadRequest)
+		return
+	}
+	events, ok := webhookEvents(body.Events)
+	if !ok {
+		http.Error(w, "Subscribe to at least one of lesson.changed, menu.updated and week.subtitle.created.", http.StatusBadRequest)
+		return
+	}
+	webhook := dbModels.Webhook{UserId: userId, URL: body.Url, Events: events}
+	if body.Description != nil {
+		webhook.Description = *body.Description
+	}
+	newWebhook, err := server.DB.CreateWebhook(webhook, r.Context())
+	if err != nil {
+		if errors.Is(err, dbModels.ErrUserNotFound) {
+			http.Error(w, "User not found.", http.StatusNotFound)
+		} else if errors.Is(err, dbModels.ErrWebhookLimitReached) {
+			http.Error(w, "The maximum number of webhooks is reached. Delete a webhook first.", http.StatusConflict)
+		} else {
+			webhookError(w, err)
+		}
+		return
+	}
+	server.audit(r, "webhook.create", "user", userId, map[string]interface{}{"webhookId": newWebhook.Webhook.Id, "url": body.Url, "events": events})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(newWebhook)
+}
+
+// Change a webhook
+// (PUT /users/{userId}/webhooks/{webhookId})
+func (server Server) PutUsersUserIdWebhooksWebhookId(w http.ResponseWriter, r *http.Request, userId int, webhookId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.WebhooksManage, userId) {
+		return
+	}
+	var body gen.PutUsersUserIdWebhooksWebhookIdJSONRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Url != nil && !validWebhookURL(*body.Url) {
+		http.Error(w, "The url has to be a https url.", http.StatusBadRequest)
+		return
+	}
+	if body.Events != nil {
+		events, ok := webhookEvents(*body.Events)
+		if !ok {
+			http.Error(w, "Subscribe to at least one of lesson.changed, menu.updated and week.subtitle.created.", http.StatusBadRequest)
+			return
+		}
+		deduplicated := make([]gen.WebhookEvent, len(events))
+		for i, event := range events {
+			deduplicated[i] = gen.WebhookEvent(event)
+		}
+		body.Events = &deduplicated
+	}
+	webhook, err := server.DB.UpdateWebhook(userId, webhookId, gen.PutUsersUserIdWebhooksWebhookIdJSONBody(body), r.Context())
+	if err != nil {
+		webhookError(w, err)
+		return
+	}
+	if body.Url != nil {
+		server.audit(r, "webhook.update", "user", userId, map[string]interface{}{"webhookId": webhookId, "url": *body.Url})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(webhook)
+}
+
+// Delete a webhook and its queued deliveries
+// (DELETE /users/{userId}/webhooks/{webhookId})
+func (server Server) DeleteUsersUserIdWebhooksWebhookId(w http.ResponseWriter, r *http.Request, userId int, webhookId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.WebhooksManage, userId) {
+		return
+	}
+	err := server.DB.DeleteWebhook(userId, webhookId, r.Context())
+	if err != nil {
+		webhookError(w, err)
+		return
+	}
+	server.audit(r, "webhook.delete", "user", userId, map[string]interface{}{"webhookId": webhookId})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Replace the signing secret of a webhook
+// (POST /users/{userId}/webhooks/{webhookId}/secret)
+func (server Server) PostUsersUserIdWebhooksWebhookIdSecret(w http.ResponseWriter, r *http.Request, userId int, webhookId int) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.WebhooksManage, userId) {
+		return
+	}
+	newWebhook, err := server.DB.RotateWebhookSecret(userId, webhookId, r.Context())
+	if err != nil {
+		webhookError(w, err)
+		return
+	}
+	server.audit(r, "webhook.secret.rotate", "user", userId, map[string]interface{}{"webhookId": webhookId})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(newWebhook)
+}
+
+// Get the deliveries of a webhook, status=dead for the dead letters
+// (GET /users/{userId}/webhooks/{webhookId}/deliveries)
+func (server Server) GetUsersUserIdWebhooksWebhookIdDeliveries(w http.ResponseWriter, r *http.Request, userId int, webhookId int, params gen.GetUsersUserIdWebhooksWebhookIdDeliveriesParams) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.WebhooksManage, userId) {
+		return
+	}
+	deliveries, err := server.DB.GetWebhookDeliveries(userId, webhookId, params, r.Context())
+	if err != nil {
+		webhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(deliveries)
+}
+
+// Queue a dead or failed delivery again
+// (POST /users/{userId}/webhooks/{webhookId}/deliveries/{deliveryId}/retry)
+func (server Server) PostUsersUserIdWebhooksWebhookIdDeliveriesDeliveryIdRetry(w http.ResponseWriter, r *http.Request, userId int, webhookId int, deliveryId int64) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	user := principal.User
+	if userId == -1 {
+		userId = *user.Id
+	}
+	if !authorizeFor(w, user, authz.WebhooksManage, userId) {
+		return
+	}
+	delivery, err := server.DB.RetryWebhookDelivery(userId, webhookId, deliveryId, r.Context())
+	if err != nil {
+		webhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,109 @@
+// Package authz maps roles to the permissions they grant.
+//
+// The permissions the operations need are mapped to their routes in the api package, where the
+// permission middleware checks them. Permissions ending in ":own" only grant the action on the
+// account of the user itself, the handlers check the owner.
+package authz
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+)
+
+type Permission string
+
+const (
+	UsersRead Permission = "users.read"
+	// Create users while sign up is disabled, and with roles other than student, teacher and guardian.
+	UsersCreate Permission = "users.create"
+	UsersWrite  Permission = "users.write"
+	UsersDelete Permission = "users.delete"
+	// Sessions, personal access tokens, app passwords, linked identities, password reset and TOTP of an account.
+	CredentialsManage Permission = "credentials.manage"
+	ChoicesRead       Permission = "choices.read"
+	ChoicesWrite      Permission = "choices.write"
+	ViewRead          Permission = "view.read"
+	UntisRead         Permission = "untis.read"
+	UntisFetch        Permission = "untis.fetch"
+	LockoutsManage    Permission = "lockouts.manage"
+	AuditRead         Permission = "audit.read"
+	// Invite and revoke guardians of an account, and accept invites as guardian.
+	GuardiansManage Permission = "guardians.manage"
+	// Push subscriptions and notification preferences of an account.
+	NotificationsManage Permission = "notifications.manage"
+	// Webhooks of an account. Webhooks of a user with the global permission receive the changes of all lessons.
+	WebhooksManage Permission = "webhooks.manage"
+	// Calendar feeds of an account.
+	CalendarsManage Permission = "calendars.manage"
+
+	// All grants every permission.
+	All Permission = "*"
+)
+
+// Own returns the permission limited to the account of the user.
+func (permission Permission) Own() Permission {
+	return permission + ":own"
+}
+
+// defaultRoles are the built in roles. Config.Roles can extend them or add custom roles.
+var defaultRoles = map[string][]Permission{
+	"admin": {All},
+	"teacher": {
+		UsersRead.Own(), UsersWrite.Own(), CredentialsManage.Own(),
+		ChoicesRead.Own(), ChoicesWrite.Own(), ViewRead.Own(), UntisRead, GuardiansManage.Own(), NotificationsManage.Own(),
+		WebhooksManage.Own(), CalendarsManage.Own(),
+	},
+	"student": {
+		UsersRead.Own(), UsersWrite.Own(), CredentialsManage.Own(),
+		ChoicesRead.Own(), ChoicesWrite.Own(), ViewRead.Own(), UntisRead, GuardiansManage.Own(), NotificationsManage.Own(),
+		WebhooksManage.Own(), CalendarsManage.Own(),
+	},
+	// Parents. They read the view of the students that invited them, see ViewRead in PutViewUserUserId.
+	"guardian": {
+		UsersRead.Own(), UsersWrite.Own(), CredentialsManage.Own(), ViewRead.Own(), UntisRead, GuardiansManage.Own(),
+	},
+}
+
+// permissions returns the permissions of a role. Roles configured in Config.Roles replace the built in role of the same name.
+func permissions(role string) []Permission {
+	if configured, ok := config.Config.Roles[strings.ToLower(role)]; ok {
+		permissions := make([]Permission, len(configured))
+		for i, permission := range configured {
+			permissions[i] = Permission(permission)
+		}
+		return permissions
+	}
+	return defaultRoles[role]
+}
+
+// RoleExists reports if a role is built in or configured.
+func RoleExists(role string) bool {
+	if _, ok := defaultRoles[role]; ok {
+		return true
+	}
+	_, ok := config.Config.Roles[strings.ToLower(role)]
+	return ok
+}
+
+// Can reports if the role grants the permission on every account.
+func Can(role string, permission Permission) bool {
+	granted := permissions(role)
+	return slices.Contains(granted, All) || slices.Contains(granted, permission)
+}
+
+// CanFor reports if the role grants the permission on an account. own is set if the account is the one of the user.
+func CanFor(role string, permission Permission, own bool) bool {
+	return Can(role, permission) || (own && slices.Contains(permissions(role), permission.Own()))
+}
+
+// CanAny reports if the role grants any of the permissions, at least on the own account.
+func CanAny(role string, permissions []Permission) bool {
+	for _, permission := range permissions {
+		if CanFor(role, permission, true) {
+			return true
+		}
+	}
+	return false
+}
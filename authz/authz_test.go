@@ -0,0 +1,105 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{"admin", AuditRead, true},
+		{"admin", UsersDelete, true},
+		{"teacher", UntisRead, true},
+		{"teacher", UsersRead, false},
+		{"student", ChoicesWrite, false},
+		{"guardian", ViewRead, false},
+		{"", UntisRead, false},
+		{"unknown", UntisRead, false},
+	}
+	for _, test := range tests {
+		if got := Can(test.role, test.permission); got != test.want {
+			t.Errorf("Can(%q, %q) = %v, want %v", test.role, test.permission, got, test.want)
+		}
+	}
+}
+
+func TestCanFor(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		own        bool
+		want       bool
+	}{
+		{"student", ChoicesWrite, true, true},
+		{"student", ChoicesWrite, false, false},
+		{"teacher", UntisRead, false, true},
+		{"guardian", ChoicesRead, true, false},
+		{"admin", CredentialsManage, false, true},
+		{"", UsersRead, true, false},
+	}
+	for _, test := range tests {
+		if got := CanFor(test.role, test.permission, test.own); got != test.want {
+			t.Errorf("CanFor(%q, %q, %v) = %v, want %v", test.role, test.permission, test.own, got, test.want)
+		}
+	}
+}
+
+func TestCanAny(t *testing.T) {
+	tests := []struct {
+		role        string
+		permissions []Permission
+		want        bool
+	}{
+		{"student", []Permission{AuditRead, ViewRead}, true},
+		{"student", []Permission{AuditRead, LockoutsManage}, false},
+		{"guardian", []Permission{GuardiansManage}, true},
+		{"guardian", []Permission{WebhooksManage}, false},
+		{"admin", []Permission{UntisFetch}, true},
+		{"admin", nil, false},
+		{"", []Permission{UntisRead}, false},
+	}
+	for _, test := range tests {
+		if got := CanAny(test.role, test.permissions); got != test.want {
+			t.Errorf("CanAny(%q, %v) = %v, want %v", test.role, test.permissions, got, test.want)
+		}
+	}
+}
+
+func TestConfiguredRoles(t *testing.T) {
+	roles := config.Config.Roles
+	t.Cleanup(func() { config.Config.Roles = roles })
+	config.Config.Roles = map[string][]string{
+		"student":   {string(ViewRead.Own())},
+		"secretary": {string(UsersRead), string(LockoutsManage)},
+	}
+
+	tests := []struct {
+		role       string
+		permission Permission
+		own        bool
+		want       bool
+	}{
+		// A configured role replaces the built in role of the same name
+		{"student", ViewRead, true, true},
+		{"student", ChoicesWrite, true, false},
+		{"secretary", UsersRead, false, true},
+		{"secretary", LockoutsManage, false, true},
+		{"secretary", UsersDelete, false, false},
+		// Roles are configured in lower case, as the config keys are case insensitive
+		{"Secretary", UsersRead, false, true},
+		{"teacher", UntisRead, false, true},
+	}
+	for _, test := range tests {
+		if got := CanFor(test.role, test.permission, test.own); got != test.want {
+			t.Errorf("CanFor(%q, %q, %v) = %v, want %v", test.role, test.permission, test.own, got, test.want)
+		}
+	}
+	if !RoleExists("secretary") || RoleExists("janitor") {
+		t.Errorf("RoleExists does not include the configured roles")
+	}
+}
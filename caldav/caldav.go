@@ -0,0 +1,308 @@
+// Package caldav reads the requests of a read-only CalDAV server and writes its multistatus responses
+// (RFC 4918 WebDAV, RFC 4791 CalDAV and RFC 6578 collection synchronization).
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+)
+
+// Root elements of the request bodies.
+var (
+	PropFind         = xml.Name{Space: NamespaceDAV, Local: "propfind"}
+	CalendarQuery    = xml.Name{Space: NamespaceCalDAV, Local: "calendar-query"}
+	CalendarMultiget = xml.Name{Space: NamespaceCalDAV, Local: "calendar-multiget"}
+	SyncCollection   = xml.Name{Space: NamespaceDAV, Local: "sync-collection"}
+)
+
+// Properties and preconditions of the server.
+var (
+	ResourceType                  = xml.Name{Space: NamespaceDAV, Local: "resourcetype"}
+	DisplayName                   = xml.Name{Space: NamespaceDAV, Local: "displayname"}
+	GetETag                       = xml.Name{Space: NamespaceDAV, Local: "getetag"}
+	GetContentType                = xml.Name{Space: NamespaceDAV, Local: "getcontenttype"}
+	CurrentUserPrincipal          = xml.Name{Space: NamespaceDAV, Local: "current-user-principal"}
+	PrincipalURL                  = xml.Name{Space: NamespaceDAV, Local: "principal-URL"}
+	Owner                         = xml.Name{Space: NamespaceDAV, Local: "owner"}
+	CurrentUserPrivilegeSet       = xml.Name{Space: NamespaceDAV, Local: "current-user-privilege-set"}
+	SupportedReportSet            = xml.Name{Space: NamespaceDAV, Local: "supported-report-set"}
+	SyncToken                     = xml.Name{Space: NamespaceDAV, Local: "sync-token"}
+	ValidSyncToken                = xml.Name{Space: NamespaceDAV, Local: "valid-sync-token"}
+	SupportedReport               = xml.Name{Space: NamespaceDAV, Local: "supported-report"}
+	CalendarHomeSet               = xml.Name{Space: NamespaceCalDAV, Local: "calendar-home-set"}
+	CalendarData                  = xml.Name{Space: NamespaceCalDAV, Local: "calendar-data"}
+	SupportedCalendarComponentSet = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-component-set"}
+	GetCTag                       = xml.Name{Space: NamespaceCalendarServer, Local: "getctag"}
+)
+
+// ErrInvalidRequest is returned for a body that is not a well formed request.
+var ErrInvalidRequest = errors.New("caldav: invalid request body")
+
+// maxRequestSize limits the request bodies that are read.
+const maxRequestSize = 1 << 20
+
+// Request is the body of a PROPFIND or REPORT request.
+type Request struct {
+	// Root element of the body, PropFind for a PROPFIND without body.
+	Type xml.Name
+	// Return all properties, for allprop or a PROPFIND without body.
+	AllProp bool
+	// Return only the names of the properties.
+	PropName bool
+	// The requested properties.
+	Props []xml.Name
+	// Component the filter of a calendar-query asks for, "" to match every component.
+	Component string
+	// time-range of a calendar-query, zero for an open range.
+	Start time.Time
+	End   time.Time
+	// Resources of a calendar-multiget.
+	Hrefs []string
+	// sync-token of a sync-collection, "" for the initial synchronization.
+	SyncToken string
+}
+
+type element struct {
+	XMLName xml.Name
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+type compFilter struct {
+	Name       string      `xml:"name,attr"`
+	TimeRange  *timeRange  `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilter *compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type requestBody struct {
+	XMLName  xml.Name
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     *struct {
+		Props []element `xml:",any"`
+	} `xml:"DAV: prop"`
+	Filter *struct {
+		CompFilter *compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+	Hrefs     []string `xml:"DAV: href"`
+	SyncToken string   `xml:"DAV: sync-token"`
+}
+
+// ParseRequest reads the body of a PROPFIND or REPORT request.
+func ParseRequest(body io.Reader) (Request, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxRequestSize))
+	if err != nil {
+		return Request{}, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Request{Type: PropFind, AllProp: true}, nil
+	}
+	var parsed requestBody
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return Request{}, fmt.Errorf("%w: %s", ErrInvalidRequest, err.Error())
+	}
+	request := Request{
+		Type:      parsed.XMLName,
+		AllProp:   parsed.AllProp != nil,
+		PropName:  parsed.PropName != nil,
+		Hrefs:     parsed.Hrefs,
+		SyncToken: strings.TrimSpace(parsed.SyncToken),
+	}
+	if parsed.Prop != nil {
+		for _, prop := range parsed.Prop.Props {
+			request.Props = append(request.Props, prop.XMLName)
+		}
+	} else if request.Type == PropFind && !request.PropName {
+		request.AllProp = true
+	}
+	if parsed.Filter != nil && parsed.Filter.CompFilter != nil {
+		calendar := parsed.Filter.CompFilter
+		if calendar.Name != "VCALENDAR" {
+			request.Component = calendar.Name
+		} else if calendar.CompFilter != nil {
+			request.Component = calendar.CompFilter.Name
+			if calendar.CompFilter.TimeRange != nil {
+				request.Start, err = parseTime(calendar.CompFilter.TimeRange.Start)
+				if err != nil {
+					return Request{}, err
+				}
+				request.End, err = parseTime(calendar.CompFilter.TimeRange.End)
+				if err != nil {
+					return Request{}, err
+				}
+			}
+		}
+	}
+	return request, nil
+}
+
+// parseTime parses a UTC date-time of a time-range, "" is the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse("20060102T150405Z", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid time %q", ErrInvalidRequest, value)
+	}
+	return parsed, nil
+}
+
+// MatchesEvent reports if an event from start to end matches the filter of a calendar-query.
+func (request Request) MatchesEvent(start time.Time, end time.Time) bool {
+	if request.Component != "" && request.Component != "VEVENT" {
+		return false
+	}
+	return (request.End.IsZero() || start.Before(request.End)) && (request.Start.IsZero() || end.After(request.Start))
+}
+
+// Wants reports if the request asks for the property by name. allprop does not include expensive properties like
+// calendar-data, so add those only if they are wanted.
+func (request Request) Wants(name xml.Name) bool {
+	for _, prop := range request.Props {
+		if prop == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Properties of a resource by name. The values are XML fragments, see Href and Text.
+type Properties map[xml.Name]string
+
+// Response is one resource of a multistatus response.
+type Response struct {
+	Href     string
+	Found    Properties
+	NotFound []xml.Name
+	// Status of a resource without properties, e.g. http.StatusNotFound for an unknown href of a calendar-multiget.
+	Status int
+}
+
+// Response returns the properties of a resource the request asks for.
+func (request Request) Response(href string, properties Properties) Response {
+	response := Response{Href: href, Found: Properties{}}
+	switch {
+	case request.PropName:
+		for name := range properties {
+			response.Found[name] = ""
+		}
+	case request.AllProp:
+		response.Found = properties
+	default:
+		for _, name := range request.Props {
+			if value, ok := properties[name]; ok {
+				response.Found[name] = value
+			} else {
+				response.NotFound = append(response.NotFound, name)
+			}
+		}
+	}
+	return response
+}
+
+// prefixes of the namespaces declared on the root element of the responses.
+var prefixes = map[string]string{NamespaceDAV: "D", NamespaceCalDAV: "C", NamespaceCalendarServer: "CS"}
+
+const namespaces = " xmlns:D=\"" + NamespaceDAV + "\" xmlns:C=\"" + NamespaceCalDAV + "\" xmlns:CS=\"" + NamespaceCalendarServer + "\""
+
+// Element returns an element with the inner XML. Elements of other namespaces declare their namespace themselves.
+func Element(name xml.Name, inner string) string {
+	tag, declaration := name.Local, ""
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "X:" + name.Local
+		declaration = " xmlns:X=\"" + Text(name.Space) + "\""
+	}
+	if inner == "" {
+		return "<" + tag + declaration + "/>"
+	}
+	return "<" + tag + declaration + ">" + inner + "</" + tag + ">"
+}
+
+// Href returns a DAV:href element.
+func Href(href string) string {
+	return Element(xml.Name{Space: NamespaceDAV, Local: "href"}, Text(href))
+}
+
+// Text escapes a text value.
+func Text(text string) string {
+	var buffer strings.Builder
+	_ = xml.EscapeText(&buffer, []byte(text))
+	return buffer.String()
+}
+
+func status(code int) string {
+	return Element(xml.Name{Space: NamespaceDAV, Local: "status"}, fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code)))
+}
+
+// WriteMultistatus writes a 207 Multi-Status response. syncToken is only written for a sync-collection report.
+func WriteMultistatus(w http.ResponseWriter, responses []Response, syncToken string) {
+	var builder strings.Builder
+	builder.WriteString(xml.Header)
+	builder.WriteString("<D:multistatus" + namespaces + ">")
+	for _, response := range responses {
+		builder.WriteString("<D:response>")
+		builder.WriteString(Href(response.Href))
+		if response.Status != 0 {
+			builder.WriteString(status(response.Status))
+		} else {
+			if len(response.Found) > 0 || len(response.NotFound) == 0 {
+				builder.WriteString("<D:propstat><D:prop>")
+				names := make([]xml.Name, 0, len(response.Found))
+				for name := range response.Found {
+					names = append(names, name)
+				}
+				sort.Slice(names, func(i, j int) bool {
+					if names[i].Space != names[j].Space {
+						return names[i].Space < names[j].Space
+					}
+					return names[i].Local < names[j].Local
+				})
+				for _, name := range names {
+					builder.WriteString(Element(name, response.Found[name]))
+				}
+				builder.WriteString("</D:prop>" + status(http.StatusOK) + "</D:propstat>")
+			}
+			if len(response.NotFound) > 0 {
+				builder.WriteString("<D:propstat><D:prop>")
+				for _, name := range response.NotFound {
+					builder.WriteString(Element(name, ""))
+				}
+				builder.WriteString("</D:prop>" + status(http.StatusNotFound) + "</D:propstat>")
+			}
+		}
+		builder.WriteString("</D:response>")
+	}
+	if syncToken != "" {
+		builder.WriteString(Element(SyncToken, Text(syncToken)))
+	}
+	builder.WriteString("</D:multistatus>")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, builder.String())
+}
+
+// WriteError writes an error response with a failed precondition, e.g. ValidSyncToken.
+func WriteError(w http.ResponseWriter, code int, condition xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = io.WriteString(w, xml.Header+"<D:error"+namespaces+">"+Element(condition, "")+"</D:error>")
+}
@@ -84,15 +84,13 @@ func (untisClient UntisClient) GetClasses() ([]structs.Class, error) {
 	}
 	return classes, nil
 }
-func (untisClient UntisClient) GetLessonsByClass(class dbModels.Class, startDate time.Time, endDate time.Time) ([]structs.Period, error) {
-	err := untisClient.reAuthenticate()
-	if err != nil {
-		return nil, err
-	}
+
+// timetableRequest returns the request for the timetable of an element. Without endDate a week is requested.
+func timetableRequest(elementType int, elementId int, startDate time.Time, endDate time.Time) structs.GetTimetableRequest {
 	body := structs.GetTimetableRequest{
 		Element: structs.GetTimetableRequestElement{
-			Type: 1,
-			Id:   class.Id,
+			Type: elementType,
+			Id:   elementId,
 		},
 		ShowBooking:   true,
 		ShowInfo:      true,
@@ -106,9 +104,18 @@ func (untisClient UntisClient) GetLessonsByClass(class dbModels.Class, startDate
 	if endDate.IsZero() {
 		body.EndDate, _ = strconv.Atoi(startDate.AddDate(0, 0, 7).Local().Format("20060102"))
 	} else {
-		body.StartDate, _ = strconv.Atoi(startDate.Local().Format("20060102"))
+		body.EndDate, _ = strconv.Atoi(endDate.Local().Format("20060102"))
+	}
+	return body
+}
+
+// GetLessonsByClass returns the timetable of a class, fetched with the service account.
+func (untisClient UntisClient) GetLessonsByClass(class dbModels.Class, startDate time.Time, endDate time.Time) ([]structs.Period, error) {
+	err := untisClient.reAuthenticate()
+	if err != nil {
+		return nil, err
 	}
-	lessons, err := untisClient.staticClient.GetTimetable(body)
+	lessons, err := untisClient.staticClient.GetTimetable(timetableRequest(1, class.Id, startDate, endDate))
 	if err != nil {
 		return nil, err
 	}
@@ -124,26 +131,26 @@ func (untisClient UntisClient) GetLessonsByStudent(UntisName string, untisPWD st
 		dynamicClient.Logout()
 		return nil, err
 	}
-	body := structs.GetTimetableRequest{
-		Element: structs.GetTimetableRequestElement{
-			Type: 1,
-			Id:   classId,
-		},
-		ShowBooking:   true,
-		ShowInfo:      true,
-		ShowLsText:    true,
-		ShowSubstText: true,
-		ShowLsNumber:  true,
-	}
-	if !startDate.IsZero() {
-		body.StartDate, _ = strconv.Atoi(startDate.Local().Format("20060102"))
+	lessons, err := dynamicClient.GetTimetable(timetableRequest(1, classId, startDate, endDate))
+	if err != nil {
+		dynamicClient.Logout()
+		return nil, err
 	}
-	if endDate.IsZero() {
-		body.EndDate, _ = strconv.Atoi(startDate.AddDate(0, 0, 7).Local().Format("20060102"))
-	} else {
-		body.EndDate, _ = strconv.Atoi(endDate.Local().Format("20060102"))
+	dynamicClient.Logout()
+	return lessons, nil
+}
+
+// GetOwnLessons returns the personal timetable of the Untis user, including courses outside its class.
+func (untisClient UntisClient) GetOwnLessons(UntisName string, untisPWD string, startDate time.Time, endDate time.Time) ([]structs.Period, error) {
+	dynamicClient := untisApi.NewClient(untisClient.dynamicClient.ApiConfig, log.Default(), untisApi.DEBUG, true)
+	dynamicClient.ApiConfig.User = UntisName
+	dynamicClient.ApiConfig.Password = untisPWD
+	err := dynamicClient.Authenticate()
+	if err != nil {
+		dynamicClient.Logout()
+		return nil, err
 	}
-	lessons, err := dynamicClient.GetTimetable(body)
+	lessons, err := dynamicClient.GetTimetable(timetableRequest(dynamicClient.PersonType, dynamicClient.PersonID, startDate, endDate))
 	if err != nil {
 		dynamicClient.Logout()
 		return nil, err
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+)
+
+// AccessTokenPrefix marks personal access tokens, so they can be told apart from JWTs.
+const AccessTokenPrefix = "tmf_pat_"
+
+// accessTokenKeyPurpose is the hkdf info used to derive the key that seals the crypto key of a personal access token.
+const accessTokenKeyPurpose = "tmf personal access token crypto key"
+
+// accessTokenDisplayLength is the number of characters of a token that are stored to recognize it.
+const accessTokenDisplayLength = len(AccessTokenPrefix) + 6
+
+// IsAccessToken reports if a bearer token is a personal access token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// accessTokenSessionId is the id used in the claims of a request authenticated with a personal access token.
+func accessTokenSessionId(tokenId int) string {
+	return "pat:" + strconv.Itoa(tokenId)
+}
+
+// accessTokenAssociatedData binds the sealed crypto key to the user and the token entry.
+func accessTokenAssociatedData(userId int, tokenId int) []byte {
+	return []byte(fmt.Sprintf("%d|%d", userId, tokenId))
+}
+
+// CreateAccessToken creates a personal access token for a user and returns the token. It is only available here, only its hash is stored.
+// If cryptoKey is nil the token can not access the Untis account of the user.
+func (database *Database) CreateAccessToken(userId int, name string, scopes []string, expiresAt time.Time, cryptoKey []byte, ctx context.Context) (string, gen.PersonalAccessToken, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", gen.PersonalAccessToken{}, err
+	}
+	token := AccessTokenPrefix + secret
+	entry := dbModels.PersonalAccessToken{
+		UserId:    userId,
+		Name:      name,
+		Prefix:    token[:accessTokenDisplayLength],
+		TokenHash: generateSHA256Hash(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().Model(&entry).Returning("*").Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if cryptoKey == nil {
+			return nil
+		}
+		sealingKey, err := tokenKey(token, accessTokenKeyPurpose)
+		if err != nil {
+			return err
+		}
+		entry.EncryptedKey, err = sealKey(sealingKey, cryptoKey, accessTokenAssociatedData(userId, entry.Id))
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewUpdate().Model(&entry).Column("encryptedKey").WherePK().Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return "", gen.PersonalAccessToken{}, err
+	}
+	return token, entry.ToGen(), nil
+}
+
+// VerifyAccessToken checks a personal access token and returns its user and claims equivalent to the ones of a session.
+// The crypto key is wrapped into the claims like for session tokens, so handlers can use it the same way.
+func (database *Database) VerifyAccessToken(token string, ip string, ctx context.Context) (gen.User, *Claims, error) {
+	entry := dbModels.PersonalAccessToken{}
+	err := database.DB.NewSelect().
+		Model(&entry).
+		Where("\"tokenHash\" = ?", generateSHA256Hash(token)).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return gen.User{}, nil, dbModels.ErrAccessTokenInvalid
+		}
+		return gen.User{}, nil, err
+	}
+	if !entry.ExpiresAt.IsZero() && entry.ExpiresAt.Before(time.Now()) {
+		return gen.User{}, nil, dbModels.ErrAccessTokenInvalid
+	}
+	user := dbModels.User{Id: entry.UserId}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return gen.User{}, nil, err
+	}
+
+	claims := &Claims{
+		UserId:        user.Id,
+		Name:          user.Name,
+		Role:          user.Role,
+		Scopes:        entry.Scopes,
+		AccessTokenId: entry.Id,
+	}
+	claims.ID = accessTokenSessionId(entry.Id)
+	if entry.EncryptedKey != "" {
+		sealingKey, err := tokenKey(token, accessTokenKeyPurpose)
+		if err != nil {
+			return gen.User{}, nil, err
+		}
+		cryptoKey, err := openKey(sealingKey, entry.EncryptedKey, accessTokenAssociatedData(user.Id, entry.Id))
+		if err != nil {
+			return gen.User{}, nil, err
+		}
+		claims.WrappedKey, err = wrapCryptoKey(cryptoKey, user.Id, claims.ID)
+		if err != nil {
+			return gen.User{}, nil, err
+		}
+	}
+
+	if time.Since(entry.LastUsedAt) > lastSeenResolution || entry.LastUsedIP != ip {
+		entry.LastUsedAt = time.Now()
+		entry.LastUsedIP = ip
+		_, err = database.DB.NewUpdate().
+			Model(&entry).
+			Column("last_used_at", "last_used_ip").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return gen.User{}, nil, err
+		}
+	}
+	return user.ToGen(), claims, nil
+}
+
+// GetAccessTokens returns the personal access tokens of a user, newest first.
+func (database *Database) GetAccessTokens(userId int, ctx context.Context) ([]gen.PersonalAccessToken, error) {
+	var entries []dbModels.PersonalAccessToken
+	err := database.DB.NewSelect().
+		Model(&entries).
+		Where("\"userId\" = ?", userId).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]gen.PersonalAccessToken, len(entries))
+	for i, entry := range entries {
+		tokens[i] = entry.ToGen()
+	}
+	return tokens, nil
+}
+
+// RevokeAccessToken deletes a personal access token of a user.
+func (database *Database) RevokeAccessToken(userId int, tokenId int, ctx context.Context) error {
+	res, err := database.DB.NewDelete().
+		Model((*dbModels.PersonalAccessToken)(nil)).
+		Where("id = ?", tokenId).
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return dbModels.ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+// revokeAccessTokens deletes all personal access tokens of a user.
+func revokeAccessTokens(idb bun.IDB, userId int, ctx context.Context) error {
+	_, err := idb.NewDelete().
+		Model((*dbModels.PersonalAccessToken)(nil)).
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	return err
+}
+
+// dropAccessTokenKeys removes the crypto key from the personal access tokens of a user after the key changed.
+// The tokens stay valid, but can no longer access the Untis account until they are recreated.
+func dropAccessTokenKeys(idb bun.IDB, userId int, ctx context.Context) error {
+	_, err := idb.NewUpdate().
+		Model((*dbModels.PersonalAccessToken)(nil)).
+		Set("\"encryptedKey\" = NULL").
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	return err
+}
+
+// HasScope reports if the claims allow an action that needs the scope.
+// Sessions are not limited by scopes. The admin scope includes all other scopes.
+func (claims *Claims) HasScope(scope string) bool {
+	if claims == nil || claims.AccessTokenId == 0 {
+		return true
+	}
+	return slices.Contains(claims.Scopes, scope) || slices.Contains(claims.Scopes, string(gen.TokenScopeAdmin))
+}
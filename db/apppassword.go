@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+)
+
+// appPasswordDisplayLength is the number of characters of an app password that are stored to recognize it.
+const appPasswordDisplayLength = 4
+
+// CreateAppPassword creates an app password for the CalDAV clients of a user. The password is only returned here.
+func (database *Database) CreateAppPassword(userId int, name string, ctx context.Context) (gen.NewAppPassword, error) {
+	password, err := randomToken(24)
+	if err != nil {
+		return gen.NewAppPassword{}, err
+	}
+	entry := dbModels.AppPassword{
+		UserId:       userId,
+		Name:         name,
+		Prefix:       password[:appPasswordDisplayLength],
+		PasswordHash: generateSHA256Hash(password),
+	}
+	user := dbModels.User{Id: userId}
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Lock the user, so two passwords at once can not both pass the limit
+		err := tx.NewSelect().Model(&user).Column("id", "name").WherePK().For("UPDATE").Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrUserNotFound
+			}
+			return err
+		}
+		count, err := tx.NewSelect().Model((*dbModels.AppPassword)(nil)).Where("\"userId\" = ?", userId).Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count >= config.Config.CalDAV.MaxAppPasswordsPerUser {
+			return dbModels.ErrAppPasswordLimitReached
+		}
+		_, err = tx.NewInsert().Model(&entry).Returning("*").Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return gen.NewAppPassword{}, err
+	}
+	newPassword := gen.NewAppPassword{AppPassword: entry.ToGen(), Password: password, Username: user.Name}
+	if config.Config.CalDAV.URL != "" {
+		newPassword.Url = &config.Config.CalDAV.URL
+	}
+	return newPassword, nil
+}
+
+// GetAppPasswords returns the app passwords of a user.
+func (database *Database) GetAppPasswords(userId int, ctx context.Context) ([]gen.AppPassword, error) {
+	var passwords []dbModels.AppPassword
+	err := database.DB.NewSelect().Model(&passwords).Where("\"userId\" = ?", userId).Order("id").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genPasswords := make([]gen.AppPassword, len(passwords))
+	for i, password := range passwords {
+		genPasswords[i] = password.ToGen()
+	}
+	return genPasswords, nil
+}
+
+// DeleteAppPassword revokes an app password of a user.
+func (database *Database) DeleteAppPassword(userId int, passwordId int, ctx context.Context) error {
+	result, err := database.DB.NewDelete().
+		Model((*dbModels.AppPassword)(nil)).
+		Where("id = ?", passwordId).
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return dbModels.ErrAppPasswordNotFound
+	}
+	return nil
+}
+
+// revokeAppPasswords deletes all app passwords of a user.
+func revokeAppPasswords(idb bun.IDB, userId int, ctx context.Context) error {
+	_, err := idb.NewDelete().
+		Model((*dbModels.AppPassword)(nil)).
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	return err
+}
+
+// VerifyAppPassword checks the user name and app password of a CalDAV client and returns the user.
+func (database *Database) VerifyAppPassword(username string, password string, ip string, ctx context.Context) (gen.User, error) {
+	entry := dbModels.AppPassword{}
+	err := database.DB.NewSelect().
+		Model(&entry).
+		Where("\"passwordHash\" = ?", generateSHA256Hash(password)).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return gen.User{}, dbModels.ErrAppPasswordInvalid
+		}
+		return gen.User{}, err
+	}
+	user := dbModels.User{Id: entry.UserId}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return gen.User{}, err
+	}
+	// The password alone identifies the user, the name has to match anyway so a leaked password is not enough
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(user.Name)), []byte(strings.ToLower(username))) != 1 {
+		return gen.User{}, dbModels.ErrAppPasswordInvalid
+	}
+
+	if time.Since(entry.LastUsedAt) > lastSeenResolution || entry.LastUsedIP != ip {
+		entry.LastUsedAt = time.Now()
+		entry.LastUsedIP = ip
+		_, err = database.DB.NewUpdate().
+			Model(&entry).
+			Column("last_used_at", "last_used_ip").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return gen.User{}, err
+		}
+	}
+	return user.ToGen(), nil
+}
@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/hkdf"
+)
+
+// auditKeyPurpose is the info of the key the audit hashes are keyed with.
+const auditKeyPurpose = "tmf audit log"
+
+// AuditEvent is an action to record in the audit log.
+type AuditEvent struct {
+	// 0 for actions of the server itself, e.g. the retention.
+	ActorId    int
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetId   string
+	IP         string
+	// Changed fields as {"field": {"old": ..., "new": ...}}. Never put secrets in here.
+	Diff map[string]interface{}
+}
+
+// auditKey derives the key of the audit hashes from Crypto.KeyEncryptionKey, so the chain can not be rewritten
+// with access to the database alone.
+func auditKey() []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, keyEncryptionKey(), nil, []byte(auditKeyPurpose)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// auditHash returns the HMAC of an entry. It covers every field except the id and the hash itself.
+func auditHash(entry dbModels.AuditEntry) string {
+	data, err := json.Marshal([]interface{}{
+		entry.PrevHash,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		entry.ActorId,
+		entry.ActorName,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetId,
+		entry.IP,
+		entry.Diff,
+	})
+	if err != nil {
+		panic(err)
+	}
+	mac := hmac.New(sha256.New, auditKey())
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RecordAudit appends an entry to the audit log and chains it to the previous entry.
+func (database *Database) RecordAudit(event AuditEvent, ctx context.Context) error {
+	if len(event.Diff) == 0 {
+		event.Diff = nil
+	}
+	return database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return recordAudit(tx, event, ctx)
+	})
+}
+
+// lockAuditLog serializes the writers of the audit log until the end of the transaction.
+// Two entries with the same predecessor would fork the chain.
+func lockAuditLog(tx bun.Tx, ctx context.Context) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('audit_log'))")
+	return err
+}
+
+func recordAudit(tx bun.Tx, event AuditEvent, ctx context.Context) error {
+	if err := lockAuditLog(tx, ctx); err != nil {
+		return err
+	}
+	var last dbModels.AuditEntry
+	err := tx.NewSelect().Model(&last).Column("hash").Order("id DESC").Limit(1).Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	entry := dbModels.AuditEntry{
+		// Postgres stores microseconds, the hash has to match the stored value.
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		ActorId:    event.ActorId,
+		ActorName:  event.ActorName,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetId:   event.TargetId,
+		IP:         event.IP,
+		Diff:       event.Diff,
+		PrevHash:   last.Hash,
+	}
+	entry.Hash = auditHash(entry)
+	_, err = tx.NewInsert().Model(&entry).Exec(ctx)
+	return err
+}
+
+// GetAuditLog returns the entries matching the filter, newest first.
+func (database *Database) GetAuditLog(filter gen.GetAuditParams, ctx context.Context) ([]gen.AuditEntry, error) {
+	var entries []dbModels.AuditEntry
+	query := database.DB.NewSelect().Model(&entries)
+	if filter.ActorId != nil {
+		query.Where("\"actorId\" = ?", *filter.ActorId)
+	}
+	if filter.Action != nil {
+		if prefix, ok := strings.CutSuffix(*filter.Action, "*"); ok {
+			query.Where("\"action\" LIKE ?", strings.NewReplacer("%", "\\%", "_", "\\_").Replace(prefix)+"%")
+		} else {
+			query.Where("\"action\" = ?", *filter.Action)
+		}
+	}
+	if filter.TargetType != nil {
+		query.Where("\"targetType\" = ?", *filter.TargetType)
+	}
+	if filter.TargetId != nil {
+		query.Where("\"targetId\" = ?", *filter.TargetId)
+	}
+	if filter.From != nil {
+		query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query.Where("created_at < ?", *filter.To)
+	}
+	limit := 100
+	if filter.Limit != nil && *filter.Limit > 0 && *filter.Limit <= 1000 {
+		limit = *filter.Limit
+	}
+	query.Limit(limit)
+	if filter.Offset != nil && *filter.Offset > 0 {
+		query.Offset(*filter.Offset)
+	}
+	err := query.Order("id DESC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genEntries := make([]gen.AuditEntry, len(entries))
+	for i, entry := range entries {
+		genEntries[i] = entry.ToGen()
+	}
+	return genEntries, nil
+}
+
+// VerifyAuditLog recomputes the hash chain. The chain starts at the oldest entry left by the retention,
+// the deleted entries before it have to be covered by an audit.prune entry.
+func (database *Database) VerifyAuditLog(ctx context.Context) (gen.AuditVerification, error) {
+	result := gen.AuditVerification{Valid: true}
+	var prevHash string
+	var lastId int64
+	var first *dbModels.AuditEntry
+	// gapCovered tells whether an audit.prune entry recorded the deletion of the predecessor of the first entry.
+	gapCovered := false
+	for {
+		var entries []dbModels.AuditEntry
+		err := database.DB.NewSelect().Model(&entries).Where("id > ?", lastId).Order("id ASC").Limit(1000).Scan(ctx)
+		if err != nil {
+			return result, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			if first == nil {
+				first = &entry
+				prevHash = entry.PrevHash
+				gapCovered = entry.PrevHash == ""
+			}
+			result.Checked++
+			if entry.PrevHash != prevHash || !hmac.Equal([]byte(auditHash(entry)), []byte(entry.Hash)) {
+				result.Valid = false
+				result.FirstInvalidId = &entry.Id
+				return result, nil
+			}
+			if entry.Action == "audit.prune" && entry.Diff["lastHash"] == first.PrevHash {
+				gapCovered = true
+			}
+			prevHash = entry.Hash
+			lastId = entry.Id
+		}
+	}
+	if !gapCovered {
+		result.Valid = false
+		result.FirstInvalidId = &first.Id
+	}
+	return result, nil
+}
+
+// PruneAuditLog deletes the entries older than Audit.Retention and records the deletion in the log.
+// Only the oldest entries are deleted, so the rest of the chain stays verifiable. The audit.prune entry
+// carries the hash of the last deleted entry, the predecessor of the oldest entry left.
+func (database *Database) PruneAuditLog(ctx context.Context) (int, error) {
+	if config.Config.Audit.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-config.Config.Audit.Retention)
+	var deleted int64
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := lockAuditLog(tx, ctx); err != nil {
+			return err
+		}
+		var last dbModels.AuditEntry
+		err := tx.NewSelect().Model(&last).Column("id", "hash").Where("created_at < ?", cutoff).Order("id DESC").Limit(1).Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		res, err := tx.NewDelete().Model((*dbModels.AuditEntry)(nil)).Where("id <= ?", last.Id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if deleted, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		return recordAudit(tx, AuditEvent{
+			Action: "audit.prune",
+			Diff: map[string]interface{}{
+				"deleted":  deleted,
+				"before":   cutoff.UTC().Format(time.RFC3339),
+				"lastHash": last.Hash,
+			},
+		}, ctx)
+	})
+	return int(deleted), err
+}
+
+// RunAuditRetention deletes expired audit entries every Audit.PruneInterval. It blocks, run it in a goroutine.
+func (database *Database) RunAuditRetention() {
+	if config.Config.Audit.Retention <= 0 || config.Config.Audit.PruneInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(config.Config.Audit.PruneInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := database.PruneAuditLog(context.Background()); err != nil {
+			log.Printf("Failed to prune the audit log: %s", err.Error())
+		}
+	}
+}
+
+// protectAuditLog makes the database reject updates of audit entries. Deletes stay possible for the retention.
+func (database *Database) protectAuditLog(ctx context.Context) error {
+	_, err := database.DB.ExecContext(ctx, `CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append only';
+END;
+$$ LANGUAGE plpgsql`)
+	if err != nil {
+		return err
+	}
+	_, err = database.DB.ExecContext(ctx, "DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log")
+	if err != nil {
+		return err
+	}
+	_, err = database.DB.ExecContext(ctx, "CREATE TRIGGER audit_log_append_only BEFORE UPDATE ON audit_log FOR EACH ROW EXECUTE FUNCTION audit_log_append_only()")
+	return err
+}
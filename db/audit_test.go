@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// recordAuditEvents records count entries and returns their ids.
+func recordAuditEvents(t *testing.T, database Database, count int) []int64 {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < count; i++ {
+		if err := database.RecordAudit(AuditEvent{ActorId: 1, Action: "user.update", TargetType: "user", TargetId: "2"}, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids []int64
+	if err := database.DB.NewSelect().Model((*dbModels.AuditEntry)(nil)).Column("id").Order("id ASC").Scan(ctx, &ids); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	recordAuditEvents(t, database, 3)
+
+	result, err := database.VerifyAuditLog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.Checked != 3 {
+		t.Errorf("VerifyAuditLog = %+v, want 3 valid entries", result)
+	}
+
+	// The hashes are keyed, a chain rewritten without the key does not verify
+	previous := config.Config.Crypto.KeyEncryptionKey
+	t.Cleanup(func() { config.Config.Crypto.KeyEncryptionKey = previous })
+	config.Config.Crypto.KeyEncryptionKey = "another key"
+	result, err = database.VerifyAuditLog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid {
+		t.Error("the audit log verified with another key")
+	}
+}
+
+func TestVerifyAuditLogAfterPrune(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	recordAuditEvents(t, database, 3)
+
+	previous := config.Config.Audit.Retention
+	t.Cleanup(func() { config.Config.Audit.Retention = previous })
+	config.Config.Audit.Retention = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	deleted, err := database.PruneAuditLog(ctx)
+	if err != nil || deleted != 3 {
+		t.Fatalf("PruneAuditLog = %d, %v, want 3 deleted entries", deleted, err)
+	}
+	recordAuditEvents(t, database, 1)
+
+	result, err := database.VerifyAuditLog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.Checked != 2 {
+		t.Errorf("VerifyAuditLog = %+v, want the prune entry and one more valid entry", result)
+	}
+}
+
+func TestVerifyAuditLogDeletedPrefix(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	ids := recordAuditEvents(t, database, 3)
+
+	// Deleting the oldest entries without audit.prune leaves a gap at the start of the chain
+	if _, err := database.DB.NewDelete().Model((*dbModels.AuditEntry)(nil)).Where("id = ?", ids[0]).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err := database.VerifyAuditLog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || result.FirstInvalidId == nil || *result.FirstInvalidId != ids[1] {
+		t.Errorf("VerifyAuditLog = %+v, want entry %d invalid", result, ids[1])
+	}
+}
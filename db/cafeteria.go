@@ -38,6 +38,7 @@ func (database *Database) FetchMenuForDate(startDate time.Time, days int, ctx co
 			log.Println("Error inserting new menus:", err)
 			return []gen.Menu{}, err
 		}
+		go database.emitMenuUpdates(changedMenus(nil, menus))
 
 		dbMenus = getFirstNMenus(menus, days)
 	} else if len(dbMenus) < days {
@@ -58,23 +59,60 @@ func (database *Database) FetchMenuForDate(startDate time.Time, days int, ctx co
 			log.Println("Error upserting menus:", err)
 			return []gen.Menu{}, err
 		}
+		go database.emitMenuUpdates(changedMenus(dbMenus, menus))
 		dbMenus = getFirstNMenus(menus, days)
 	}
 	// Convert db.Menu to gen.Menu in one pass
 	menus := make([]gen.Menu, len(dbMenus))
 	for i, dbMenu := range dbMenus {
-		menus[i] = gen.Menu{
-			Cookteam:    &dbMenu.Cookteam,
-			Date:        openapi_types.Date{Time: dbMenu.Date},
-			Dessert:     &dbMenu.Dessert,
-			Garnish:     &dbMenu.Garnish,
-			MainDish:    &dbMenu.MainDish,
-			MainDishVeg: &dbMenu.MainDishVeg,
-		}
+		menus[i] = menuToGen(dbMenu)
 	}
 	return menus, nil
 }
 
+func menuToGen(dbMenu dbModels.Menu) gen.Menu {
+	return gen.Menu{
+		Cookteam:    &dbMenu.Cookteam,
+		Date:        openapi_types.Date{Time: dbMenu.Date},
+		Dessert:     &dbMenu.Dessert,
+		Garnish:     &dbMenu.Garnish,
+		MainDish:    &dbMenu.MainDish,
+		MainDishVeg: &dbMenu.MainDishVeg,
+	}
+}
+
+// changedMenus returns the fetched menus that are new or differ from the stored ones.
+func changedMenus(stored []dbModels.Menu, fetched []dbModels.Menu) []dbModels.Menu {
+	storedByDate := make(map[string]dbModels.Menu, len(stored))
+	for _, menu := range stored {
+		storedByDate[menu.Date.Format("2006-01-02")] = menu
+	}
+	var changed []dbModels.Menu
+	for _, menu := range fetched {
+		old, ok := storedByDate[menu.Date.Format("2006-01-02")]
+		if ok && old.Cookteam == menu.Cookteam && old.Dessert == menu.Dessert && old.Garnish == menu.Garnish &&
+			old.MainDish == menu.MainDish && old.MainDishVeg == menu.MainDishVeg {
+			continue
+		}
+		changed = append(changed, menu)
+	}
+	return changed
+}
+
+// emitMenuUpdates emits a menu.updated event for every new or changed menu.
+func (database *Database) emitMenuUpdates(menus []dbModels.Menu) {
+	events := make([]dbModels.Event, 0, len(menus))
+	for _, menu := range menus {
+		event, err := newEvent(gen.WebhookEventMenuUpdated, 0, menuToGen(menu))
+		if err != nil {
+			log.Printf("Failed to create the event of the menu of %s: %s", menu.Date.Format("2006-01-02"), err.Error())
+			continue
+		}
+		events = append(events, event)
+	}
+	database.emitEvents(events)
+}
+
 func getFirstNMenus(menus []dbModels.Menu, n int) []dbModels.Menu {
 	// Sort menus by date
 	sort.Slice(menus, func(i, j int) bool {
@@ -0,0 +1,343 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/ical"
+	"github.com/uptrace/bun"
+)
+
+// calendarFeedDisplayLength is the number of characters of a feed token that are stored to recognize it.
+const calendarFeedDisplayLength = 6
+
+// CreateCalendarFeed creates a feed with a new token. The token is only returned here.
+func (database *Database) CreateCalendarFeed(feed dbModels.CalendarFeed, ctx context.Context) (gen.NewCalendarFeed, error) {
+	if feed.ChoiceId != 0 {
+		// The feed shows the choice with the rights of its owner, so the owner has to be able to use it
+		filter := dbModels.LessonFilter{User: dbModels.User{Id: feed.UserId}, Choice: dbModels.Choice{Id: feed.ChoiceId}}
+		if _, err := database.resolveLessonChoice(&filter, ctx); err != nil {
+			return gen.NewCalendarFeed{}, err
+		}
+	}
+	token, err := randomToken(24)
+	if err != nil {
+		return gen.NewCalendarFeed{}, err
+	}
+	feed.TokenHash = generateSHA256Hash(token)
+	feed.Prefix = token[:calendarFeedDisplayLength]
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Lock the user, so two feeds at once can not both pass the limit
+		err := tx.NewSelect().Model((*dbModels.User)(nil)).Column("id").Where("id = ?", feed.UserId).For("UPDATE").Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrUserNotFound
+			}
+			return err
+		}
+		count, err := tx.NewSelect().Model((*dbModels.CalendarFeed)(nil)).Where("\"userId\" = ?", feed.UserId).Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count >= config.Config.Calendar.MaxFeedsPerUser {
+			return dbModels.ErrCalendarFeedLimitReached
+		}
+		_, err = tx.NewInsert().Model(&feed).Returning("*").Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return gen.NewCalendarFeed{}, err
+	}
+	newFeed := gen.NewCalendarFeed{Feed: feed.ToGen(), Token: token}
+	if config.Config.Calendar.FeedURL != "" {
+		feedURL := fmt.Sprintf(config.Config.Calendar.FeedURL, token)
+		newFeed.Url = &feedURL
+	}
+	return newFeed, nil
+}
+
+// GetCalendarFeeds returns the feeds of a user.
+func (database *Database) GetCalendarFeeds(userId int, ctx context.Context) ([]gen.CalendarFeed, error) {
+	var feeds []dbModels.CalendarFeed
+	err := database.DB.NewSelect().Model(&feeds).Where("\"userId\" = ?", userId).Order("id").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genFeeds := make([]gen.CalendarFeed, len(feeds))
+	for i, feed := range feeds {
+		genFeeds[i] = feed.ToGen()
+	}
+	return genFeeds, nil
+}
+
+// DeleteCalendarFeed revokes a feed of a user. Its url stops working at once.
+func (database *Database) DeleteCalendarFeed(userId int, feedId int, ctx context.Context) error {
+	result, err := database.DB.NewDelete().
+		Model((*dbModels.CalendarFeed)(nil)).
+		Where("id = ?", feedId).
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return dbModels.ErrCalendarFeedNotFound
+	}
+	return nil
+}
+
+// GetCalendarFeedByToken returns the feed of a token and records its use.
+func (database *Database) GetCalendarFeedByToken(token string, ctx context.Context) (dbModels.CalendarFeed, error) {
+	var feed dbModels.CalendarFeed
+	err := database.DB.NewSelect().Model(&feed).Where("\"tokenHash\" = ?", generateSHA256Hash(token)).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return feed, dbModels.ErrCalendarFeedNotFound
+		}
+		return feed, err
+	}
+	feed.LastUsedAt = time.Now()
+	_, err = database.DB.NewUpdate().Model(&feed).Column("last_used_at").WherePK().Exec(ctx)
+	return feed, err
+}
+
+// CalendarWindow returns the days the calendars contain, from Calendar.PastDays before until Calendar.FutureDays
+// after today.
+func CalendarWindow() (time.Time, time.Time) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return today.AddDate(0, 0, -config.Config.Calendar.PastDays), today.AddDate(0, 0, config.Config.Calendar.FutureDays+1)
+}
+
+// CalendarScope identifies the lessons the CalDAV calendar of a user contains: the default choice of the user, or the
+// classes of the user without one. It changes when the user picks another choice or edits it.
+func (database *Database) CalendarScope(userId int, ctx context.Context) (string, error) {
+	filter := dbModels.LessonFilter{User: dbModels.User{Id: userId}, ClassFallback: true}
+	choice, err := database.resolveLessonChoice(&filter, ctx)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s", choice.Id, choice.Choice, strings.Join(filter.User.Classes, ","))))
+	return hex.EncodeToString(hash[:8]), nil
+}
+
+// GetCalendar returns the lessons of a feed in the CalendarWindow, with the menus and week subtitles if the feed
+// includes them. Only stored data is used, nothing is fetched from Untis.
+func (database *Database) GetCalendar(feed dbModels.CalendarFeed, ctx context.Context) (ical.Calendar, error) {
+	start, end := CalendarWindow()
+	filter := dbModels.LessonFilter{
+		User:      dbModels.User{Id: feed.UserId},
+		Choice:    dbModels.Choice{Id: feed.ChoiceId},
+		StartDate: start,
+		EndDate:   end,
+		// Without a default choice the feed contains the lessons of the classes of the user
+		ClassFallback: true,
+	}
+	lessons, err := database.GetLesson(filter, ctx)
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+	calendarEvents, err := database.LessonEvents(lessons, ctx)
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+	if feed.IncludeMenus {
+		var menus []dbModels.Menu
+		err := database.DB.NewSelect().Model(&menus).Where("date >= ? AND date < ?", start, end).Order("date").Scan(ctx)
+		if err != nil {
+			return ical.Calendar{}, err
+		}
+		for _, menu := range menus {
+			calendarEvents = append(calendarEvents, menuEvent(menu))
+		}
+	}
+	if feed.IncludeWeeks {
+		var weeks []dbModels.WeekSubtitle
+		err := database.DB.NewSelect().Model(&weeks).Where("date >= ? AND date < ?", start, end).Order("date").Scan(ctx)
+		if err != nil {
+			return ical.Calendar{}, err
+		}
+		for _, week := range weeks {
+			calendarEvents = append(calendarEvents, ical.Event{
+				UID:     fmt.Sprintf("week-%s@%s", week.Date.Format("20060102"), config.Config.Calendar.UIDDomain),
+				Start:   week.Date,
+				End:     week.Date.AddDate(0, 0, 1),
+				AllDay:  true,
+				Summary: week.Subtitle,
+			})
+		}
+	}
+
+	name := feed.Name
+	if name == "" {
+		name = "Timetable"
+	}
+	return ical.Calendar{
+		Name:            name,
+		Method:          "PUBLISH",
+		RefreshInterval: config.Config.Calendar.RefreshInterval,
+		Stamp:           start,
+		Events:          calendarEvents,
+	}, nil
+}
+
+// menuEvent returns the menu of a day as all day event.
+func menuEvent(menu dbModels.Menu) ical.Event {
+	var description []string
+	if menu.MainDishVeg != "" {
+		description = append(description, "Vegetarian: "+menu.MainDishVeg)
+	}
+	if menu.Garnish != "" {
+		description = append(description, "Garnish: "+menu.Garnish)
+	}
+	if menu.Dessert != "" {
+		description = append(description, "Dessert: "+menu.Dessert)
+	}
+	if menu.Cookteam != "" {
+		description = append(description, "Cookteam: "+menu.Cookteam)
+	}
+	summary := menu.MainDish
+	if summary == "" {
+		summary = "Menu"
+	}
+	return ical.Event{
+		UID:          fmt.Sprintf("menu-%s@%s", menu.Date.Format("20060102"), config.Config.Calendar.UIDDomain),
+		Start:        menu.Date,
+		End:          menu.Date.AddDate(0, 0, 1),
+		AllDay:       true,
+		Summary:      summary,
+		Description:  strings.Join(description, "\n"),
+		LastModified: menu.UpdatedAt,
+	}
+}
+
+// LessonUID returns the UID of the calendar event of a lesson. It stays the same when the lesson changes.
+func LessonUID(lessonId int) string {
+	return fmt.Sprintf("lesson-%d@%s", lessonId, config.Config.Calendar.UIDDomain)
+}
+
+// LessonEvents returns the lessons as calendar events with the names of their subjects, rooms and teachers.
+func (database *Database) LessonEvents(lessons []gen.Lesson, ctx context.Context) ([]ical.Event, error) {
+	var subjectIds, roomIds, teacherIds []int
+	for _, lesson := range lessons {
+		if lesson.Subjects != nil {
+			subjectIds = append(subjectIds, *lesson.Subjects...)
+		}
+		if lesson.Rooms != nil {
+			roomIds = append(roomIds, *lesson.Rooms...)
+		}
+		if lesson.Teachers != nil {
+			teacherIds = append(teacherIds, *lesson.Teachers...)
+		}
+	}
+	subjectNames := map[int]string{}
+	if len(subjectIds) > 0 {
+		var subjects []dbModels.Subject
+		err := database.DB.NewSelect().Model(&subjects).Where("id IN (?)", bun.In(subjectIds)).Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, subject := range subjects {
+			subjectNames[subject.Id] = subject.Name
+			if subject.Name == "" {
+				subjectNames[subject.Id] = subject.ShortName
+			}
+		}
+	}
+	roomNames := map[int]string{}
+	if len(roomIds) > 0 {
+		var rooms []dbModels.Room
+		err := database.DB.NewSelect().Model(&rooms).Where("id IN (?)", bun.In(roomIds)).Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, room := range rooms {
+			roomNames[room.Id] = room.Name
+		}
+	}
+	teacherNames := map[int]string{}
+	if len(teacherIds) > 0 {
+		var teachers []dbModels.Teacher
+		err := database.DB.NewSelect().Model(&teachers).Where("id IN (?)", bun.In(teacherIds)).Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, teacher := range teachers {
+			name := strings.TrimSpace(strings.Join([]string{teacher.Title, teacher.FirstName, teacher.Name}, " "))
+			if name == "" {
+				name = teacher.ShortName
+			}
+			teacherNames[teacher.Id] = name
+		}
+	}
+
+	calendarEvents := make([]ical.Event, 0, len(lessons))
+	for _, lesson := range lessons {
+		if lesson.Id == nil {
+			continue
+		}
+		summary := strings.Join(namesOf(lesson.Subjects, subjectNames), ", ")
+		if summary == "" && lesson.LessonText != nil {
+			summary = *lesson.LessonText
+		}
+		if summary == "" {
+			summary = "Lesson"
+		}
+		status := "CONFIRMED"
+		if lesson.Cancelled != nil && *lesson.Cancelled {
+			summary = "Cancelled: " + summary
+			status = "CANCELLED"
+		}
+		var description []string
+		if teachers := namesOf(lesson.Teachers, teacherNames); len(teachers) > 0 {
+			description = append(description, "Teachers: "+strings.Join(teachers, ", "))
+		}
+		if lesson.SubstitutionText != nil && *lesson.SubstitutionText != "" {
+			description = append(description, *lesson.SubstitutionText)
+		}
+		if lesson.AdditionalInformation != nil && *lesson.AdditionalInformation != "" {
+			description = append(description, *lesson.AdditionalInformation)
+		}
+		event := ical.Event{
+			UID:         LessonUID(*lesson.Id),
+			Start:       lesson.StartTime,
+			End:         lesson.EndTime,
+			Summary:     summary,
+			Location:    strings.Join(namesOf(lesson.Rooms, roomNames), ", "),
+			Description: strings.Join(description, "\n"),
+			Status:      status,
+		}
+		if lesson.LastUpdate != nil {
+			event.LastModified = *lesson.LastUpdate
+		}
+		calendarEvents = append(calendarEvents, event)
+	}
+	sort.SliceStable(calendarEvents, func(i, j int) bool {
+		return calendarEvents[i].Start.Before(calendarEvents[j].Start)
+	})
+	return calendarEvents, nil
+}
+
+// namesOf returns the known names of the ids.
+func namesOf(ids *[]int, names map[int]string) []string {
+	if ids == nil {
+		return nil
+	}
+	var result []string
+	for _, id := range *ids {
+		if name, ok := names[id]; ok && name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -1,16 +1,18 @@
 package db
 
 import (
-	"bytes"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
@@ -18,19 +20,56 @@ import (
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/hkdf"
 	"golang.org/x/crypto/pbkdf2"
 )
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserId    int    `json:"userID"`
-	Name      string `json:"userName"`
-	Role      string `json:"role"`
-	PWD       string `json:"pwd"`
-	CryptoKey string `json:"cKey"`
+	UserId int    `json:"userID"`
+	Name   string `json:"userName"`
+	Role   string `json:"role"`
+	PWD    string `json:"pwd"`
+	// WrappedKey is the Untis crypto key encrypted with the server side key encryption key.
+	WrappedKey string `json:"wKey,omitempty"`
+	// Deprecated: CryptoKey is the plain Untis crypto key of tokens issued before the key was wrapped.
+	// Those tokens do not authenticate requests, they can only be exchanged for a session with ExchangeLegacyToken.
+	CryptoKey string `json:"cKey,omitempty"`
+	// Partial is set on tokens issued after the password was verified, while the second factor is missing.
+	// They are only accepted by the second factor endpoints of the login.
+	Partial bool `json:"partial,omitempty"`
+	// Scopes and AccessTokenId are only set for requests authenticated with a personal access token.
+	// They are never part of a signed token.
+	Scopes        []string `json:"-"`
+	AccessTokenId int      `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// cryptoKeyPurpose is the associated data prefix of keys wrapped into tokens.
+const cryptoKeyPurpose = "tmf token crypto key"
+
+// keyEncryptionKey returns the server side AES key used to wrap the crypto key inside tokens.
+func keyEncryptionKey() []byte {
+	kek := sha256.Sum256([]byte(config.Config.Crypto.KeyEncryptionKey))
+	return kek[:]
+}
+
+// wrapCryptoKey encrypts the crypto key for a token. The key is bound to the user and the session.
+func wrapCryptoKey(cryptoKey []byte, userId int, sessionId string) (string, error) {
+	return sealKey(keyEncryptionKey(), cryptoKey, []byte(fmt.Sprintf("%s|%d|%s", cryptoKeyPurpose, userId, sessionId)))
+}
+
+// UnwrapCryptoKey returns the crypto key carried by the token.
+func (claims *Claims) UnwrapCryptoKey() ([]byte, error) {
+	if claims == nil {
+		return nil, dbModels.ErrNoCryptoKey
+	}
+	if claims.WrappedKey != "" {
+		return openKey(keyEncryptionKey(), claims.WrappedKey, []byte(fmt.Sprintf("%s|%d|%s", cryptoKeyPurpose, claims.UserId, claims.ID)))
+	}
+	return nil, dbModels.ErrNoCryptoKey
+}
+
 // hashPassword hashes a plain text password with bcrypt and returns the hashed password.
 func hashPassword(password string) (string, error) {
 	// Generate a hash of the password using bcrypt
@@ -53,7 +92,19 @@ func verifyPassword(password string, hashedPassword string) (bool, error) {
 	return true, nil
 }
 
-func (database *Database) CreateSession(body gen.PostLoginJSONBody, cxt context.Context) (string, gen.User, error) {
+// SessionTokens are the tokens handed out on login and on every refresh.
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	// ExpiresAt is the expiration time of the access token.
+	ExpiresAt time.Time
+	// PartialToken is set instead of the other tokens if the login needs a second factor (POST /login/totp).
+	PartialToken string
+	// EnrollmentRequired is set if the role of the user requires a second factor that is not enrolled yet.
+	EnrollmentRequired bool
+}
+
+func (database *Database) CreateSession(body gen.PostLoginJSONBody, device string, ip string, cxt context.Context) (SessionTokens, gen.User, error) {
 	var user dbModels.User
 	query := database.DB.NewSelect()
 	query.Model(&user)
@@ -62,46 +113,83 @@ func (database *Database) CreateSession(body gen.PostLoginJSONBody, cxt context.
 	err := query.Scan(cxt) //sql.ErrNoRows
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", gen.User{}, dbModels.ErrUserNotFound
+			return SessionTokens{}, gen.User{}, dbModels.ErrUserNotFound
 		}
-		return "", gen.User{}, err
+		return SessionTokens{}, gen.User{}, err
+	}
+	// Users created through the identity provider have no password
+	if user.PwdHash == "" {
+		return SessionTokens{}, gen.User{}, dbModels.ErrInvalidPassword
 	}
 	verified, err := verifyPassword(*body.Password, user.PwdHash)
 	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		return "", gen.User{}, dbModels.ErrInvalidPassword
+		return SessionTokens{}, gen.User{}, dbModels.ErrInvalidPassword
 	}
 	if verified {
-		// Generate a new session token
-		token, err := generateSessionToken(user, *body.Password, time.Now().AddDate(1, 0, 0))
+		cryptoKey := deriveKey(*body.Password)
+		// Re-encrypt secrets stored in the legacy format now that the key is available
+		if err := database.migrateSecretUserSettings(user.Id, cryptoKey, cxt); err != nil {
+			log.Printf("Failed to migrate secret settings of user %d: %s", user.Id, err.Error())
+		}
+		tokens, err := database.loginSession(user, cryptoKey, device, ip, cxt)
 		if err != nil {
-			return "", gen.User{}, err
+			return SessionTokens{}, gen.User{}, err
 		}
-		return token, user.ToGen(), err
+		return tokens, user.ToGen(), err
 	}
-	return "", gen.User{}, err
+	return SessionTokens{}, gen.User{}, err
+
+}
 
+// loginSession starts the session of a user whose first factor was verified. If the user enrolled TOTP or its role
+// requires a second factor, only a partial token for POST /login/totp is returned.
+func (database *Database) loginSession(user dbModels.User, cryptoKey []byte, device string, ip string, ctx context.Context) (SessionTokens, error) {
+	if tokens, pending, err := database.secondFactorLogin(user, cryptoKey, ctx); err != nil || pending {
+		return tokens, err
+	}
+	return database.startSession(user, cryptoKey, device, ip, ctx)
 }
-func generateSessionToken(user dbModels.User, userPWD string, expirationTime time.Time) (string, error) {
-	// Generate a random token
 
+// secondFactorLogin returns the partial token of a login and pending = true if the user still has to send a second factor.
+func (database *Database) secondFactorLogin(user dbModels.User, cryptoKey []byte, ctx context.Context) (tokens SessionTokens, pending bool, err error) {
+	enrolled, err := database.DB.NewSelect().
+		Model((*dbModels.UserTOTP)(nil)).
+		Where("\"userId\" = ?", user.Id).
+		Where("confirmed = true").
+		Exists(ctx)
+	if err != nil {
+		return SessionTokens{}, false, err
+	}
+	if !enrolled && !secondFactorRequired(user.Role) {
+		return SessionTokens{}, false, nil
+	}
+	partialToken, err := generatePartialToken(user, cryptoKey)
+	if err != nil {
+		return SessionTokens{}, false, err
+	}
+	return SessionTokens{PartialToken: partialToken, EnrollmentRequired: !enrolled}, true, nil
+}
+func generateSessionToken(user dbModels.User, cryptoKey []byte, sessionId string, expirationTime time.Time) (string, error) {
+	wrappedKey, err := wrapCryptoKey(cryptoKey, user.Id, sessionId)
+	if err != nil {
+		return "", err
+	}
 	// Create the JWT claims, which includes the email and expiration time
 	claims := &Claims{
-		UserId:    user.Id,
-		Name:      user.Name,
-		Role:      user.Role,
-		PWD:       generateSHA256Hash(user.PwdHash)[:8],
-		CryptoKey: base64.StdEncoding.EncodeToString(deriveKey(userPWD)),
+		UserId:     user.Id,
+		Name:       user.Name,
+		Role:       user.Role,
+		PWD:        generateSHA256Hash(user.PwdHash)[:8],
+		WrappedKey: wrappedKey,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionId,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	// Create the token using the claims and the signing method
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(config.Config.Crypto.JwtSecretKey))
+	// Sign the token with the current key of the keyring
+	tokenString, err := signToken(claims)
 	if err != nil {
 		return "", err
 	}
@@ -125,13 +213,7 @@ func generateSHA256Hash(input string) string {
 // VerifySession verifies the JWT token and returns the user claims if valid.
 func unpackToken(tokenString string) (*Claims, error) {
 	// Parse the token using the JWT library
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the token's signing method is HMAC and return the secret key
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(config.Config.Crypto.JwtSecretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, jwtKeyFunc)
 
 	if err != nil {
 		return nil, err
@@ -142,6 +224,9 @@ func unpackToken(tokenString string) (*Claims, error) {
 	if !ok || !token.Valid {
 		return nil, errors.New("invalid token")
 	}
+	if claims.Partial {
+		return nil, dbModels.ErrSecondFactorRequired
+	}
 
 	// Optionally, you can add additional checks here (e.g., user existence in the database)
 
@@ -165,10 +250,14 @@ func (database *Database) verifySession(tokenString string, cxt context.Context)
 		return dbModels.User{}, claims, err
 	}
 
-	if claims.PWD == generateSHA256Hash(user.PwdHash)[:8] {
-		return user, claims, nil
+	if claims.PWD != generateSHA256Hash(user.PwdHash)[:8] {
+		return dbModels.User{}, claims, dbModels.ErrInvalidPassword
 	}
-	return dbModels.User{}, claims, dbModels.ErrInvalidPassword
+	err = database.touchSession(claims.ID, user.Id, cxt)
+	if err != nil {
+		return dbModels.User{}, claims, err
+	}
+	return user, claims, nil
 
 }
 func (database *Database) VerifySession(tokenString string, cxt context.Context) (gen.User, *Claims, error) {
@@ -181,46 +270,125 @@ func deriveKey(password string) []byte {
 	return pbkdf2.Key([]byte(password), []byte(config.Config.Crypto.Untis.Salt), 100000, 32, sha256.New)
 }
 
-// Pad data to be a multiple of the block size
-func pad(data []byte) []byte {
-	padding := aes.BlockSize - len(data)%aes.BlockSize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(data, padtext...)
-}
-
-// Unpad data
+// Unpad data and validate the PKCS#7 padding
 func unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("padding size error")
+	}
 	padding := data[len(data)-1]
-	if int(padding) > aes.BlockSize {
+	if padding == 0 || int(padding) > aes.BlockSize {
 		return nil, fmt.Errorf("padding size error")
 	}
+	for _, b := range data[len(data)-int(padding):] {
+		if b != padding {
+			return nil, fmt.Errorf("padding error")
+		}
+	}
 	return data[:len(data)-int(padding)], nil
 }
 
-// Encrypt data using AES with a fixed IV
-func encrypt(data []byte, key []byte) ([]byte, error) {
+// Decrypt data using AES-CBC with a fixed IV. Only used to read values stored before the switch to AES-GCM.
+func decryptLegacy(encData []byte, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
+	if len(encData) == 0 || len(encData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("ciphertext is not a multiple of the block size")
+	}
+
+	data := make([]byte, len(encData))
+	mode := cipher.NewCBCDecrypter(block, []byte(config.Config.Crypto.Untis.FixedIV))
+	mode.CryptBlocks(data, encData)
+	return unpad(data) // Unpad after decryption
+}
 
-	data = pad(data) // Pad data before encryption
+// settingCiphertextPrefix marks the versioned format of encrypted user settings: "v2:" + base64(nonce|ciphertext).
+// Values without the prefix are base64 AES-CBC ciphertexts of the legacy format.
+const settingCiphertextPrefix = "v2:"
 
-	ciphertext := make([]byte, len(data))
-	mode := cipher.NewCBCEncrypter(block, []byte(config.Config.Crypto.Untis.FixedIV))
-	mode.CryptBlocks(ciphertext, data)
+// settingAssociatedData binds an encrypted setting to its user and name, so ciphertexts can not be swapped between rows.
+func settingAssociatedData(userId int, settingType, settingName string) []byte {
+	return []byte(fmt.Sprintf("%d|%s|%s", userId, settingType, settingName))
+}
 
-	return ciphertext, nil // Return ciphertext without prepending IV
+// encryptSetting encrypts the value of a user setting with AES-GCM and a random nonce.
+func encryptSetting(userId int, settingType, settingName string, value []byte, key []byte) (string, error) {
+	sealed, err := sealKey(key, value, settingAssociatedData(userId, settingType, settingName))
+	if err != nil {
+		return "", err
+	}
+	return settingCiphertextPrefix + sealed, nil
 }
 
-// Decrypt data using AES with a fixed IV
-func decrypt(encData []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// decryptSetting decrypts the value of a user setting. legacy reports if the value was stored in the old AES-CBC format.
+func decryptSetting(userId int, settingType, settingName string, encValue string, key []byte) (value []byte, legacy bool, err error) {
+	if strings.HasPrefix(encValue, settingCiphertextPrefix) {
+		value, err = openKey(key, strings.TrimPrefix(encValue, settingCiphertextPrefix), settingAssociatedData(userId, settingType, settingName))
+		return value, false, err
+	}
+	encData, err := base64.StdEncoding.DecodeString(encValue)
+	if err != nil {
+		return nil, true, err
+	}
+	value, err = decryptLegacy(encData, key)
+	return value, true, err
+}
+
+// randomToken returns a url safe random string with n bytes of entropy.
+func randomToken(n int) (string, error) {
+	data := make([]byte, n)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// tokenKey derives an AES key from an opaque token. The purpose separates keys derived for different uses.
+func tokenKey(token string, purpose string) ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := io.ReadFull(hkdf.New(sha256.New, []byte(token), nil, []byte(purpose)), key)
 	if err != nil {
 		return nil, err
 	}
+	return key, nil
+}
 
-	mode := cipher.NewCBCDecrypter(block, []byte(config.Config.Crypto.Untis.FixedIV))
-	mode.CryptBlocks(encData, encData)
-	return unpad(encData) // Unpad after decryption
+// sealKey encrypts a key (or any other small secret) with AES-GCM and returns base64(nonce|ciphertext).
+// The associated data has to be presented again to open the key.
+func sealKey(kek []byte, key []byte, aad []byte) (string, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, key, aad)), nil
+}
+
+// openKey decrypts a key sealed with sealKey.
+func openKey(kek []byte, sealed string, aad []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("sealed key too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
 }
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// tamper returns the sealed value with one bit of the byte at index flipped.
+func tamper(t *testing.T, sealed string, index int) string {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index < 0 {
+		index += len(data)
+	}
+	data[index] ^= 1
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestSealKey(t *testing.T) {
+	kek := bytes.Repeat([]byte{1}, 32)
+	key := bytes.Repeat([]byte{2}, 32)
+	aad := []byte("purpose|1|session")
+	sealed, err := sealKey(kek, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := sealKey(kek, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed == again {
+		t.Error("sealKey returned the same ciphertext twice, the nonce is not random")
+	}
+
+	tests := []struct {
+		name   string
+		kek    []byte
+		sealed string
+		aad    []byte
+		ok     bool
+	}{
+		{"round trip", kek, sealed, aad, true},
+		{"second ciphertext", kek, again, aad, true},
+		{"other key", bytes.Repeat([]byte{3}, 32), sealed, aad, false},
+		{"other associated data", kek, sealed, []byte("purpose|2|session"), false},
+		{"no associated data", kek, sealed, nil, false},
+		{"tampered nonce", kek, tamper(t, sealed, 0), aad, false},
+		{"tampered ciphertext", kek, tamper(t, sealed, 12), aad, false},
+		{"tampered tag", kek, tamper(t, sealed, -1), aad, false},
+		{"truncated", kek, base64.StdEncoding.EncodeToString([]byte("short")), aad, false},
+		{"not base64", kek, "not base64!", aad, false},
+	}
+	for _, test := range tests {
+		opened, err := openKey(test.kek, test.sealed, test.aad)
+		if test.ok {
+			if err != nil || !bytes.Equal(opened, key) {
+				t.Errorf("%s: openKey = %x, %v, want %x", test.name, opened, err, key)
+			}
+		} else if err == nil {
+			t.Errorf("%s: openKey succeeded, want an error", test.name)
+		}
+	}
+}
+
+// encryptLegacy encrypts like the values stored before AES-GCM: AES-CBC with the fixed IV and PKCS#7 padding.
+func encryptLegacy(t *testing.T, value []byte, key []byte) string {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(value)%aes.BlockSize
+	data := append(append([]byte{}, value...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, []byte(config.Config.Crypto.Untis.FixedIV)).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestDecryptSetting(t *testing.T) {
+	key := bytes.Repeat([]byte{4}, 32)
+	value := []byte("untis password")
+	encrypted, err := encryptSetting(1, "untis", "password", value, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		userId      int
+		settingName string
+		encValue    string
+		key         []byte
+		legacy      bool
+		ok          bool
+	}{
+		{"round trip", 1, "password", encrypted, key, false, true},
+		{"other user", 2, "password", encrypted, key, false, false},
+		{"other setting", 1, "username", encrypted, key, false, false},
+		{"other key", 1, "password", encrypted, bytes.Repeat([]byte{5}, 32), false, false},
+		{"tampered", 1, "password", settingCiphertextPrefix + tamper(t, strings.TrimPrefix(encrypted, settingCiphertextPrefix), 20), key, false, false},
+		{"legacy", 1, "password", encryptLegacy(t, value, key), key, true, true},
+		{"legacy other key", 1, "password", encryptLegacy(t, value, key), bytes.Repeat([]byte{5}, 32), true, false},
+		{"legacy not base64", 1, "password", "not base64!", key, true, false},
+	}
+	for _, test := range tests {
+		decrypted, legacy, err := decryptSetting(test.userId, "untis", test.settingName, test.encValue, test.key)
+		if legacy != test.legacy {
+			t.Errorf("%s: legacy = %v, want %v", test.name, legacy, test.legacy)
+		}
+		if test.ok {
+			if err != nil || !bytes.Equal(decrypted, value) {
+				t.Errorf("%s: decryptSetting = %q, %v, want %q", test.name, decrypted, err, value)
+			}
+		} else if err == nil && bytes.Equal(decrypted, value) {
+			t.Errorf("%s: decryptSetting succeeded, want an error", test.name)
+		}
+	}
+}
+
+func TestLegacyKeyFunc(t *testing.T) {
+	claims := &Claims{UserId: 1, CryptoKey: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{6}, 32))}
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Config.Crypto.JwtSecretKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withKid := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	withKid.Header["kid"] = "key"
+	keyed, err := withKid.SignedString([]byte(config.Config.Crypto.JwtSecretKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("other secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"legacy", legacy, true},
+		{"kid", keyed, false},
+		{"other secret", otherSecret, false},
+	}
+	for _, test := range tests {
+		parsed := &Claims{}
+		_, err := jwt.ParseWithClaims(test.token, parsed, legacyKeyFunc)
+		if test.ok != (err == nil) {
+			t.Errorf("%s: ParseWithClaims error = %v, want ok = %v", test.name, err, test.ok)
+		}
+		if test.ok && parsed.CryptoKey != claims.CryptoKey {
+			t.Errorf("%s: cKey = %q, want %q", test.name, parsed.CryptoKey, claims.CryptoKey)
+		}
+	}
+}
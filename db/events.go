@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/events"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// eventChannel is notified after events were stored, so every instance publishes them to its streams.
+const eventChannel = "tmf_events"
+
+// eventBatchSize is the number of stored events published at once.
+const eventBatchSize = 500
+
+// eventGapTimeout is how long a missing event id holds back the events after it. Ids are taken when an insert
+// starts, so an insert that commits later leaves a gap until then. The gaps of failed inserts never close.
+const eventGapTimeout = 10 * time.Second
+
+// newEvent creates an event with the JSON representation of data.
+func newEvent(eventType gen.WebhookEvent, lessonId int, data interface{}) (dbModels.Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return dbModels.Event{}, err
+	}
+	event := dbModels.Event{Type: string(eventType), CreatedAt: time.Now(), LessonId: lessonId}
+	err = json.Unmarshal(raw, &event.Data)
+	return event, err
+}
+
+// emitLessonChanges emits a lesson.changed event for every new version of a lesson.
+func (database *Database) emitLessonChanges(versions []dbModels.LessonVersion, lessons []dbModels.Lesson) {
+	if len(versions) == 0 {
+		return
+	}
+	lessonsById := make(map[int]dbModels.Lesson, len(lessons))
+	for _, lesson := range lessons {
+		lessonsById[lesson.Id] = lesson
+	}
+	events := make([]dbModels.Event, 0, len(versions))
+	for _, version := range versions {
+		lesson := lessonsById[version.LessonId]
+		version.Lesson = &lesson
+		event, err := newEvent(gen.WebhookEventLessonChanged, lesson.Id, version.ToGen())
+		if err != nil {
+			log.Printf("Failed to create the event of lesson %d: %s", lesson.Id, err.Error())
+			continue
+		}
+		events = append(events, event)
+	}
+	database.emitEvents(events)
+}
+
+// emitEvents stores the events, queues them for the webhooks and notifies the streams of every instance.
+// It runs after the change was written, failures are only logged.
+func (database *Database) emitEvents(events []dbModels.Event) {
+	if (!config.Config.Webhooks.Enabled && !config.Config.Stream.Enabled) || len(events) == 0 {
+		return
+	}
+	ctx := context.Background()
+	_, err := database.DB.NewInsert().Model(&events).Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to store the events: %s", err.Error())
+		return
+	}
+	if config.Config.Webhooks.Enabled {
+		database.queueWebhookDeliveries(events, ctx)
+	}
+	if config.Config.Stream.Enabled {
+		// The listeners poll as well, a lost notification only delays the events
+		if err := pgdriver.Notify(ctx, database.DB, eventChannel, ""); err != nil {
+			log.Printf("Failed to notify the event listeners: %s", err.Error())
+		}
+	}
+}
+
+// streamEvent converts a stored event for the hub.
+func streamEvent(event dbModels.Event) (events.Event, error) {
+	data, err := json.Marshal(event.Data)
+	return events.Event{
+		Id:        event.Id,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		LessonId:  event.LessonId,
+		Data:      data,
+	}, err
+}
+
+// GetEventsAfter returns up to limit stored events with an id after afterId and up to untilId, oldest first.
+func (database *Database) GetEventsAfter(afterId int64, untilId int64, limit int, ctx context.Context) ([]events.Event, error) {
+	var stored []dbModels.Event
+	err := database.DB.NewSelect().
+		Model(&stored).
+		Where("id > ?", afterId).
+		Where("id <= ?", untilId).
+		Order("id").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]events.Event, 0, len(stored))
+	for _, event := range stored {
+		converted, err := streamEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+// OldestEventId returns the id of the oldest stored event, 0 if there is none.
+func (database *Database) OldestEventId(ctx context.Context) (int64, error) {
+	var id int64
+	err := database.DB.NewSelect().Model((*dbModels.Event)(nil)).ColumnExpr("COALESCE(MIN(id), 0)").Scan(ctx, &id)
+	return id, err
+}
+
+// FilterEventsForUser removes the lesson.changed events of lessons the choice of the user does not include.
+func (database *Database) FilterEventsForUser(userId int, stream []events.Event, ctx context.Context) ([]events.Event, error) {
+	var lessonIds []int
+	for _, event := range stream {
+		if event.LessonId != 0 {
+			lessonIds = append(lessonIds, event.LessonId)
+		}
+	}
+	if len(lessonIds) == 0 {
+		return stream, nil
+	}
+	ids, err := database.lessonsOfUser(userId, lessonIds, ctx)
+	if err != nil {
+		return nil, err
+	}
+	matching := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		matching[id] = true
+	}
+	result := make([]events.Event, 0, len(stream))
+	for _, event := range stream {
+		if event.LessonId == 0 || matching[event.LessonId] {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+// RunEventListener publishes the events stored by any instance to events.DefaultHub and deletes expired events.
+// It blocks, run it in a goroutine.
+func (database *Database) RunEventListener() {
+	ctx := context.Background()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+	if !config.Config.Stream.Enabled {
+		for range prune.C {
+			database.pruneEvents(ctx)
+		}
+		return
+	}
+
+	// Only events stored from now on are published, streams resume older ones from the database
+	var cursor eventCursor
+	err := database.DB.NewSelect().Model((*dbModels.Event)(nil)).ColumnExpr("COALESCE(MAX(id), 0)").Scan(ctx, &cursor.lastId)
+	if err != nil {
+		log.Printf("Failed to load the last event: %s", err.Error())
+	}
+	events.DefaultHub.MarkPublished(cursor.lastId)
+	listener := pgdriver.NewListener(database.DB)
+	defer listener.Close()
+	var notifications <-chan pgdriver.Notification
+	if err := listener.Listen(ctx, eventChannel); err != nil {
+		log.Printf("Failed to listen for events, falling back to polling: %s", err.Error())
+	} else {
+		notifications = listener.Channel()
+	}
+	poll := time.NewTicker(config.Config.Stream.PollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-notifications:
+		case <-poll.C:
+		case <-prune.C:
+			database.pruneEvents(ctx)
+			continue
+		}
+		database.publishEvents(&cursor, ctx)
+	}
+}
+
+// eventCursor is the position of the event listener.
+type eventCursor struct {
+	// Id of the last published event.
+	lastId int64
+	// When the gap after lastId was found, zero if there is none.
+	gapSince time.Time
+}
+
+// publishEvents publishes the events after the cursor in the order of their ids. The ids are taken before the
+// inserts commit, so a missing id stops the publishing until it was committed or eventGapTimeout passed.
+// Otherwise an event committed late would be behind the Last-Event-ID of the streams and never reach them.
+func (database *Database) publishEvents(cursor *eventCursor, ctx context.Context) {
+	for {
+		stored, err := database.GetEventsAfter(cursor.lastId, math.MaxInt64, eventBatchSize, ctx)
+		if err != nil {
+			log.Printf("Failed to load the new events: %s", err.Error())
+			return
+		}
+		for _, event := range stored {
+			if event.Id != cursor.lastId+1 {
+				if cursor.gapSince.IsZero() {
+					cursor.gapSince = time.Now()
+				}
+				if time.Since(cursor.gapSince) < eventGapTimeout {
+					return
+				}
+			}
+			cursor.gapSince = time.Time{}
+			events.DefaultHub.Publish(event)
+			cursor.lastId = event.Id
+		}
+		if len(stored) < eventBatchSize {
+			return
+		}
+	}
+}
+
+// pruneEvents deletes finished webhook deliveries and events older than Events.Retention. Events with pending
+// deliveries are kept.
+func (database *Database) pruneEvents(ctx context.Context) {
+	if config.Config.Events.Retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-config.Config.Events.Retention)
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*dbModels.WebhookDelivery)(nil)).
+			Where("status != ?", string(gen.WebhookDeliveryStatusPending)).
+			Where("created_at < ?", cutoff).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*dbModels.Event)(nil)).
+			Where("created_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM webhook_delivery WHERE \"eventId\" = event.id)").
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to prune the events: %s", err.Error())
+	}
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/events"
+)
+
+// receivedIds returns the ids of the events buffered by the subscriber.
+func receivedIds(subscriber *events.Subscriber) []int64 {
+	var ids []int64
+	for {
+		select {
+		case event := <-subscriber.C:
+			ids = append(ids, event.Id)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestPublishEventsGap(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	subscriber := events.DefaultHub.Subscribe(16)
+	defer events.DefaultHub.Unsubscribe(subscriber)
+
+	// Event 3 is still being inserted
+	stored := []dbModels.Event{{Id: 1, Type: "menu.updated"}, {Id: 2, Type: "menu.updated"}, {Id: 4, Type: "menu.updated"}}
+	if _, err := database.DB.NewInsert().Model(&stored).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var cursor eventCursor
+	database.publishEvents(&cursor, ctx)
+	if ids := receivedIds(subscriber); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("published %v before the gap, want [1 2]", ids)
+	}
+	if id, _ := events.DefaultHub.LastId(); id != 2 {
+		t.Errorf("LastId = %d, want 2", id)
+	}
+
+	// The gap closes when the insert commits
+	if _, err := database.DB.NewInsert().Model(&dbModels.Event{Id: 3, Type: "menu.updated"}).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	database.publishEvents(&cursor, ctx)
+	if ids := receivedIds(subscriber); len(ids) != 2 || ids[0] != 3 || ids[1] != 4 {
+		t.Fatalf("published %v after the gap closed, want [3 4]", ids)
+	}
+
+	// A gap of a failed insert is skipped after eventGapTimeout
+	if _, err := database.DB.NewInsert().Model(&dbModels.Event{Id: 6, Type: "menu.updated"}).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	database.publishEvents(&cursor, ctx)
+	if ids := receivedIds(subscriber); len(ids) != 0 {
+		t.Fatalf("published %v across an open gap", ids)
+	}
+	cursor.gapSince = time.Now().Add(-eventGapTimeout)
+	database.publishEvents(&cursor, ctx)
+	if ids := receivedIds(subscriber); len(ids) != 1 || ids[0] != 6 {
+		t.Errorf("published %v after the gap timed out, want [6]", ids)
+	}
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+)
+
+// CreateGuardianInvite creates an invite for a guardian of the student and returns its code.
+func (database *Database) CreateGuardianInvite(studentId int, ctx context.Context) (string, time.Time, gen.GuardianLink, error) {
+	code, err := randomToken(16)
+	if err != nil {
+		return "", time.Time{}, gen.GuardianLink{}, err
+	}
+	link := dbModels.GuardianLink{
+		StudentId:       studentId,
+		InviteHash:      generateSHA256Hash(code),
+		InviteExpiresAt: time.Now().Add(config.Config.Guardians.InviteLifetime),
+	}
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Lock the student, so two invites at once can not both pass the limit
+		err := tx.NewSelect().Model((*dbModels.User)(nil)).Column("id").Where("id = ?", studentId).For("UPDATE").Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrUserNotFound
+			}
+			return err
+		}
+		count, err := tx.NewSelect().
+			Model((*dbModels.GuardianLink)(nil)).
+			Where("\"studentId\" = ?", studentId).
+			Where("accepted_at IS NOT NULL OR invite_expires_at > ?", time.Now()).
+			Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count >= config.Config.Guardians.MaxPerStudent {
+			return dbModels.ErrGuardianLimitReached
+		}
+		_, err = tx.NewInsert().Model(&link).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return "", time.Time{}, gen.GuardianLink{}, err
+	}
+	return code, link.InviteExpiresAt, link.ToGen(), nil
+}
+
+// AcceptGuardianInvite makes the user the guardian of the student that created the invite.
+func (database *Database) AcceptGuardianInvite(code string, guardianId int, ctx context.Context) (gen.GuardianLink, error) {
+	var link dbModels.GuardianLink
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model(&link).
+			Where("\"inviteHash\" = ?", generateSHA256Hash(code)).
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrGuardianInviteInvalid
+			}
+			return err
+		}
+		if !link.AcceptedAt.IsZero() || link.InviteExpiresAt.Before(time.Now()) {
+			return dbModels.ErrGuardianInviteInvalid
+		}
+		if link.StudentId == guardianId {
+			return dbModels.ErrGuardianSelf
+		}
+		exists, err := tx.NewSelect().
+			Model((*dbModels.GuardianLink)(nil)).
+			Where("\"studentId\" = ?", link.StudentId).
+			Where("\"guardianId\" = ?", guardianId).
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return dbModels.ErrGuardianAlreadyLinked
+		}
+		// The code can only be used once
+		link.GuardianId = guardianId
+		link.AcceptedAt = time.Now()
+		link.InviteHash = ""
+		link.InviteExpiresAt = time.Time{}
+		_, err = tx.NewUpdate().Model(&link).Column("guardianId", "accepted_at", "inviteHash", "invite_expires_at").WherePK().Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return gen.GuardianLink{}, err
+	}
+	return database.getGuardianLink(link.Id, ctx)
+}
+
+func (database *Database) getGuardianLink(linkId int, ctx context.Context) (gen.GuardianLink, error) {
+	var link dbModels.GuardianLink
+	err := database.DB.NewSelect().
+		Model(&link).
+		Relation("Student", func(q *bun.SelectQuery) *bun.SelectQuery { return q.Column("name") }).
+		Relation("Guardian", func(q *bun.SelectQuery) *bun.SelectQuery { return q.Column("name") }).
+		Where("\"guardian_link\".\"id\" = ?", linkId).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return gen.GuardianLink{}, dbModels.ErrGuardianLinkNotFound
+		}
+		return gen.GuardianLink{}, err
+	}
+	return link.ToGen(), nil
+}
+
+// GetGuardians returns the guardians and the open invites of a student.
+func (database *Database) GetGuardians(studentId int, ctx context.Context) ([]gen.GuardianLink, error) {
+	return database.getGuardianLinks(ctx, func(query *bun.SelectQuery) {
+		query.Where("\"guardian_link\".\"studentId\" = ?", studentId)
+		query.Where("\"guardian_link\".accepted_at IS NOT NULL OR \"guardian_link\".invite_expires_at > ?", time.Now())
+	})
+}
+
+// GetWards returns the students the user is a guardian of.
+func (database *Database) GetWards(guardianId int, ctx context.Context) ([]gen.GuardianLink, error) {
+	return database.getGuardianLinks(ctx, func(query *bun.SelectQuery) {
+		query.Where("\"guardian_link\".\"guardianId\" = ?", guardianId)
+	})
+}
+
+func (database *Database) getGuardianLinks(ctx context.Context, filter func(query *bun.SelectQuery)) ([]gen.GuardianLink, error) {
+	var links []dbModels.GuardianLink
+	query := database.DB.NewSelect().
+		Model(&links).
+		Relation("Student", func(q *bun.SelectQuery) *bun.SelectQuery { return q.Column("name") }).
+		Relation("Guardian", func(q *bun.SelectQuery) *bun.SelectQuery { return q.Column("name") }).
+		Order("guardian_link.id")
+	filter(query)
+	err := query.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genLinks := make([]gen.GuardianLink, len(links))
+	for i, link := range links {
+		genLinks[i] = link.ToGen()
+	}
+	return genLinks, nil
+}
+
+// RevokeGuardianLink deletes a guardian link or an open invite. Both the student and the guardian can revoke it.
+func (database *Database) RevokeGuardianLink(userId int, linkId int, ctx context.Context) (gen.GuardianLink, error) {
+	var link dbModels.GuardianLink
+	res, err := database.DB.NewDelete().
+		Model(&link).
+		Where("id = ?", linkId).
+		Where("\"studentId\" = ? OR \"guardianId\" = ?", userId, userId).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return gen.GuardianLink{}, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return gen.GuardianLink{}, dbModels.ErrGuardianLinkNotFound
+	}
+	return link.ToGen(), nil
+}
+
+// IsGuardianOf reports if the user is a guardian of the student.
+func (database *Database) IsGuardianOf(guardianId int, studentId int, ctx context.Context) (bool, error) {
+	return database.DB.NewSelect().
+		Model((*dbModels.GuardianLink)(nil)).
+		Where("\"studentId\" = ?", studentId).
+		Where("\"guardianId\" = ?", guardianId).
+		Where("accepted_at IS NOT NULL").
+		Exists(ctx)
+}
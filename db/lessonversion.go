@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+)
+
+// lessonVersions compares the fetched lessons with the stored ones and returns a version for every material change.
+// A new lesson is only a change if it is cancelled or irregular, otherwise the first fetch of a class would flood the feed.
+// The stored lessons are locked, so a concurrent fetch can not write the same change twice.
+// It also returns the ids of the new and changed lessons, including changes of the other fields shown in the calendars.
+// Those get the current time as LastUpdate, the other lessons keep the stored one, so their ETags stay the same.
+func lessonVersions(tx bun.Tx, lessons []dbModels.Lesson, ctx context.Context) ([]dbModels.LessonVersion, []int, error) {
+	ids := make([]int, len(lessons))
+	for i, lesson := range lessons {
+		ids[i] = lesson.Id
+	}
+	var stored []dbModels.Lesson
+	err := tx.NewSelect().Model(&stored).Where("id IN (?)", bun.In(ids)).For("UPDATE").Scan(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	previous := make(map[int]*dbModels.Lesson, len(stored))
+	for i := range stored {
+		previous[stored[i].Id] = &stored[i]
+	}
+
+	now := time.Now()
+	versions := make([]dbModels.LessonVersion, 0)
+	var changed []int
+	for i := range lessons {
+		lesson := &lessons[i]
+		old, exists := previous[lesson.Id]
+		diff := lessonDiff(old, *lesson)
+		if exists && len(diff) == 0 && reflect.DeepEqual(otherLessonFields(*old), otherLessonFields(*lesson)) {
+			lesson.LastUpdate = old.LastUpdate
+			continue
+		}
+		lesson.LastUpdate = now
+		changed = append(changed, lesson.Id)
+		if exists && len(diff) == 0 {
+			continue
+		}
+		kind := string(gen.LessonChangeKindUpdated)
+		if !exists {
+			if !lesson.Cancelled && !lesson.Irregular {
+				continue
+			}
+			kind = string(gen.LessonChangeKindCreated)
+		}
+		versions = append(versions, dbModels.LessonVersion{
+			LessonId:  lesson.Id,
+			ChangedAt: now,
+			Kind:      kind,
+			Diff:      diff,
+		})
+	}
+	return versions, changed, nil
+}
+
+// lockLessonChanges serializes the writers of lessons until the end of the transaction, so the change numbers are
+// committed in the order they are taken. Take it before the lessons are locked.
+func lockLessonChanges(tx bun.Tx, ctx context.Context) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('lesson_change'))")
+	return err
+}
+
+// recordLessonChanges gives the lessons the next change number. The caller holds lockLessonChanges.
+func recordLessonChanges(tx bun.Tx, lessonIds []int, ctx context.Context) error {
+	if len(lessonIds) == 0 {
+		return nil
+	}
+	var seq int64
+	err := tx.NewSelect().Model((*dbModels.LessonChange)(nil)).ColumnExpr("COALESCE(MAX(seq), 0) + 1").Scan(ctx, &seq)
+	if err != nil {
+		return err
+	}
+	changes := make([]dbModels.LessonChange, len(lessonIds))
+	for i, lessonId := range lessonIds {
+		changes[i] = dbModels.LessonChange{LessonId: lessonId, Seq: seq}
+	}
+	_, err = tx.NewInsert().Model(&changes).On("CONFLICT (\"lessonId\") DO UPDATE").Set("seq = EXCLUDED.seq").Exec(ctx)
+	return err
+}
+
+// LessonChangeSeq returns the number of the last change of any lesson, 0 if none was recorded.
+func (database *Database) LessonChangeSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := database.DB.NewSelect().Model((*dbModels.LessonChange)(nil)).ColumnExpr("COALESCE(MAX(seq), 0)").Scan(ctx, &seq)
+	return seq, err
+}
+
+// lessonDiff returns the changed material fields of a lesson as {"field": {"old": ..., "new": ...}}.
+// Without old, for a created lesson, every material field is returned with old null.
+func lessonDiff(old *dbModels.Lesson, new dbModels.Lesson) map[string]interface{} {
+	diff := make(map[string]interface{})
+	newFields := materialLessonFields(new)
+	if old == nil {
+		for field, value := range newFields {
+			diff[field] = map[string]interface{}{"old": nil, "new": value}
+		}
+		return diff
+	}
+	for field, oldValue := range materialLessonFields(*old) {
+		if !reflect.DeepEqual(oldValue, newFields[field]) {
+			diff[field] = map[string]interface{}{"old": oldValue, "new": newFields[field]}
+		}
+	}
+	return diff
+}
+
+// materialLessonFields returns the fields of a lesson a change is written for, normalized so equal values compare equal:
+// stored times come back in UTC and empty arrays as nil.
+func materialLessonFields(lesson dbModels.Lesson) map[string]interface{} {
+	ids := func(ids []string) []string {
+		if ids == nil {
+			return []string{}
+		}
+		return ids
+	}
+	return map[string]interface{}{
+		"cancelled":        lesson.Cancelled,
+		"irregular":        lesson.Irregular,
+		"subjects":         ids(lesson.Subjects),
+		"teachers":         ids(lesson.Teachers),
+		"rooms":            ids(lesson.Rooms),
+		"substitutionText": lesson.SubstitutionText,
+		"startTime":        lesson.StartTime.UTC().Format(time.RFC3339),
+		"endTime":          lesson.EndTime.UTC().Format(time.RFC3339),
+	}
+}
+
+// otherLessonFields returns the fields of a lesson without a version that still change its calendar event,
+// normalized like materialLessonFields.
+func otherLessonFields(lesson dbModels.Lesson) map[string]interface{} {
+	ids := func(ids []string) []string {
+		if ids == nil {
+			return []string{}
+		}
+		return ids
+	}
+	return map[string]interface{}{
+		"classes":               ids(lesson.Classes),
+		"additionalInformation": lesson.AdditionalInformation,
+		"lessonText":            lesson.LessonText,
+		"bookingText":           lesson.BookingText,
+		"lessonType":            lesson.LessonType,
+	}
+}
+
+// GetLessonChanges returns the lesson changes after since, filtered like the lessons of the view by the choice of the filter
+// or the default choice of the user. Oldest first by id, so a client continues with the id of the last change as after:
+// the changes of one fetch share their changedAt, a page that ends within them would lose the rest with changedAt alone.
+func (database *Database) GetLessonChanges(filter dbModels.LessonFilter, since time.Time, after int64, limit int, ctx context.Context) (gen.LessonChanges, error) {
+	choice, err := database.resolveLessonChoice(&filter, ctx)
+	if err != nil {
+		return gen.LessonChanges{}, err
+	}
+	var versions []dbModels.LessonVersion
+	query := database.DB.NewSelect().
+		Model(&versions).
+		Relation("Lesson").
+		Where("\"lesson_version\".changed_at > ?", since)
+	if after > 0 {
+		query.Where("\"lesson_version\".id > ?", after)
+	}
+	err = whereLessonChoice(query, filter.User, choice)
+	if err != nil {
+		return gen.LessonChanges{}, err
+	}
+	err = query.Order("lesson_version.id ASC").Limit(limit).Scan(ctx)
+	if err != nil {
+		return gen.LessonChanges{}, err
+	}
+	changes := gen.LessonChanges{Changes: make([]gen.LessonChange, len(versions))}
+	for i, version := range versions {
+		changes.Changes[i] = version.ToGen()
+	}
+	if len(versions) > 0 {
+		changes.Cursor = &versions[len(versions)-1].Id
+	} else if after > 0 {
+		changes.Cursor = &after
+	}
+	return changes, nil
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+)
+
+func TestLessonDiff(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 8, 0, 0, 0, time.UTC)
+	berlin := time.FixedZone("CET", 60*60)
+	lesson := dbModels.Lesson{
+		Id:        1,
+		Subjects:  []string{"10"},
+		Classes:   []string{"5"},
+		Teachers:  []string{"20"},
+		Rooms:     []string{"30"},
+		StartTime: start,
+		EndTime:   start.Add(45 * time.Minute),
+	}
+	change := func(modify func(lesson *dbModels.Lesson)) dbModels.Lesson {
+		changed := lesson
+		modify(&changed)
+		return changed
+	}
+	changed := func(field string, old, new interface{}) map[string]interface{} {
+		return map[string]interface{}{field: map[string]interface{}{"old": old, "new": new}}
+	}
+
+	tests := []struct {
+		name string
+		new  dbModels.Lesson
+		want map[string]interface{}
+	}{
+		{"unchanged", lesson, map[string]interface{}{}},
+		{"cancelled", change(func(l *dbModels.Lesson) { l.Cancelled = true }), changed("cancelled", false, true)},
+		{"room", change(func(l *dbModels.Lesson) { l.Rooms = []string{"31"} }), changed("rooms", []string{"30"}, []string{"31"})},
+		{"teacher removed", change(func(l *dbModels.Lesson) { l.Teachers = nil }), changed("teachers", []string{"20"}, []string{})},
+		{"substitution text", change(func(l *dbModels.Lesson) { l.SubstitutionText = "Vertretung" }), changed("substitutionText", "", "Vertretung")},
+		{"moved", change(func(l *dbModels.Lesson) { l.StartTime = start.Add(time.Hour) }), changed("startTime", "2024-03-04T08:00:00Z", "2024-03-04T09:00:00Z")},
+		{"same time in another zone", change(func(l *dbModels.Lesson) { l.StartTime = start.In(berlin) }), map[string]interface{}{}},
+		{"not material", change(func(l *dbModels.Lesson) { l.Homework = "Read"; l.LastUpdate = start }), map[string]interface{}{}},
+	}
+	for _, test := range tests {
+		if got := lessonDiff(&lesson, test.new); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: lessonDiff = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLessonDiffEmptyArrays(t *testing.T) {
+	stored := dbModels.Lesson{Subjects: []string{}, Rooms: nil}
+	fetched := dbModels.Lesson{Subjects: nil, Rooms: []string{}}
+	if diff := lessonDiff(&stored, fetched); len(diff) != 0 {
+		t.Errorf("nil and empty arrays differ: %v", diff)
+	}
+}
+
+func TestLessonDiffCreated(t *testing.T) {
+	lesson := dbModels.Lesson{Cancelled: true, Rooms: []string{"30"}}
+	diff := lessonDiff(nil, lesson)
+	if len(diff) != len(materialLessonFields(lesson)) {
+		t.Fatalf("lessonDiff of a created lesson has %d fields, want all %d", len(diff), len(materialLessonFields(lesson)))
+	}
+	want := map[string]interface{}{"old": nil, "new": true}
+	if !reflect.DeepEqual(diff["cancelled"], want) {
+		t.Errorf("cancelled = %v, want %v", diff["cancelled"], want)
+	}
+}
+
+// storeLessons writes lessons like a fetch from Untis and returns the ids of the changed lessons.
+func storeLessons(t *testing.T, database Database, lessons []dbModels.Lesson) []int {
+	t.Helper()
+	var changed []int
+	err := database.DB.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := lockLessonChanges(tx, ctx); err != nil {
+			return err
+		}
+		var err error
+		_, changed, err = lessonVersions(tx, lessons, ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(&lessons).On("CONFLICT (id) DO UPDATE").Exec(ctx); err != nil {
+			return err
+		}
+		return recordLessonChanges(tx, changed, ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return changed
+}
+
+func TestLessonChanges(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	start := time.Date(2024, time.March, 4, 8, 0, 0, 0, time.UTC)
+	fetch := func(room string, info string) []dbModels.Lesson {
+		return []dbModels.Lesson{
+			{Id: 1, Rooms: []string{room}, StartTime: start, EndTime: start.Add(45 * time.Minute), AdditionalInformation: info},
+			{Id: 2, Rooms: []string{"7"}, StartTime: start.Add(time.Hour), EndTime: start.Add(105 * time.Minute)},
+		}
+	}
+	lastUpdate := func(id int) time.Time {
+		lesson := dbModels.Lesson{Id: id}
+		if err := database.DB.NewSelect().Model(&lesson).WherePK().Scan(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return lesson.LastUpdate
+	}
+
+	if changed := storeLessons(t, database, fetch("3", "")); !reflect.DeepEqual(changed, []int{1, 2}) {
+		t.Fatalf("first fetch changed %v, want [1 2]", changed)
+	}
+	seq, err := database.LessonChangeSeq(ctx)
+	if err != nil || seq != 1 {
+		t.Fatalf("LessonChangeSeq = %d, %v, want 1", seq, err)
+	}
+	stored := lastUpdate(1)
+
+	// The same lessons again change nothing
+	if changed := storeLessons(t, database, fetch("3", "")); len(changed) != 0 {
+		t.Errorf("unchanged fetch changed %v", changed)
+	}
+	if !lastUpdate(1).Equal(stored) {
+		t.Errorf("LastUpdate of an unchanged lesson moved from %s to %s", stored, lastUpdate(1))
+	}
+	if seq, _ := database.LessonChangeSeq(ctx); seq != 1 {
+		t.Errorf("LessonChangeSeq after an unchanged fetch = %d, want 1", seq)
+	}
+
+	// A note shown in the calendar changes the lesson without a version
+	if changed := storeLessons(t, database, fetch("3", "Bring your books")); !reflect.DeepEqual(changed, []int{1}) {
+		t.Errorf("fetch with a new note changed %v, want [1]", changed)
+	}
+	if changed := storeLessons(t, database, fetch("4", "Bring your books")); !reflect.DeepEqual(changed, []int{1}) {
+		t.Errorf("fetch with a new room changed %v, want [1]", changed)
+	}
+	if seq, _ := database.LessonChangeSeq(ctx); seq != 3 {
+		t.Errorf("LessonChangeSeq = %d, want 3", seq)
+	}
+	var ids []int
+	err = database.DB.NewSelect().Model((*dbModels.LessonChange)(nil)).Column("lessonId").Where("seq > ?", 1).Scan(ctx, &ids)
+	if err != nil || !reflect.DeepEqual(ids, []int{1}) {
+		t.Errorf("lessons changed after 1 = %v, %v, want [1]", ids, err)
+	}
+}
@@ -40,6 +40,14 @@ func NewDatabase(config config.DatabaseConfig) Database {
 	if err != nil {
 		panic(err)
 	}
+	err = database.protectAuditLog(ctx)
+	if err != nil {
+		panic(err)
+	}
+	err = database.LoadSigningKeys(ctx)
+	if err != nil {
+		panic(err)
+	}
 
 	return database
 }
@@ -57,6 +65,30 @@ func (database *Database) createSchema(ctx context.Context) error {
 		&dbModels.Menu{},
 		&dbModels.WeekSubtitle{},
 		&dbModels.UserSetting{},
+		&dbModels.Session{},
+		&dbModels.RefreshToken{},
+		&dbModels.PasswordResetToken{},
+		&dbModels.SigningKey{},
+		&dbModels.UserIdentity{},
+		&dbModels.OIDCLoginState{},
+		&dbModels.UserDataKey{},
+		&dbModels.Throttle{},
+		&dbModels.UserTOTP{},
+		&dbModels.RecoveryCode{},
+		&dbModels.PersonalAccessToken{},
+		&dbModels.AuditEntry{},
+		&dbModels.GuardianLink{},
+		&dbModels.SyncRun{},
+		&dbModels.LessonVersion{},
+		&dbModels.LessonChange{},
+		&dbModels.PushSubscription{},
+		&dbModels.PushPreferences{},
+		&dbModels.PushPending{},
+		&dbModels.Event{},
+		&dbModels.Webhook{},
+		&dbModels.WebhookDelivery{},
+		&dbModels.CalendarFeed{},
+		&dbModels.AppPassword{},
 	}
 
 	for _, model := range models {
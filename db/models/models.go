@@ -18,6 +18,46 @@ var ErrUserNotFound = errors.New("db: User not found")
 var ErrInvalidPassword = errors.New("db: The Password is wrong")
 var ErrInvalidToken = errors.New("db: The Token is invalid")
 var ErrChoiceNotFound = errors.New("db: Choice not found")
+var ErrSessionNotFound = errors.New("db: Session not found")
+var ErrSessionRevoked = errors.New("db: The Session was revoked or is expired")
+var ErrRefreshTokenInvalid = errors.New("db: The refresh token is invalid or expired")
+var ErrRefreshTokenReused = errors.New("db: The refresh token was already used")
+var ErrNoCryptoKey = errors.New("crypto: The token carries no usable crypto key")
+var ErrResetTokenInvalid = errors.New("db: The password reset token is invalid or expired")
+var ErrNoEmail = errors.New("db: The user has no email address")
+var ErrUnknownSigningKey = errors.New("db: Unknown token signing key")
+var ErrOIDCStateInvalid = errors.New("db: The OIDC login state is invalid or expired")
+var ErrIdentityNotLinked = errors.New("db: The identity is not linked to any user")
+var ErrIdentityLinked = errors.New("db: The identity is already linked to another user")
+var ErrIdentityNotFound = errors.New("db: Identity not found")
+var ErrLastLoginMethod = errors.New("db: The identity is the only way the user can log in")
+var ErrUsernameTaken = errors.New("db: The username is already taken")
+var ErrThrottled = errors.New("db: Too many failed attempts")
+var ErrLockoutNotFound = errors.New("db: Lockout not found")
+var ErrSecondFactorRequired = errors.New("db: The login needs a second factor")
+var ErrInvalidSecondFactor = errors.New("db: The TOTP or recovery code is wrong")
+var ErrTOTPEnabled = errors.New("db: TOTP is already enabled")
+var ErrTOTPNotEnabled = errors.New("db: TOTP is not enabled")
+var ErrTOTPRequired = errors.New("db: TOTP is required for the role of the user")
+var ErrAccessTokenNotFound = errors.New("db: Personal access token not found")
+var ErrAccessTokenInvalid = errors.New("db: The personal access token is invalid or expired")
+var ErrInsufficientScope = errors.New("db: The personal access token lacks the scope")
+var ErrGuardianLinkNotFound = errors.New("db: Guardian link not found")
+var ErrGuardianInviteInvalid = errors.New("db: The guardian invite is invalid or expired")
+var ErrGuardianLimitReached = errors.New("db: The student has the maximum number of guardians")
+var ErrGuardianSelf = errors.New("db: A user can not be its own guardian")
+var ErrGuardianAlreadyLinked = errors.New("db: The user is already a guardian of the student")
+var ErrPushSubscriptionNotFound = errors.New("db: Push subscription not found")
+var ErrPushSubscriptionTaken = errors.New("db: The push endpoint is subscribed by another user")
+var ErrWebhookNotFound = errors.New("db: Webhook not found")
+var ErrWebhookLimitReached = errors.New("db: The user has the maximum number of webhooks")
+var ErrWebhookDeliveryNotFound = errors.New("db: Webhook delivery not found")
+var ErrNoDefaultChoice = errors.New("db: The user has no default choice")
+var ErrCalendarFeedNotFound = errors.New("db: Calendar feed not found")
+var ErrCalendarFeedLimitReached = errors.New("db: The user has the maximum number of calendar feeds")
+var ErrAppPasswordInvalid = errors.New("db: The user name or app password is wrong")
+var ErrAppPasswordNotFound = errors.New("db: App password not found")
+var ErrAppPasswordLimitReached = errors.New("db: The user has the maximum number of app passwords")
 
 func getPointerIfNotEmpty[T any](v T) *T {
 	val := reflect.ValueOf(v)
@@ -112,11 +152,6 @@ type User struct {
 }
 
 func (user *User) FromGen(genUser gen.User) User {
-	strClasses := make([]string, len(*genUser.Classes))
-	for i, s := range *genUser.Classes {
-		num := strconv.Itoa(s)
-		strClasses[i] = num
-	}
 	if user == nil {
 		user = &User{}
 	}
@@ -133,7 +168,10 @@ func (user *User) FromGen(genUser gen.User) User {
 		user.DefaultChoiceId = *genUser.DefaultChoice.Id
 	}
 	if genUser.Classes != nil {
-
+		strClasses := make([]string, len(*genUser.Classes))
+		for i, s := range *genUser.Classes {
+			strClasses[i] = strconv.Itoa(s)
+		}
 		user.Classes = strClasses
 	}
 	if genUser.Email != nil {
@@ -254,7 +292,10 @@ var _ bun.BeforeAppendModelHook = (*Lesson)(nil)
 func (l *Lesson) BeforeAppendModel(ctx context.Context, query bun.Query) error {
 	switch query.(type) {
 	case *bun.InsertQuery:
-		l.LastUpdate = time.Now()
+		// A fetch keeps the LastUpdate of lessons without a material change
+		if l.LastUpdate.IsZero() {
+			l.LastUpdate = time.Now()
+		}
 	case *bun.UpdateQuery:
 		l.LastUpdate = time.Now()
 	}
@@ -591,6 +632,12 @@ type LessonFilter struct {
 	User      User
 	StartDate time.Time
 	EndDate   time.Time
+	// Only these lessons, all if empty.
+	LessonIds []int
+	// Without a choice and a default choice, use the lessons of the classes of the user instead of failing with ErrNoDefaultChoice.
+	ClassFallback bool
+	// Only lessons created or materially changed after this LessonChange.Seq, all if 0.
+	ChangedAfter int64
 }
 type Menu struct {
 	bun.BaseModel `bun:"table:menu"`
@@ -627,3 +674,543 @@ type UserSetting struct {
 	SettingName      string `bun:",pk"`
 	SettingsVariable string
 }
+
+// Session model. The Id is used as the jti of the issued JWT.
+type Session struct {
+	bun.BaseModel `bun:"table:session"`
+	Id            string    `bun:"id,pk"`
+	UserId        int       `bun:"userId,notnull"`
+	Device        string    `bun:"device"`
+	IP            string    `bun:"ip"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	LastSeen      time.Time `bun:",nullzero"`
+	ExpiresAt     time.Time `bun:",notnull"`
+	RevokedAt     time.Time `bun:",nullzero"`
+	User          *User     `bun:"rel:belongs-to,join:userId=id"`
+}
+
+func (session *Session) ToGen() gen.Session {
+	return gen.Session{
+		Id:        getPointerIfNotEmpty(session.Id),
+		Device:    getPointerIfNotEmpty(session.Device),
+		Ip:        getPointerIfNotEmpty(session.IP),
+		CreatedAt: getPointerIfNotEmpty(session.CreatedAt),
+		LastSeen:  getPointerIfNotEmpty(session.LastSeen),
+		ExpiresAt: getPointerIfNotEmpty(session.ExpiresAt),
+	}
+}
+
+// RefreshToken model. All refresh tokens issued for one session form a family sharing the SessionId.
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_token"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	SessionId     string    `bun:"sessionId,notnull"`
+	TokenHash     string    `bun:"tokenHash,unique,notnull"`
+	WrappedKey    string    `bun:"wrappedKey"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	ExpiresAt     time.Time `bun:",notnull"`
+	UsedAt        time.Time `bun:",nullzero"`
+	Session       *Session  `bun:"rel:belongs-to,join:sessionId=id"`
+}
+
+// PasswordResetToken model. Only the hash of the token is stored.
+type PasswordResetToken struct {
+	bun.BaseModel `bun:"table:password_reset_token"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	UserId        int       `bun:"userId,notnull"`
+	RequestedBy   int       `bun:"requestedBy"`
+	TokenHash     string    `bun:"tokenHash,unique,notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	ExpiresAt     time.Time `bun:",notnull"`
+	UsedAt        time.Time `bun:",nullzero"`
+	User          *User     `bun:"rel:belongs-to,join:userId=id"`
+}
+
+// SigningKey model. The private key is sealed with the key encryption key, the public key is stored as PKIX DER in base64.
+type SigningKey struct {
+	bun.BaseModel `bun:"table:signing_key"`
+	Id            string    `bun:"id,pk"`
+	Algorithm     string    `bun:"algorithm,notnull"`
+	PrivateKey    string    `bun:"privateKey,notnull"`
+	PublicKey     string    `bun:"publicKey,notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	// Set when the key stopped signing. The key is removed after ExpiresAt.
+	RetiredAt time.Time `bun:",nullzero"`
+	ExpiresAt time.Time `bun:",nullzero"`
+}
+
+// UserIdentity links a user to a subject at an external OpenID Connect identity provider.
+type UserIdentity struct {
+	bun.BaseModel `bun:"table:user_identity"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	UserId        int       `bun:"userId,notnull"`
+	Issuer        string    `bun:"issuer,notnull,unique:issuer_subject"`
+	Subject       string    `bun:"subject,notnull,unique:issuer_subject"`
+	Email         string    `bun:"email"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	LastLogin     time.Time `bun:",nullzero"`
+	User          *User     `bun:"rel:belongs-to,join:userId=id"`
+}
+
+func (identity *UserIdentity) ToGen() gen.UserIdentity {
+	return gen.UserIdentity{
+		Id:        getPointerIfNotEmpty(identity.Id),
+		Issuer:    getPointerIfNotEmpty(identity.Issuer),
+		Subject:   getPointerIfNotEmpty(identity.Subject),
+		Email:     getPointerIfNotEmpty(identity.Email),
+		CreatedAt: getPointerIfNotEmpty(identity.CreatedAt),
+		LastLogin: getPointerIfNotEmpty(identity.LastLogin),
+	}
+}
+
+// OIDCLoginState is kept between the redirect to the identity provider and the callback.
+// LinkKey holds the crypto key of the user that started a link, sealed with a key derived from the state.
+type OIDCLoginState struct {
+	bun.BaseModel `bun:"table:oidc_login_state"`
+	StateHash     string `bun:"stateHash,pk"`
+	// BindingHash is the hash of the random value in the cookie of the browser that started the login.
+	BindingHash string    `bun:"bindingHash,notnull"`
+	Nonce       string    `bun:"nonce,notnull"`
+	Verifier    string    `bun:"verifier,notnull"`
+	Redirect    string    `bun:"redirect"`
+	LinkUserId  int       `bun:"linkUserId"`
+	LinkKey     string    `bun:"linkKey"`
+	ExpiresAt   time.Time `bun:",notnull"`
+}
+
+// UserDataKey is the crypto key of a user that can log in through an identity provider, wrapped with the key encryption key.
+// Users without a password have a random key, for linked users it is the key derived from their password.
+type UserDataKey struct {
+	bun.BaseModel `bun:"table:user_data_key"`
+	UserId        int    `bun:"userId,pk"`
+	WrappedKey    string `bun:"wrappedKey,notnull"`
+}
+
+// Throttle counts the failed attempts of an account or ip address in a scope like "login".
+// Key is "user:<name or id>" or "ip:<address>".
+type Throttle struct {
+	bun.BaseModel `bun:"table:throttle"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	Scope         string    `bun:"scope,notnull,unique:scope_key"`
+	Key           string    `bun:"key,notnull,unique:scope_key"`
+	Failures      int       `bun:"failures,notnull"`
+	LastFailure   time.Time `bun:",nullzero"`
+	BlockedUntil  time.Time `bun:",nullzero"`
+	Locked        bool      `bun:"locked,notnull,default:false"`
+}
+
+func (throttle *Throttle) ToGen() gen.Lockout {
+	return gen.Lockout{
+		Id:           getPointerIfNotEmpty(throttle.Id),
+		Scope:        getPointerIfNotEmpty(throttle.Scope),
+		Key:          getPointerIfNotEmpty(throttle.Key),
+		Failures:     getPointerIfNotEmpty(throttle.Failures),
+		LastFailure:  getPointerIfNotEmpty(throttle.LastFailure),
+		BlockedUntil: getPointerIfNotEmpty(throttle.BlockedUntil),
+		Locked:       &throttle.Locked,
+	}
+}
+
+// UserTOTP is the TOTP secret of a user, sealed with the key encryption key.
+// LastUsedStep is the time step of the last accepted code, so every code can only be used once.
+type UserTOTP struct {
+	bun.BaseModel `bun:"table:user_totp"`
+	UserId        int       `bun:"userId,pk"`
+	Secret        string    `bun:"secret,notnull"`
+	Confirmed     bool      `bun:"confirmed,notnull,default:false"`
+	LastUsedStep  int64     `bun:"lastUsedStep"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// RecoveryCode is a one time code to log in without the TOTP device. Only the hash is stored.
+type RecoveryCode struct {
+	bun.BaseModel `bun:"table:recovery_code"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	UserId        int       `bun:"userId,notnull"`
+	CodeHash      string    `bun:"codeHash,notnull"`
+	UsedAt        time.Time `bun:",nullzero"`
+}
+
+// PersonalAccessToken lets scripts authenticate without a session. Only the hash of the token is stored.
+// EncryptedKey is the Untis crypto key of the user, sealed with a key derived from the token itself.
+type PersonalAccessToken struct {
+	bun.BaseModel `bun:"table:personal_access_token"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	UserId        int       `bun:"userId,notnull"`
+	Name          string    `bun:"name,notnull"`
+	Prefix        string    `bun:"prefix,notnull"`
+	TokenHash     string    `bun:"tokenHash,unique,notnull"`
+	Scopes        []string  `bun:"scopes,array"`
+	EncryptedKey  string    `bun:"encryptedKey"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	ExpiresAt     time.Time `bun:",nullzero"`
+	LastUsedAt    time.Time `bun:",nullzero"`
+	LastUsedIP    string    `bun:"last_used_ip"`
+	User          *User     `bun:"rel:belongs-to,join:userId=id"`
+}
+
+func (token *PersonalAccessToken) ToGen() gen.PersonalAccessToken {
+	scopes := make([]gen.TokenScope, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = gen.TokenScope(scope)
+	}
+	return gen.PersonalAccessToken{
+		Id:         getPointerIfNotEmpty(token.Id),
+		Name:       getPointerIfNotEmpty(token.Name),
+		Prefix:     getPointerIfNotEmpty(token.Prefix),
+		Scopes:     &scopes,
+		CreatedAt:  getPointerIfNotEmpty(token.CreatedAt),
+		ExpiresAt:  getPointerIfNotEmpty(token.ExpiresAt),
+		LastUsedAt: getPointerIfNotEmpty(token.LastUsedAt),
+		LastUsedIp: getPointerIfNotEmpty(token.LastUsedIP),
+	}
+}
+
+// AuditEntry is an entry of the append only audit log. Hash covers the entry and the hash of the previous
+// entry, so a changed or removed entry breaks the chain.
+type AuditEntry struct {
+	bun.BaseModel `bun:"table:audit_log"`
+	Id            int64                  `bun:"id,pk,autoincrement,notnull"`
+	CreatedAt     time.Time              `bun:",notnull"`
+	ActorId       int                    `bun:"actorId,nullzero"`
+	ActorName     string                 `bun:"actorName"`
+	Action        string                 `bun:"action,notnull"`
+	TargetType    string                 `bun:"targetType"`
+	TargetId      string                 `bun:"targetId"`
+	IP            string                 `bun:"ip"`
+	Diff          map[string]interface{} `bun:"diff,type:jsonb"`
+	PrevHash      string                 `bun:"prevHash,notnull"`
+	Hash          string                 `bun:"hash,unique,notnull"`
+}
+
+func (entry *AuditEntry) ToGen() gen.AuditEntry {
+	var diff *map[string]interface{}
+	if len(entry.Diff) > 0 {
+		diff = &entry.Diff
+	}
+	return gen.AuditEntry{
+		Id:         entry.Id,
+		CreatedAt:  entry.CreatedAt,
+		ActorId:    getPointerIfNotEmpty(entry.ActorId),
+		ActorName:  getPointerIfNotEmpty(entry.ActorName),
+		Action:     entry.Action,
+		TargetType: getPointerIfNotEmpty(entry.TargetType),
+		TargetId:   getPointerIfNotEmpty(entry.TargetId),
+		Ip:         getPointerIfNotEmpty(entry.IP),
+		Diff:       diff,
+		PrevHash:   entry.PrevHash,
+		Hash:       entry.Hash,
+	}
+}
+
+// LessonChange is the number of the last creation or material change of a lesson. The numbers only grow,
+// the CalDAV sync-tokens carry the last one.
+type LessonChange struct {
+	bun.BaseModel `bun:"table:lesson_change"`
+	LessonId      int   `bun:"lessonId,pk"`
+	Seq           int64 `bun:"seq,notnull"`
+}
+
+// LessonVersion is a material change of a lesson, written when a fetch from Untis changes the stored lesson.
+type LessonVersion struct {
+	bun.BaseModel `bun:"table:lesson_version"`
+	Id            int64     `bun:"id,pk,autoincrement,notnull"`
+	LessonId      int       `bun:"lessonId,notnull"`
+	ChangedAt     time.Time `bun:",notnull"`
+	// "created" or "updated"
+	Kind string `bun:"kind,notnull"`
+	// Changed fields as {"field": {"old": ..., "new": ...}}
+	Diff   map[string]interface{} `bun:"diff,type:jsonb"`
+	Lesson *Lesson                `bun:"rel:belongs-to,join:lessonId=id"`
+}
+
+func (version *LessonVersion) ToGen() gen.LessonChange {
+	change := gen.LessonChange{
+		Id:        version.Id,
+		LessonId:  version.LessonId,
+		ChangedAt: version.ChangedAt,
+		Kind:      gen.LessonChangeKind(version.Kind),
+		Diff:      version.Diff,
+	}
+	if version.Lesson != nil {
+		lesson := version.Lesson.ToGen()
+		change.Lesson = &lesson
+	}
+	return change
+}
+
+// GuardianLink gives a guardian read access to the timetable of a student.
+// It starts as an invite of the student: GuardianId is unset and only the hash of the invite code is stored.
+type GuardianLink struct {
+	bun.BaseModel   `bun:"table:guardian_link"`
+	Id              int       `bun:"id,pk,autoincrement,notnull"`
+	StudentId       int       `bun:"studentId,notnull"`
+	GuardianId      int       `bun:"guardianId,nullzero"`
+	InviteHash      string    `bun:"inviteHash,unique,nullzero"`
+	InviteExpiresAt time.Time `bun:",nullzero"`
+	CreatedAt       time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	AcceptedAt      time.Time `bun:",nullzero"`
+	Student         *User     `bun:"rel:belongs-to,join:studentId=id"`
+	Guardian        *User     `bun:"rel:belongs-to,join:guardianId=id"`
+}
+
+func (link *GuardianLink) ToGen() gen.GuardianLink {
+	pending := link.AcceptedAt.IsZero()
+	genLink := gen.GuardianLink{
+		Id:         getPointerIfNotEmpty(link.Id),
+		StudentId:  getPointerIfNotEmpty(link.StudentId),
+		GuardianId: getPointerIfNotEmpty(link.GuardianId),
+		CreatedAt:  getPointerIfNotEmpty(link.CreatedAt),
+		AcceptedAt: getPointerIfNotEmpty(link.AcceptedAt),
+		Pending:    &pending,
+	}
+	if pending {
+		genLink.InviteExpiresAt = getPointerIfNotEmpty(link.InviteExpiresAt)
+	}
+	if link.Student != nil {
+		genLink.StudentName = getPointerIfNotEmpty(link.Student.Name)
+	}
+	if link.Guardian != nil {
+		genLink.GuardianName = getPointerIfNotEmpty(link.Guardian.Name)
+	}
+	return genLink
+}
+
+// SyncRun is a run of a scheduled Untis sync job.
+type SyncRun struct {
+	bun.BaseModel `bun:"table:sync_run"`
+	Id            int64     `bun:"id,pk,autoincrement,notnull"`
+	Job           string    `bun:"job,notnull"`
+	StartedAt     time.Time `bun:",notnull"`
+	FinishedAt    time.Time `bun:",nullzero"`
+	Status        string    `bun:"status,notnull"`
+	Error         string    `bun:"error"`
+}
+
+func (run *SyncRun) ToGen() gen.SyncRun {
+	return gen.SyncRun{
+		Id:         run.Id,
+		Job:        run.Job,
+		StartedAt:  run.StartedAt,
+		FinishedAt: getPointerIfNotEmpty(run.FinishedAt),
+		Status:     gen.SyncRunStatus(run.Status),
+		Error:      getPointerIfNotEmpty(run.Error),
+	}
+}
+
+// PushSubscription is a browser subscribed to the push notifications of a user. P256dh and Auth are the keys
+// the notifications are encrypted for.
+type PushSubscription struct {
+	bun.BaseModel `bun:"table:push_subscription"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	UserId        int       `bun:"userId,notnull"`
+	Endpoint      string    `bun:"endpoint,unique,notnull"`
+	P256dh        string    `bun:"p256dh,notnull"`
+	Auth          string    `bun:"auth,notnull"`
+	UserAgent     string    `bun:"userAgent"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+func (subscription *PushSubscription) ToGen() gen.PushSubscription {
+	return gen.PushSubscription{
+		Id:        subscription.Id,
+		Endpoint:  subscription.Endpoint,
+		UserAgent: getPointerIfNotEmpty(subscription.UserAgent),
+		CreatedAt: getPointerIfNotEmpty(subscription.CreatedAt),
+	}
+}
+
+// PushPreferences are the notification settings of a user. Users without a row get DefaultPushPreferences.
+type PushPreferences struct {
+	bun.BaseModel `bun:"table:push_preferences"`
+	UserId        int  `bun:"userId,pk"`
+	Cancelled     bool `bun:"cancelled,notnull"`
+	Rooms         bool `bun:"rooms,notnull"`
+	Teachers      bool `bun:"teachers,notnull"`
+	Substitutions bool `bun:"substitutions,notnull"`
+	// "HH:MM", both empty for no quiet hours
+	QuietHoursStart string `bun:"quietHoursStart"`
+	QuietHoursEnd   string `bun:"quietHoursEnd"`
+}
+
+// PushPending is a notification held back by the quiet hours of a user, it is sent when they end.
+// Further changes of the lesson in the meantime are merged into it.
+type PushPending struct {
+	bun.BaseModel `bun:"table:push_pending"`
+	UserId        int       `bun:"userId,pk"`
+	LessonId      int       `bun:"lessonId,pk"`
+	Types         []string  `bun:"types,notnull"`
+	SendAt        time.Time `bun:",notnull"`
+}
+
+// DefaultPushPreferences notifies about every change at any time.
+func DefaultPushPreferences(userId int) PushPreferences {
+	return PushPreferences{UserId: userId, Cancelled: true, Rooms: true, Teachers: true, Substitutions: true}
+}
+
+func (preferences *PushPreferences) ToGen() gen.PushPreferences {
+	return gen.PushPreferences{
+		Cancelled:       preferences.Cancelled,
+		Rooms:           preferences.Rooms,
+		Teachers:        preferences.Teachers,
+		Substitutions:   preferences.Substitutions,
+		QuietHoursStart: getPointerIfNotEmpty(preferences.QuietHoursStart),
+		QuietHoursEnd:   getPointerIfNotEmpty(preferences.QuietHoursEnd),
+	}
+}
+
+func (preferences *PushPreferences) FromGen(genPreferences gen.PushPreferences) PushPreferences {
+	if preferences == nil {
+		preferences = &PushPreferences{}
+	}
+	preferences.Cancelled = genPreferences.Cancelled
+	preferences.Rooms = genPreferences.Rooms
+	preferences.Teachers = genPreferences.Teachers
+	preferences.Substitutions = genPreferences.Substitutions
+	preferences.QuietHoursStart = ""
+	if genPreferences.QuietHoursStart != nil {
+		preferences.QuietHoursStart = *genPreferences.QuietHoursStart
+	}
+	preferences.QuietHoursEnd = ""
+	if genPreferences.QuietHoursEnd != nil {
+		preferences.QuietHoursEnd = *genPreferences.QuietHoursEnd
+	}
+	return *preferences
+}
+
+// Event is a change of the stored data, e.g. a changed lesson or an updated menu. The events are delivered to the
+// webhooks that subscribed to their type.
+type Event struct {
+	bun.BaseModel `bun:"table:event"`
+	Id            int64     `bun:"id,pk,autoincrement,notnull"`
+	Type          string    `bun:"type,notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	// The changed lesson of lesson.changed events, to match it against the choice of a user.
+	LessonId int                    `bun:"lessonId,nullzero"`
+	Data     map[string]interface{} `bun:"data,type:jsonb"`
+}
+
+// Webhook is an url of a user that receives the events it subscribed to as signed POST requests.
+type Webhook struct {
+	bun.BaseModel `bun:"table:webhook"`
+	Id            int    `bun:"id,pk,autoincrement,notnull"`
+	UserId        int    `bun:"userId,notnull"`
+	URL           string `bun:"url,notnull"`
+	// The signing secret, sealed with the key encryption key. The receiver needs it in plain text to verify the
+	// signatures, so it can not be hashed.
+	SealedSecret string    `bun:"sealedSecret,notnull"`
+	Events       []string  `bun:"events,array"`
+	Description  string    `bun:"description"`
+	Active       bool      `bun:"active,notnull"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	User         *User     `bun:"rel:belongs-to,join:userId=id"`
+}
+
+func (webhook *Webhook) ToGen() gen.Webhook {
+	events := make([]gen.WebhookEvent, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = gen.WebhookEvent(event)
+	}
+	return gen.Webhook{
+		Id:          webhook.Id,
+		UserId:      webhook.UserId,
+		Url:         webhook.URL,
+		Events:      events,
+		Description: getPointerIfNotEmpty(webhook.Description),
+		Active:      webhook.Active,
+		CreatedAt:   getPointerIfNotEmpty(webhook.CreatedAt),
+	}
+}
+
+// WebhookDelivery is an event queued for a webhook. Failed deliveries are retried with a growing delay until
+// Webhooks.MaxAttempts, then they are dead until retried by hand.
+type WebhookDelivery struct {
+	bun.BaseModel `bun:"table:webhook_delivery"`
+	Id            int64 `bun:"id,pk,autoincrement,notnull"`
+	WebhookId     int   `bun:"webhookId,notnull"`
+	EventId       int64 `bun:"eventId,notnull"`
+	// "pending", "succeeded" or "dead"
+	Status         string    `bun:"status,notnull"`
+	Attempts       int       `bun:"attempts,notnull"`
+	NextAttemptAt  time.Time `bun:",nullzero"`
+	LastError      string    `bun:"lastError"`
+	LastStatusCode int       `bun:"lastStatusCode,nullzero"`
+	CreatedAt      time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeliveredAt    time.Time `bun:",nullzero"`
+	Event          *Event    `bun:"rel:belongs-to,join:eventId=id"`
+	Webhook        *Webhook  `bun:"rel:belongs-to,join:webhookId=id"`
+}
+
+func (delivery *WebhookDelivery) ToGen() gen.WebhookDelivery {
+	genDelivery := gen.WebhookDelivery{
+		Id:             delivery.Id,
+		WebhookId:      delivery.WebhookId,
+		EventId:        delivery.EventId,
+		Status:         gen.WebhookDeliveryStatus(delivery.Status),
+		Attempts:       delivery.Attempts,
+		LastError:      getPointerIfNotEmpty(delivery.LastError),
+		LastStatusCode: getPointerIfNotEmpty(delivery.LastStatusCode),
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    getPointerIfNotEmpty(delivery.DeliveredAt),
+	}
+	if delivery.Status == string(gen.WebhookDeliveryStatusPending) {
+		genDelivery.NextAttemptAt = getPointerIfNotEmpty(delivery.NextAttemptAt)
+	}
+	if delivery.Event != nil {
+		genDelivery.EventType = gen.WebhookEvent(delivery.Event.Type)
+	}
+	return genDelivery
+}
+
+// CalendarFeed is a secret link to the lessons of a user as iCalendar file. Only the hash of the token is stored.
+type CalendarFeed struct {
+	bun.BaseModel `bun:"table:calendar_feed"`
+	Id            int    `bun:"id,pk,autoincrement,notnull"`
+	UserId        int    `bun:"userId,notnull"`
+	Name          string `bun:"name"`
+	// The choice whose lessons are shown, 0 for the default choice of the user at the time of the request.
+	ChoiceId     int       `bun:"choiceId,nullzero"`
+	TokenHash    string    `bun:"tokenHash,unique,notnull"`
+	Prefix       string    `bun:"prefix,notnull"`
+	IncludeMenus bool      `bun:"includeMenus,notnull"`
+	IncludeWeeks bool      `bun:"includeWeeks,notnull"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	LastUsedAt   time.Time `bun:",nullzero"`
+}
+
+func (feed *CalendarFeed) ToGen() gen.CalendarFeed {
+	return gen.CalendarFeed{
+		Id:           feed.Id,
+		Name:         getPointerIfNotEmpty(feed.Name),
+		ChoiceId:     getPointerIfNotEmpty(feed.ChoiceId),
+		Prefix:       feed.Prefix,
+		IncludeMenus: feed.IncludeMenus,
+		IncludeWeeks: feed.IncludeWeeks,
+		CreatedAt:    getPointerIfNotEmpty(feed.CreatedAt),
+		LastUsedAt:   getPointerIfNotEmpty(feed.LastUsedAt),
+	}
+}
+
+// AppPassword lets a CalDAV client read the timetable of a user. Only the hash of the password is stored.
+type AppPassword struct {
+	bun.BaseModel `bun:"table:app_password"`
+	Id            int       `bun:"id,pk,autoincrement,notnull"`
+	UserId        int       `bun:"userId,notnull"`
+	Name          string    `bun:"name"`
+	Prefix        string    `bun:"prefix,notnull"`
+	PasswordHash  string    `bun:"passwordHash,unique,notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	LastUsedAt    time.Time `bun:",nullzero"`
+	LastUsedIP    string    `bun:"last_used_ip"`
+}
+
+func (password *AppPassword) ToGen() gen.AppPassword {
+	return gen.AppPassword{
+		Id:         password.Id,
+		Name:       getPointerIfNotEmpty(password.Name),
+		Prefix:     password.Prefix,
+		CreatedAt:  getPointerIfNotEmpty(password.CreatedAt),
+		LastUsedAt: getPointerIfNotEmpty(password.LastUsedAt),
+		LastUsedIp: getPointerIfNotEmpty(password.LastUsedIP),
+	}
+}
@@ -0,0 +1,372 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/sso"
+	"github.com/uptrace/bun"
+)
+
+// oidcStateLifetime is the time a user has to log in at the identity provider.
+const oidcStateLifetime = 10 * time.Minute
+
+// oidcLinkKeyPurpose is the hkdf info used to derive the key that seals the crypto key of a user while linking an identity.
+const oidcLinkKeyPurpose = "tmf oidc link crypto key"
+
+// dataKeyAssociatedData binds a wrapped data key to its user.
+func dataKeyAssociatedData(userId int) []byte {
+	return []byte(fmt.Sprintf("tmf user data key|%d", userId))
+}
+
+// storeDataKey wraps the crypto key of a user with the key encryption key and stores it.
+func storeDataKey(idb bun.IDB, userId int, key []byte, ctx context.Context) error {
+	wrapped, err := sealKey(keyEncryptionKey(), key, dataKeyAssociatedData(userId))
+	if err != nil {
+		return err
+	}
+	dataKey := dbModels.UserDataKey{UserId: userId, WrappedKey: wrapped}
+	_, err = idb.NewInsert().
+		Model(&dataKey).
+		On("CONFLICT (\"userId\") DO UPDATE").
+		Exec(ctx)
+	return err
+}
+
+// updateDataKey replaces the stored data key of a user, if the user has one.
+// It keeps the key of linked users in sync with the key derived from their password.
+func updateDataKey(idb bun.IDB, userId int, key []byte, ctx context.Context) error {
+	exists, err := idb.NewSelect().
+		Model((*dbModels.UserDataKey)(nil)).
+		Where("\"userId\" = ?", userId).
+		Exists(ctx)
+	if err != nil || !exists {
+		return err
+	}
+	return storeDataKey(idb, userId, key, ctx)
+}
+
+// loadDataKey returns the unwrapped data key of a user.
+func loadDataKey(idb bun.IDB, userId int, ctx context.Context) ([]byte, error) {
+	dataKey := dbModels.UserDataKey{UserId: userId}
+	err := idb.NewSelect().Model(&dataKey).WherePK().Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return openKey(keyEncryptionKey(), dataKey.WrappedKey, dataKeyAssociatedData(userId))
+}
+
+// OIDCLoginState is handed to the identity provider. State and Nonce are sent in the authorization request,
+// Verifier is the PKCE code verifier. Binding is stored in a cookie of the browser that started the login,
+// the callback is only accepted from this browser.
+type OIDCLoginState struct {
+	State    string
+	Nonce    string
+	Verifier string
+	Binding  string
+}
+
+// CreateOIDCLoginState stores the state of a login at the identity provider. Only a hash of the state is stored.
+// If linkUserId is not 0, the callback links the identity to this user. cryptoKey is the key of this user.
+func (database *Database) CreateOIDCLoginState(redirect string, linkUserId int, cryptoKey []byte, ctx context.Context) (OIDCLoginState, error) {
+	var state OIDCLoginState
+	var err error
+	if state.State, err = randomToken(32); err != nil {
+		return OIDCLoginState{}, err
+	}
+	if state.Nonce, err = randomToken(16); err != nil {
+		return OIDCLoginState{}, err
+	}
+	if state.Verifier, err = randomToken(32); err != nil {
+		return OIDCLoginState{}, err
+	}
+	if state.Binding, err = randomToken(32); err != nil {
+		return OIDCLoginState{}, err
+	}
+	entry := dbModels.OIDCLoginState{
+		StateHash:   generateSHA256Hash(state.State),
+		BindingHash: generateSHA256Hash(state.Binding),
+		Nonce:       state.Nonce,
+		Verifier:    state.Verifier,
+		Redirect:    redirect,
+		LinkUserId:  linkUserId,
+		ExpiresAt:   time.Now().Add(oidcStateLifetime),
+	}
+	if linkUserId != 0 {
+		wrappingKey, err := tokenKey(state.State, oidcLinkKeyPurpose)
+		if err != nil {
+			return OIDCLoginState{}, err
+		}
+		entry.LinkKey, err = sealKey(wrappingKey, cryptoKey, dataKeyAssociatedData(linkUserId))
+		if err != nil {
+			return OIDCLoginState{}, err
+		}
+	}
+	// Remove states of logins that were never finished
+	_, err = database.DB.NewDelete().
+		Model((*dbModels.OIDCLoginState)(nil)).
+		Where("expires_at < ?", time.Now()).
+		Exec(ctx)
+	if err != nil {
+		return OIDCLoginState{}, err
+	}
+	_, err = database.DB.NewInsert().Model(&entry).Exec(ctx)
+	if err != nil {
+		return OIDCLoginState{}, err
+	}
+	return state, nil
+}
+
+// ConsumeOIDCLoginState returns and deletes the stored state. Every state can only be used once.
+// binding is the value of the cookie set when the login started. A state presented by another browser is
+// rejected and stays unused.
+func (database *Database) ConsumeOIDCLoginState(state string, binding string, ctx context.Context) (dbModels.OIDCLoginState, error) {
+	if binding == "" {
+		return dbModels.OIDCLoginState{}, dbModels.ErrOIDCStateInvalid
+	}
+	var entry dbModels.OIDCLoginState
+	_, err := database.DB.NewDelete().
+		Model(&entry).
+		Where("\"stateHash\" = ?", generateSHA256Hash(state)).
+		Where("\"bindingHash\" = ?", generateSHA256Hash(binding)).
+		Returning("*").
+		Exec(ctx, &entry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dbModels.OIDCLoginState{}, dbModels.ErrOIDCStateInvalid
+		}
+		return dbModels.OIDCLoginState{}, err
+	}
+	if entry.StateHash == "" || entry.ExpiresAt.Before(time.Now()) {
+		return dbModels.OIDCLoginState{}, dbModels.ErrOIDCStateInvalid
+	}
+	return entry, nil
+}
+
+// LoginWithIdentity starts a session for the user linked to the identity.
+// Unknown identities get a new account if OIDC.AutoProvision is enabled.
+func (database *Database) LoginWithIdentity(identity sso.Identity, device string, ip string, ctx context.Context) (SessionTokens, gen.User, error) {
+	var linked dbModels.UserIdentity
+	err := database.DB.NewSelect().
+		Model(&linked).
+		Where("issuer = ?", identity.Issuer).
+		Where("subject = ?", identity.Subject).
+		Scan(ctx)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return SessionTokens{}, gen.User{}, err
+		}
+		if !config.Config.OIDC.AutoProvision {
+			return SessionTokens{}, gen.User{}, dbModels.ErrIdentityNotLinked
+		}
+		linked, err = database.provisionUser(identity, ctx)
+		if err != nil {
+			return SessionTokens{}, gen.User{}, err
+		}
+	}
+
+	user := dbModels.User{Id: linked.UserId}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	cryptoKey, err := loadDataKey(database.DB, user.Id, ctx)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to open the data key of user %d, creating a new one: %s", user.Id, err.Error())
+		}
+		// Secrets encrypted with the lost key can not be read anymore and have to be entered again.
+		cryptoKey = make([]byte, 32)
+		if _, err := rand.Read(cryptoKey); err != nil {
+			return SessionTokens{}, gen.User{}, err
+		}
+		if err := storeDataKey(database.DB, user.Id, cryptoKey, ctx); err != nil {
+			return SessionTokens{}, gen.User{}, err
+		}
+	}
+	linked.LastLogin = time.Now()
+	linked.Email = identity.Email
+	_, err = database.DB.NewUpdate().Model(&linked).Column("last_login", "email").WherePK().Exec(ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+
+	// The identity provider only replaces the password, a second factor is still required
+	tokens, err := database.loginSession(user, cryptoKey, device, ip, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	return tokens, user.ToGen(), nil
+}
+
+// provisionUser creates an account without password for an identity, together with a random data key.
+func (database *Database) provisionUser(identity sso.Identity, ctx context.Context) (dbModels.UserIdentity, error) {
+	name := identity.Username
+	if name == "" {
+		name = identity.Subject
+	}
+	exists, err := database.DB.NewSelect().
+		Model((*dbModels.User)(nil)).
+		Where("\"user\".\"name\" = ?", name).
+		Exists(ctx)
+	if err != nil {
+		return dbModels.UserIdentity{}, err
+	}
+	if exists {
+		// Accounts are only linked by an explicit request of the logged in user.
+		return dbModels.UserIdentity{}, dbModels.ErrUsernameTaken
+	}
+	cryptoKey := make([]byte, 32)
+	if _, err := rand.Read(cryptoKey); err != nil {
+		return dbModels.UserIdentity{}, err
+	}
+	user := dbModels.User{
+		Name: name,
+		Role: identity.Role,
+	}
+	if identity.EmailVerified {
+		user.Email = identity.Email
+	}
+	linked := dbModels.UserIdentity{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		Email:   identity.Email,
+	}
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().Model(&user).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		linked.UserId = user.Id
+		_, err = tx.NewInsert().Model(&linked).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return storeDataKey(tx, user.Id, cryptoKey, ctx)
+	})
+	if err != nil {
+		return dbModels.UserIdentity{}, err
+	}
+	log.Printf("Provisioned user %d (%s) for identity %s at %s", user.Id, user.Name, identity.Subject, identity.Issuer)
+	return linked, nil
+}
+
+// LinkIdentity links an identity to the user that started the link. The crypto key of the user is stored wrapped
+// with the key encryption key, so the secret settings stay readable after a login through the identity provider.
+func (database *Database) LinkIdentity(identity sso.Identity, loginState dbModels.OIDCLoginState, state string, ctx context.Context) (gen.User, error) {
+	wrappingKey, err := tokenKey(state, oidcLinkKeyPurpose)
+	if err != nil {
+		return gen.User{}, err
+	}
+	cryptoKey, err := openKey(wrappingKey, loginState.LinkKey, dataKeyAssociatedData(loginState.LinkUserId))
+	if err != nil {
+		return gen.User{}, err
+	}
+	user := dbModels.User{Id: loginState.LinkUserId}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return gen.User{}, err
+	}
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var existing dbModels.UserIdentity
+		err := tx.NewSelect().
+			Model(&existing).
+			Where("issuer = ?", identity.Issuer).
+			Where("subject = ?", identity.Subject).
+			Scan(ctx)
+		if err == nil {
+			if existing.UserId != user.Id {
+				return dbModels.ErrIdentityLinked
+			}
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		linked := dbModels.UserIdentity{
+			UserId:  user.Id,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   identity.Email,
+		}
+		_, err = tx.NewInsert().Model(&linked).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return storeDataKey(tx, user.Id, cryptoKey, ctx)
+	})
+	if err != nil {
+		return gen.User{}, err
+	}
+	return user.ToGen(), nil
+}
+
+// GetIdentities returns the identities linked to a user.
+func (database *Database) GetIdentities(userId int, ctx context.Context) ([]gen.UserIdentity, error) {
+	var identities []dbModels.UserIdentity
+	err := database.DB.NewSelect().
+		Model(&identities).
+		Where("\"userId\" = ?", userId).
+		Order("created_at").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genIdentities := make([]gen.UserIdentity, len(identities))
+	for i, identity := range identities {
+		genIdentities[i] = identity.ToGen()
+	}
+	return genIdentities, nil
+}
+
+// UnlinkIdentity removes an identity from a user. The last identity of a user without password can not be removed.
+// Once a user has no identity left, the stored data key is removed as well.
+func (database *Database) UnlinkIdentity(userId int, identityId int, ctx context.Context) error {
+	return database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		user := dbModels.User{Id: userId}
+		err := tx.NewSelect().Model(&user).WherePK().For("UPDATE").Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrUserNotFound
+			}
+			return err
+		}
+		count, err := tx.NewSelect().
+			Model((*dbModels.UserIdentity)(nil)).
+			Where("\"userId\" = ?", userId).
+			Count(ctx)
+		if err != nil {
+			return err
+		}
+		res, err := tx.NewDelete().
+			Model((*dbModels.UserIdentity)(nil)).
+			Where("id = ?", identityId).
+			Where("\"userId\" = ?", userId).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return dbModels.ErrIdentityNotFound
+		}
+		if count > 1 {
+			return nil
+		}
+		if user.PwdHash == "" {
+			return dbModels.ErrLastLoginMethod
+		}
+		_, err = tx.NewDelete().
+			Model((*dbModels.UserDataKey)(nil)).
+			Where("\"userId\" = ?", userId).
+			Exec(ctx)
+		return err
+	})
+}
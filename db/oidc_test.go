@@ -0,0 +1,168 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/db/dbtest"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/sso"
+)
+
+// openTestDatabase connects to the test database, see package dbtest.
+func openTestDatabase(t *testing.T) Database {
+	t.Helper()
+	database := NewDatabase(dbtest.Config(t))
+	t.Cleanup(func() { database.DB.Close() })
+	return database
+}
+
+// withAutoProvision sets OIDC.AutoProvision for a test.
+func withAutoProvision(t *testing.T, enabled bool) {
+	previous := config.Config.OIDC.AutoProvision
+	t.Cleanup(func() { config.Config.OIDC.AutoProvision = previous })
+	config.Config.OIDC.AutoProvision = enabled
+}
+
+func TestLoginWithIdentity(t *testing.T) {
+	database := openTestDatabase(t)
+	withAutoProvision(t, true)
+	ctx := context.Background()
+	identity := sso.Identity{Issuer: "https://idp.example", Subject: "1234", Username: "idp.teacher", Role: "teacher"}
+
+	tokens, user, err := database.LoginWithIdentity(identity, "test", "127.0.0.1", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.AccessToken == "" || user.Name != "idp.teacher" || user.Role == nil || *user.Role != "teacher" {
+		t.Fatalf("provisioned user = %+v, tokens = %+v", user, tokens)
+	}
+	_, again, err := database.LoginWithIdentity(identity, "test", "127.0.0.1", ctx)
+	if err != nil || *again.Id != *user.Id {
+		t.Errorf("second login = user %v, %v, want user %d", again.Id, err, *user.Id)
+	}
+
+	// Accounts are never linked by name
+	taken := sso.Identity{Issuer: "https://idp.example", Subject: "5678", Username: "idp.teacher"}
+	if _, _, err := database.LoginWithIdentity(taken, "test", "127.0.0.1", ctx); !errors.Is(err, dbModels.ErrUsernameTaken) {
+		t.Errorf("login with a taken name: error = %v, want %v", err, dbModels.ErrUsernameTaken)
+	}
+
+	withAutoProvision(t, false)
+	unknown := sso.Identity{Issuer: "https://idp.example", Subject: "9999", Username: "idp.unknown"}
+	if _, _, err := database.LoginWithIdentity(unknown, "test", "127.0.0.1", ctx); !errors.Is(err, dbModels.ErrIdentityNotLinked) {
+		t.Errorf("login without provisioning: error = %v, want %v", err, dbModels.ErrIdentityNotLinked)
+	}
+}
+
+func TestLoginWithIdentityAfterDelete(t *testing.T) {
+	database := openTestDatabase(t)
+	withAutoProvision(t, true)
+	ctx := context.Background()
+	identity := sso.Identity{Issuer: "https://idp.example", Subject: "1234", Username: "idp.student", Role: "student"}
+
+	_, user, err := database.LoginWithIdentity(identity, "test", "127.0.0.1", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DeleteUserByID(*user.Id, ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, provisioned, err := database.LoginWithIdentity(identity, "test", "127.0.0.1", ctx)
+	if err != nil {
+		t.Fatalf("login after the user was deleted: %s", err)
+	}
+	if *provisioned.Id == *user.Id {
+		t.Errorf("the deleted user %d was logged in again", *user.Id)
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	database := openTestDatabase(t)
+	withAutoProvision(t, false)
+	ctx := context.Background()
+	password := "correct horse battery"
+	user, err := database.CreateUser(gen.User{Name: "link.student"}, password, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := database.CreateUser(gen.User{Name: "link.other"}, password, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := sso.Identity{Issuer: "https://idp.example", Subject: "1234", Username: "someone.else"}
+	cryptoKey := deriveKey(password)
+
+	state, err := database.CreateOIDCLoginState("/", *user.Id, cryptoKey, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.ConsumeOIDCLoginState(state.State, "binding of another browser", ctx); !errors.Is(err, dbModels.ErrOIDCStateInvalid) {
+		t.Errorf("state consumed with the wrong binding: error = %v", err)
+	}
+	loginState, err := database.ConsumeOIDCLoginState(state.State, state.Binding, ctx)
+	if err != nil {
+		t.Fatalf("state with the right binding: %s", err)
+	}
+	if _, err := database.ConsumeOIDCLoginState(state.State, state.Binding, ctx); !errors.Is(err, dbModels.ErrOIDCStateInvalid) {
+		t.Errorf("state consumed twice: error = %v", err)
+	}
+	if _, err := database.LinkIdentity(identity, loginState, state.State, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The identity logs in as the linked user, with the key derived from the password
+	_, loggedIn, err := database.LoginWithIdentity(identity, "test", "127.0.0.1", ctx)
+	if err != nil || *loggedIn.Id != *user.Id {
+		t.Fatalf("login with the linked identity = user %v, %v, want user %d", loggedIn.Id, err, *user.Id)
+	}
+	dataKey, err := loadDataKey(database.DB, *user.Id, ctx)
+	if err != nil || !bytes.Equal(dataKey, cryptoKey) {
+		t.Errorf("data key = %x, %v, want the key derived from the password", dataKey, err)
+	}
+
+	state, err = database.CreateOIDCLoginState("/", *other.Id, cryptoKey, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loginState, err = database.ConsumeOIDCLoginState(state.State, state.Binding, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.LinkIdentity(identity, loginState, state.State, ctx); !errors.Is(err, dbModels.ErrIdentityLinked) {
+		t.Errorf("link to a second user: error = %v, want %v", err, dbModels.ErrIdentityLinked)
+	}
+
+	identities, err := database.GetIdentities(*user.Id, ctx)
+	if err != nil || len(identities) != 1 {
+		t.Fatalf("GetIdentities = %v, %v", identities, err)
+	}
+	if err := database.UnlinkIdentity(*user.Id, *identities[0].Id, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadDataKey(database.DB, *user.Id, ctx); err == nil {
+		t.Error("the data key was kept after the last identity was unlinked")
+	}
+}
+
+func TestUnlinkLastIdentity(t *testing.T) {
+	database := openTestDatabase(t)
+	withAutoProvision(t, true)
+	ctx := context.Background()
+	identity := sso.Identity{Issuer: "https://idp.example", Subject: "1234", Username: "idp.only"}
+	_, user, err := database.LoginWithIdentity(identity, "test", "127.0.0.1", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities, err := database.GetIdentities(*user.Id, ctx)
+	if err != nil || len(identities) != 1 {
+		t.Fatalf("GetIdentities = %v, %v", identities, err)
+	}
+	if err := database.UnlinkIdentity(*user.Id, *identities[0].Id, ctx); !errors.Is(err, dbModels.ErrLastLoginMethod) {
+		t.Errorf("unlink of the only login method: error = %v, want %v", err, dbModels.ErrLastLoginMethod)
+	}
+}
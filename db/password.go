@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/policy"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// rekeySecretUserSettings decrypts all secret settings of a user with oldKey and encrypts them with newKey.
+func rekeySecretUserSettings(idb bun.IDB, userId int, oldKey []byte, newKey []byte, ctx context.Context) error {
+	for _, secret := range secretSettings {
+		setting := dbModels.UserSetting{
+			UserID:      userId,
+			SettingType: secret.SettingType,
+			SettingName: secret.SettingName,
+		}
+		err := idb.NewSelect().Model(&setting).WherePK().Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+		if setting.SettingsVariable == "" {
+			continue
+		}
+		value, _, err := decryptSetting(userId, setting.SettingType, setting.SettingName, setting.SettingsVariable, oldKey)
+		if err != nil {
+			return err
+		}
+		setting.SettingsVariable, err = encryptSetting(userId, setting.SettingType, setting.SettingName, value, newKey)
+		if err != nil {
+			return err
+		}
+		_, err = idb.NewUpdate().Model(&setting).Column("settings_variable").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of a user and re-encrypts the secret settings with the new crypto key.
+// All other sessions of the user are revoked and new tokens are issued for the current session.
+// Everything runs in one transaction, so a failure leaves the old password and settings intact.
+func (database *Database) ChangePassword(userId int, oldPassword string, newPassword string, sessionId string, ctx context.Context) (SessionTokens, gen.User, error) {
+	var tokens SessionTokens
+	var user dbModels.User
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		user = dbModels.User{Id: userId}
+		err := tx.NewSelect().Model(&user).WherePK().For("UPDATE").Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrUserNotFound
+			}
+			return err
+		}
+		if user.PwdHash == "" {
+			return dbModels.ErrInvalidPassword
+		}
+		_, err = verifyPassword(oldPassword, user.PwdHash)
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return dbModels.ErrInvalidPassword
+			}
+			return err
+		}
+		err = policy.CheckPassword(newPassword, user.Name)
+		if err != nil {
+			return err
+		}
+		user.PwdHash, err = hashPassword(newPassword)
+		if err != nil {
+			return err
+		}
+		newKey := deriveKey(newPassword)
+		err = rekeySecretUserSettings(tx, user.Id, deriveKey(oldPassword), newKey, ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewUpdate().Model(&user).Column("pwdHash").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		err = updateDataKey(tx, user.Id, newKey, ctx)
+		if err != nil {
+			return err
+		}
+		// Personal access tokens still seal the old crypto key.
+		err = dropAccessTokenKeys(tx, user.Id, ctx)
+		if err != nil {
+			return err
+		}
+
+		// The PWD claim of all issued access tokens no longer matches, revoke the sessions as well.
+		_, err = tx.NewUpdate().
+			Model((*dbModels.Session)(nil)).
+			Set("revoked_at = ?", time.Now()).
+			Where("\"userId\" = ?", user.Id).
+			Where("id != ?", sessionId).
+			Where("revoked_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		session := dbModels.Session{Id: sessionId}
+		err = tx.NewSelect().Model(&session).WherePK().Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrSessionRevoked
+			}
+			return err
+		}
+		// Refresh tokens of the current session still wrap the old crypto key.
+		_, err = tx.NewDelete().
+			Model((*dbModels.RefreshToken)(nil)).
+			Where("\"sessionId\" = ?", session.Id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		tokens, err = database.issueSessionTokens(tx, user, newKey, session, ctx)
+		return err
+	})
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	return tokens, user.ToGen(), nil
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/policy"
+	"github.com/uptrace/bun"
+)
+
+// CreatePasswordResetToken creates a single use reset token for a user. Earlier unused tokens of the user are invalidated.
+// requestedBy is the id of the user that requested the reset, either the user itself or an admin.
+func (database *Database) CreatePasswordResetToken(userId int, requestedBy int, ctx context.Context) (string, gen.User, error) {
+	user := dbModels.User{Id: userId}
+	err := database.fetchUser(&user, ctx)
+	if err != nil {
+		return "", gen.User{}, err
+	}
+	if user.Email == "" {
+		return "", gen.User{}, dbModels.ErrNoEmail
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return "", gen.User{}, err
+	}
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model((*dbModels.PasswordResetToken)(nil)).
+			Set("used_at = ?", time.Now()).
+			Where("\"userId\" = ?", user.Id).
+			Where("used_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		entry := dbModels.PasswordResetToken{
+			UserId:      user.Id,
+			RequestedBy: requestedBy,
+			TokenHash:   generateSHA256Hash(token),
+			ExpiresAt:   time.Now().Add(config.Config.PasswordReset.TokenLifetime),
+		}
+		_, err = tx.NewInsert().Model(&entry).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return "", gen.User{}, err
+	}
+	return token, user.ToGen(), nil
+}
+
+// GetUserIdByName returns the id of the user with the given name.
+func (database *Database) GetUserIdByName(name string, ctx context.Context) (int, error) {
+	var user dbModels.User
+	err := database.DB.NewSelect().
+		Model(&user).
+		Column("id").
+		Where("\"user\".\"name\" = ?", name).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, dbModels.ErrUserNotFound
+		}
+		return 0, err
+	}
+	return user.Id, nil
+}
+
+// ResetPassword sets a new password using a reset token.
+// The old crypto key is lost with the old password, so the stored Untis credentials are wiped
+// and the user is asked to reconnect the Untis account. All sessions and personal access tokens of the user are revoked.
+// It returns the id of the user.
+func (database *Database) ResetPassword(token string, newPassword string, ctx context.Context) (int, error) {
+	var userId int
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var entry dbModels.PasswordResetToken
+		err := tx.NewSelect().
+			Model(&entry).
+			Where("\"tokenHash\" = ?", generateSHA256Hash(token)).
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrResetTokenInvalid
+			}
+			return err
+		}
+		if !entry.UsedAt.IsZero() || entry.ExpiresAt.Before(time.Now()) {
+			return dbModels.ErrResetTokenInvalid
+		}
+		// Check the password before the token is used up, so the user can try another one
+		userId = entry.UserId
+		user := dbModels.User{Id: entry.UserId}
+		err = tx.NewSelect().Model(&user).Column("name").WherePK().Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrResetTokenInvalid
+			}
+			return err
+		}
+		err = policy.CheckPassword(newPassword, user.Name)
+		if err != nil {
+			return err
+		}
+		entry.UsedAt = time.Now()
+		_, err = tx.NewUpdate().Model(&entry).Column("used_at").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		user.PwdHash, err = hashPassword(newPassword)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewUpdate().Model(&user).Column("pwdHash").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		// A login through the identity provider has to use the new key as well.
+		err = updateDataKey(tx, user.Id, deriveKey(newPassword), ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewDelete().
+			Model((*dbModels.UserSetting)(nil)).
+			Where("userid = ?", user.Id).
+			Where("setting_type = ?", "untis").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		reconnect := dbModels.UserSetting{
+			UserID:           user.Id,
+			SettingType:      "account",
+			SettingName:      "untisReconnectRequired",
+			SettingsVariable: "true",
+		}
+		_, err = tx.NewInsert().
+			Model(&reconnect).
+			On("CONFLICT (userid, setting_type, setting_name) DO UPDATE").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*dbModels.Session)(nil)).
+			Set("revoked_at = ?", time.Now()).
+			Where("\"userId\" = ?", user.Id).
+			Where("revoked_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		err = revokeAccessTokens(tx, user.Id, ctx)
+		if err != nil {
+			return err
+		}
+		return revokeAppPasswords(tx, user.Id, ctx)
+	})
+	return userId, err
+}
+
+// GetUntisAccStatus reports if the user has linked an Untis account and if it has to be reconnected.
+func (database *Database) GetUntisAccStatus(userId int, ctx context.Context) (gen.UntisAccStatus, error) {
+	status := gen.UntisAccStatus{}
+	untisName, err := database.GetUserSetting(userId, "untis", "untisName", ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return status, err
+	}
+	untisPWD, err := database.GetUserSetting(userId, "untis", "untisPWD", ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return status, err
+	}
+	reconnect, err := database.GetUserSetting(userId, "account", "untisReconnectRequired", ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return status, err
+	}
+	linked := untisPWD != ""
+	reconnectRequired := reconnect == "true"
+	status.Linked = &linked
+	status.ReconnectRequired = &reconnectRequired
+	if untisName != "" {
+		status.UserName = &untisName
+	}
+	return status, nil
+}
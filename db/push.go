@@ -0,0 +1,528 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/webpush"
+	"github.com/uptrace/bun"
+)
+
+// pendingPushInterval is how often the notifications held back by quiet hours are checked.
+const pendingPushInterval = time.Minute
+
+// Notification types, each can be switched off in the PushPreferences.
+const (
+	pushCancelled     = "cancelled"
+	pushSubstitutions = "substitutions"
+	pushRooms         = "rooms"
+	pushTeachers      = "teachers"
+)
+
+// CreatePushSubscription stores the subscription of a browser. An endpoint that is already subscribed by another user
+// only moves to the user if the same auth secret is sent, which only the browser knows, e.g. after a logout and a
+// login with another account. Otherwise ErrPushSubscriptionTaken is returned.
+func (database *Database) CreatePushSubscription(subscription dbModels.PushSubscription, ctx context.Context) (gen.PushSubscription, error) {
+	result, err := database.DB.NewInsert().
+		Model(&subscription).
+		On("CONFLICT (endpoint) DO UPDATE").
+		Set("\"userId\" = EXCLUDED.\"userId\"").
+		Set("p256dh = EXCLUDED.p256dh").
+		Set("auth = EXCLUDED.auth").
+		Set("\"userAgent\" = EXCLUDED.\"userAgent\"").
+		Where("\"push_subscription\".\"userId\" = EXCLUDED.\"userId\" OR \"push_subscription\".auth = EXCLUDED.auth").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return gen.PushSubscription{}, err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return gen.PushSubscription{}, dbModels.ErrPushSubscriptionTaken
+	}
+	return subscription.ToGen(), nil
+}
+
+// GetPushSubscriptions returns the push subscriptions of a user.
+func (database *Database) GetPushSubscriptions(userId int, ctx context.Context) ([]gen.PushSubscription, error) {
+	var subscriptions []dbModels.PushSubscription
+	err := database.DB.NewSelect().Model(&subscriptions).Where("\"userId\" = ?", userId).Order("id").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genSubscriptions := make([]gen.PushSubscription, len(subscriptions))
+	for i, subscription := range subscriptions {
+		genSubscriptions[i] = subscription.ToGen()
+	}
+	return genSubscriptions, nil
+}
+
+// DeletePushSubscription deletes a push subscription of a user.
+func (database *Database) DeletePushSubscription(userId int, subscriptionId int, ctx context.Context) error {
+	res, err := database.DB.NewDelete().
+		Model((*dbModels.PushSubscription)(nil)).
+		Where("id = ?", subscriptionId).
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return dbModels.ErrPushSubscriptionNotFound
+	}
+	return nil
+}
+
+// GetPushPreferences returns the notification preferences of a user, the defaults if it never changed them.
+func (database *Database) GetPushPreferences(userId int, ctx context.Context) (dbModels.PushPreferences, error) {
+	preferences := dbModels.PushPreferences{UserId: userId}
+	err := database.DB.NewSelect().Model(&preferences).WherePK().Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return dbModels.DefaultPushPreferences(userId), nil
+	}
+	return preferences, err
+}
+
+// UpdatePushPreferences stores the notification preferences of a user.
+func (database *Database) UpdatePushPreferences(preferences dbModels.PushPreferences, ctx context.Context) error {
+	_, err := database.DB.NewInsert().
+		Model(&preferences).
+		On("CONFLICT (\"userId\") DO UPDATE").
+		Exec(ctx)
+	return err
+}
+
+// pushTypes returns the notification types of a lesson change.
+// A created lesson is cancelled or irregular, an irregular one is a substitution.
+func pushTypes(version dbModels.LessonVersion, lesson dbModels.Lesson) []string {
+	if version.Kind == string(gen.LessonChangeKindCreated) {
+		if lesson.Cancelled {
+			return []string{pushCancelled}
+		}
+		return []string{pushSubstitutions}
+	}
+	// Most important first, the first type is the title of the notification
+	var types []string
+	for _, field := range []struct{ name, pushType string }{
+		{"cancelled", pushCancelled},
+		{"substitutionText", pushSubstitutions},
+		{"rooms", pushRooms},
+		{"teachers", pushTeachers},
+	} {
+		if _, changed := version.Diff[field.name]; changed {
+			types = append(types, field.pushType)
+		}
+	}
+	return types
+}
+
+// enabledPushTypes removes the types the user switched off and orders the rest most important first.
+func enabledPushTypes(preferences dbModels.PushPreferences, types []string) []string {
+	enabled := []struct {
+		pushType string
+		enabled  bool
+	}{
+		{pushCancelled, preferences.Cancelled},
+		{pushSubstitutions, preferences.Substitutions},
+		{pushRooms, preferences.Rooms},
+		{pushTeachers, preferences.Teachers},
+	}
+	result := make([]string, 0, len(types))
+	for _, pushType := range enabled {
+		if pushType.enabled && slices.Contains(types, pushType.pushType) {
+			result = append(result, pushType.pushType)
+		}
+	}
+	return result
+}
+
+// pushLocation is the time zone of the quiet hours and the times in the notifications.
+func pushLocation() *time.Location {
+	location, err := time.LoadLocation(config.Config.WebPush.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// inQuietHours reports if now is inside the quiet hours of the user. Quiet hours with a start after the end span midnight.
+func inQuietHours(preferences dbModels.PushPreferences, now time.Time) bool {
+	start, err := time.Parse("15:04", preferences.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", preferences.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+	now = now.In(pushLocation())
+	minute := now.Hour()*60 + now.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
+
+// quietHoursEnd returns the end of the quiet hours that include now.
+func quietHoursEnd(preferences dbModels.PushPreferences, now time.Time) time.Time {
+	end, err := time.Parse("15:04", preferences.QuietHoursEnd)
+	if err != nil {
+		return now
+	}
+	now = now.In(pushLocation())
+	endTime := time.Date(now.Year(), now.Month(), now.Day(), end.Hour(), end.Minute(), 0, 0, now.Location())
+	if !endTime.After(now) {
+		endTime = endTime.AddDate(0, 0, 1)
+	}
+	return endTime
+}
+
+// notifyLessonChanges sends a push notification for every changed upcoming lesson to the subscribed users whose
+// choice includes it. It runs after the fetch, failures are only logged.
+func (database *Database) notifyLessonChanges(versions []dbModels.LessonVersion, lessons []dbModels.Lesson) {
+	if !config.Config.WebPush.Enabled || webpush.DefaultSender == nil || len(versions) == 0 {
+		return
+	}
+	ctx := context.Background()
+	now := time.Now()
+	lessonsById := make(map[int]dbModels.Lesson, len(lessons))
+	for _, lesson := range lessons {
+		lessonsById[lesson.Id] = lesson
+	}
+	changes := make(map[int][]string)
+	ids := make([]int, 0)
+	for _, version := range versions {
+		lesson := lessonsById[version.LessonId]
+		if lesson.EndTime.Before(now) {
+			continue
+		}
+		if types := pushTypes(version, lesson); len(types) > 0 {
+			changes[lesson.Id] = types
+			ids = append(ids, lesson.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	// Only the subscribed users whose view can include a changed lesson are matched lesson by lesson
+	changedClasses := make(map[string]bool)
+	for _, id := range ids {
+		for _, class := range lessonsById[id].Classes {
+			changedClasses[class] = true
+		}
+	}
+	var subscribedUsers []dbModels.User
+	err := database.DB.NewSelect().
+		Model(&subscribedUsers).
+		Relation("DefaultChoice").
+		Where("\"user\".id IN (SELECT \"userId\" FROM push_subscription)").
+		Scan(ctx)
+	if err != nil {
+		log.Printf("Failed to load the users with push subscriptions: %s", err.Error())
+		return
+	}
+	users := make([]dbModels.User, 0)
+	userIds := make([]int, 0)
+	for _, user := range subscribedUsers {
+		for _, class := range choiceClasses(user) {
+			if changedClasses[class] {
+				users = append(users, user)
+				userIds = append(userIds, user.Id)
+				break
+			}
+		}
+	}
+	if len(users) == 0 {
+		return
+	}
+
+	subscriptionsByUser, err := database.pushSubscriptionsOf(userIds, ctx)
+	if err != nil {
+		log.Printf("Failed to load the push subscriptions: %s", err.Error())
+		return
+	}
+	preferencesByUser, err := database.pushPreferencesOf(userIds, ctx)
+	if err != nil {
+		log.Printf("Failed to load the push preferences: %s", err.Error())
+		return
+	}
+	for _, user := range users {
+		preferences := preferencesByUser[user.Id]
+		quiet := inQuietHours(preferences, now)
+		matching, err := database.choiceLessons(user, ids, ctx)
+		if err != nil {
+			log.Printf("Failed to match the lesson changes of user %d: %s", user.Id, err.Error())
+			continue
+		}
+		for _, lessonId := range matching {
+			types := enabledPushTypes(preferences, changes[lessonId])
+			if len(types) == 0 {
+				continue
+			}
+			if quiet {
+				err := database.queuePush(user.Id, lessonId, types, quietHoursEnd(preferences, now), ctx)
+				if err != nil {
+					log.Printf("Failed to hold back the push notification of lesson %d for user %d: %s", lessonId, user.Id, err.Error())
+				}
+				continue
+			}
+			payload, err := database.pushPayload(lessonsById[lessonId], types, ctx)
+			if err != nil {
+				log.Printf("Failed to build the push notification of lesson %d: %s", lessonId, err.Error())
+				continue
+			}
+			for _, subscription := range subscriptionsByUser[user.Id] {
+				database.sendPush(subscription, payload, ctx)
+			}
+		}
+	}
+}
+
+// pushSubscriptionsOf returns the push subscriptions of the users by user.
+func (database *Database) pushSubscriptionsOf(userIds []int, ctx context.Context) (map[int][]dbModels.PushSubscription, error) {
+	var subscriptions []dbModels.PushSubscription
+	err := database.DB.NewSelect().Model(&subscriptions).Where("\"userId\" IN (?)", bun.In(userIds)).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscriptionsByUser := make(map[int][]dbModels.PushSubscription)
+	for _, subscription := range subscriptions {
+		subscriptionsByUser[subscription.UserId] = append(subscriptionsByUser[subscription.UserId], subscription)
+	}
+	return subscriptionsByUser, nil
+}
+
+// pushPreferencesOf returns the notification preferences of the users by user, the defaults for those that never changed them.
+func (database *Database) pushPreferencesOf(userIds []int, ctx context.Context) (map[int]dbModels.PushPreferences, error) {
+	var stored []dbModels.PushPreferences
+	err := database.DB.NewSelect().Model(&stored).Where("\"userId\" IN (?)", bun.In(userIds)).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	preferencesByUser := make(map[int]dbModels.PushPreferences, len(userIds))
+	for _, userId := range userIds {
+		preferencesByUser[userId] = dbModels.DefaultPushPreferences(userId)
+	}
+	for _, preferences := range stored {
+		preferencesByUser[preferences.UserId] = preferences
+	}
+	return preferencesByUser, nil
+}
+
+// queuePush holds back the notification of a lesson change until sendAt. A notification of the lesson that is
+// already held back gets the types of both.
+func (database *Database) queuePush(userId int, lessonId int, types []string, sendAt time.Time, ctx context.Context) error {
+	pending := dbModels.PushPending{UserId: userId, LessonId: lessonId, Types: types, SendAt: sendAt}
+	_, err := database.DB.NewInsert().
+		Model(&pending).
+		On("CONFLICT (\"userId\", \"lessonId\") DO UPDATE").
+		Set("types = (SELECT jsonb_agg(DISTINCT type) FROM jsonb_array_elements_text(\"push_pending\".types || EXCLUDED.types) AS type)").
+		Set("send_at = LEAST(\"push_pending\".send_at, EXCLUDED.send_at)").
+		Exec(ctx)
+	return err
+}
+
+// RunPendingPush sends the notifications held back by quiet hours once they end. It blocks, run it in a goroutine.
+func (database *Database) RunPendingPush() {
+	if !config.Config.WebPush.Enabled {
+		return
+	}
+	ticker := time.NewTicker(pendingPushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		database.sendPendingPush()
+	}
+}
+
+// sendPendingPush sends the due held back notifications with the current state of their lessons and the current
+// preferences. Notifications of lessons that are over by now are dropped.
+func (database *Database) sendPendingPush() {
+	if webpush.DefaultSender == nil {
+		return
+	}
+	ctx := context.Background()
+	now := time.Now()
+	var pending []dbModels.PushPending
+	// Deleting claims the notifications, so every instance sends each one only once
+	_, err := database.DB.NewDelete().Model(&pending).Where("send_at <= ?", now).Returning("*").Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to claim the held back push notifications: %s", err.Error())
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+	lessonIds := make([]int, 0, len(pending))
+	userIds := make([]int, 0, len(pending))
+	for _, entry := range pending {
+		lessonIds = append(lessonIds, entry.LessonId)
+		userIds = append(userIds, entry.UserId)
+	}
+	var lessons []dbModels.Lesson
+	err = database.DB.NewSelect().Model(&lessons).Where("id IN (?)", bun.In(lessonIds)).Scan(ctx)
+	if err != nil {
+		log.Printf("Failed to load the lessons of the held back push notifications: %s", err.Error())
+		return
+	}
+	lessonsById := make(map[int]dbModels.Lesson, len(lessons))
+	for _, lesson := range lessons {
+		lessonsById[lesson.Id] = lesson
+	}
+	subscriptionsByUser, err := database.pushSubscriptionsOf(userIds, ctx)
+	if err != nil {
+		log.Printf("Failed to load the push subscriptions: %s", err.Error())
+		return
+	}
+	preferencesByUser, err := database.pushPreferencesOf(userIds, ctx)
+	if err != nil {
+		log.Printf("Failed to load the push preferences: %s", err.Error())
+		return
+	}
+	for _, entry := range pending {
+		lesson, ok := lessonsById[entry.LessonId]
+		if !ok || lesson.EndTime.Before(now) {
+			continue
+		}
+		types := enabledPushTypes(preferencesByUser[entry.UserId], entry.Types)
+		if len(types) == 0 {
+			continue
+		}
+		payload, err := database.pushPayload(lesson, types, ctx)
+		if err != nil {
+			log.Printf("Failed to build the push notification of lesson %d: %s", lesson.Id, err.Error())
+			continue
+		}
+		for _, subscription := range subscriptionsByUser[entry.UserId] {
+			database.sendPush(subscription, payload, ctx)
+		}
+	}
+}
+
+// choiceClasses returns the classes the lessons of the view of a user can belong to: the classes of its default
+// choice, or its own classes without one. The user has to be loaded with its DefaultChoice.
+func choiceClasses(user dbModels.User) []string {
+	if user.DefaultChoice != nil {
+		var choice map[string]interface{}
+		if json.Unmarshal([]byte(user.DefaultChoice.Choice), &choice) == nil && len(choice) > 0 {
+			classes := make([]string, 0, len(choice))
+			for key := range choice {
+				if _, err := strconv.Atoi(key); err == nil {
+					classes = append(classes, key)
+				}
+			}
+			return classes
+		}
+	}
+	return user.Classes
+}
+
+// lessonsOfUser returns the ids of the lessons the choice of the user includes, like the lessons of its view.
+func (database *Database) lessonsOfUser(userId int, lessonIds []int, ctx context.Context) ([]int, error) {
+	user := dbModels.User{Id: userId}
+	err := database.DB.NewSelect().Model(&user).WherePK().Relation("DefaultChoice").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return database.choiceLessons(user, lessonIds, ctx)
+}
+
+// choiceLessons returns the ids of the lessons the default choice of a user loaded with its DefaultChoice includes.
+// Without a default choice the lessons of the classes of the user are used.
+func (database *Database) choiceLessons(user dbModels.User, lessonIds []int, ctx context.Context) ([]int, error) {
+	var choice dbModels.Choice
+	if user.DefaultChoice != nil {
+		choice = *user.DefaultChoice
+	}
+	var ids []int
+	query := database.DB.NewSelect().Model((*dbModels.Lesson)(nil)).Column("id").Where("\"lesson\".id IN (?)", bun.In(lessonIds))
+	err := whereLessonChoice(query, user, choice)
+	if err != nil {
+		return nil, err
+	}
+	err = query.Scan(ctx, &ids)
+	return ids, err
+}
+
+// pushPayload returns the notification of a lesson change, as read by the service worker of the frontend.
+func (database *Database) pushPayload(lesson dbModels.Lesson, types []string, ctx context.Context) ([]byte, error) {
+	var subjects []dbModels.Subject
+	if len(lesson.Subjects) > 0 {
+		err := database.DB.NewSelect().Model(&subjects).Where("id IN (?)", bun.In(lesson.Subjects)).Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	subjectNames := make([]string, len(subjects))
+	for i, subject := range subjects {
+		subjectNames[i] = subject.ShortName
+	}
+	body := strings.Join(subjectNames, ", ") + " " + lesson.StartTime.In(pushLocation()).Format("Mon 02.01. 15:04")
+
+	var title string
+	switch types[0] {
+	case pushCancelled:
+		title = "Lesson cancelled"
+		if !lesson.Cancelled {
+			title = "Lesson takes place again"
+		}
+	case pushSubstitutions:
+		title = "Substitution"
+	case pushRooms:
+		title = "Room changed"
+	case pushTeachers:
+		title = "Teacher changed"
+	}
+	for _, pushType := range types {
+		if pushType == pushRooms && len(lesson.Rooms) > 0 {
+			var rooms []dbModels.Room
+			err := database.DB.NewSelect().Model(&rooms).Where("id IN (?)", bun.In(lesson.Rooms)).Scan(ctx)
+			if err != nil {
+				return nil, err
+			}
+			roomNames := make([]string, len(rooms))
+			for i, room := range rooms {
+				roomNames[i] = room.Name
+			}
+			body += " in " + strings.Join(roomNames, ", ")
+		}
+	}
+	if lesson.SubstitutionText != "" {
+		body += ": " + lesson.SubstitutionText
+	}
+	return json.Marshal(map[string]interface{}{
+		"title":     title,
+		"body":      body,
+		"tag":       fmt.Sprintf("lesson-%d", lesson.Id),
+		"lessonId":  lesson.Id,
+		"types":     types,
+		"startTime": lesson.StartTime,
+	})
+}
+
+// sendPush sends a notification and deletes the subscription if the push service no longer knows it.
+func (database *Database) sendPush(subscription dbModels.PushSubscription, payload []byte, ctx context.Context) {
+	err := webpush.DefaultSender.Send(webpush.Subscription{
+		Endpoint: subscription.Endpoint,
+		P256dh:   subscription.P256dh,
+		Auth:     subscription.Auth,
+	}, payload)
+	if errors.Is(err, webpush.ErrSubscriptionGone) {
+		_, err = database.DB.NewDelete().Model(&subscription).WherePK().Exec(ctx)
+	}
+	if err != nil {
+		log.Printf("Failed to send a push notification to user %d: %s", subscription.UserId, err.Error())
+	}
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/webpush"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/webpush/pushtest"
+)
+
+// withPushTimeZone sets the time zone of the quiet hours for a test.
+func withPushTimeZone(t *testing.T, timeZone string) {
+	previous := config.Config.WebPush.TimeZone
+	t.Cleanup(func() { config.Config.WebPush.TimeZone = previous })
+	config.Config.WebPush.TimeZone = timeZone
+}
+
+func TestInQuietHours(t *testing.T) {
+	withPushTimeZone(t, "UTC")
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.March, 4, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		now        time.Time
+		want       bool
+	}{
+		{"none", "", "", at(23, 0), false},
+		{"only start", "22:00", "", at(23, 0), false},
+		{"invalid", "22", "7", at(23, 0), false},
+		{"same day inside", "12:00", "14:00", at(13, 0), true},
+		{"same day at start", "12:00", "14:00", at(12, 0), true},
+		{"same day at end", "12:00", "14:00", at(14, 0), false},
+		{"same day before", "12:00", "14:00", at(11, 59), false},
+		{"midnight before midnight", "22:00", "07:00", at(23, 30), true},
+		{"midnight at midnight", "22:00", "07:00", at(0, 0), true},
+		{"midnight after midnight", "22:00", "07:00", at(6, 59), true},
+		{"midnight at end", "22:00", "07:00", at(7, 0), false},
+		{"midnight during the day", "22:00", "07:00", at(12, 0), false},
+		{"midnight at start", "22:00", "07:00", at(22, 0), true},
+		{"empty range", "08:00", "08:00", at(8, 0), false},
+	}
+	for _, test := range tests {
+		preferences := dbModels.PushPreferences{QuietHoursStart: test.start, QuietHoursEnd: test.end}
+		if got := inQuietHours(preferences, test.now); got != test.want {
+			t.Errorf("%s: inQuietHours(%s-%s, %s) = %v, want %v", test.name, test.start, test.end, test.now.Format("15:04"), got, test.want)
+		}
+	}
+}
+
+func TestInQuietHoursTimeZone(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	withPushTimeZone(t, location.String())
+	preferences := dbModels.PushPreferences{QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}
+	// 21:30 UTC is 22:30 in Berlin in winter
+	if !inQuietHours(preferences, time.Date(2024, time.January, 10, 21, 30, 0, 0, time.UTC)) {
+		t.Error("the quiet hours are not checked in the configured time zone")
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	withPushTimeZone(t, "UTC")
+	preferences := dbModels.PushPreferences{QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2024, time.March, 4, 23, 30, 0, 0, time.UTC), time.Date(2024, time.March, 5, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2024, time.March, 5, 3, 0, 0, 0, time.UTC), time.Date(2024, time.March, 5, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2024, time.March, 31, 22, 0, 0, 0, time.UTC), time.Date(2024, time.April, 1, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := quietHoursEnd(preferences, test.now); !got.Equal(test.want) {
+			t.Errorf("quietHoursEnd at %s = %s, want %s", test.now, got, test.want)
+		}
+	}
+}
+
+func TestEnabledPushTypes(t *testing.T) {
+	all := dbModels.DefaultPushPreferences(1)
+	noRooms := all
+	noRooms.Rooms = false
+	tests := []struct {
+		preferences dbModels.PushPreferences
+		types       []string
+		want        []string
+	}{
+		{all, []string{pushCancelled}, []string{pushCancelled}},
+		{all, []string{pushTeachers, pushRooms, pushCancelled}, []string{pushCancelled, pushRooms, pushTeachers}},
+		{noRooms, []string{pushRooms, pushTeachers}, []string{pushTeachers}},
+		{noRooms, []string{pushRooms}, []string{}},
+		{all, nil, []string{}},
+	}
+	for _, test := range tests {
+		if got := enabledPushTypes(test.preferences, test.types); !slices.Equal(got, test.want) {
+			t.Errorf("enabledPushTypes(%v) = %v, want %v", test.types, got, test.want)
+		}
+	}
+}
+
+func TestChoiceClasses(t *testing.T) {
+	tests := []struct {
+		name   string
+		choice *dbModels.Choice
+		want   []string
+	}{
+		{"no choice", nil, []string{"1", "2"}},
+		{"choice", &dbModels.Choice{Choice: `{"3": [], "-4": ["7"], "name": "x"}`}, []string{"-4", "3"}},
+		{"empty choice", &dbModels.Choice{Choice: `{}`}, []string{"1", "2"}},
+		{"invalid choice", &dbModels.Choice{Choice: `[`}, []string{"1", "2"}},
+	}
+	for _, test := range tests {
+		got := choiceClasses(dbModels.User{Classes: []string{"1", "2"}, DefaultChoice: test.choice})
+		slices.Sort(got)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: choiceClasses = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSendPush(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	server := pushtest.NewServer()
+	defer server.Close()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := webpush.NewVAPIDSender(base64.RawURLEncoding.EncodeToString(key.Bytes()), "mailto:admin@school.example", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.Client = server.Client()
+	previous := webpush.DefaultSender
+	t.Cleanup(func() { webpush.DefaultSender = previous })
+	webpush.DefaultSender = sender
+
+	browser, err := server.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = database.CreatePushSubscription(dbModels.PushSubscription{UserId: 1, Endpoint: browser.Endpoint, P256dh: browser.P256dh, Auth: browser.Auth}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subscription dbModels.PushSubscription
+	if err := database.DB.NewSelect().Model(&subscription).Where("endpoint = ?", browser.Endpoint).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	database.sendPush(subscription, []byte(`{"title":"Lesson cancelled"}`), ctx)
+	if messages := server.Messages(); len(messages) != 1 || string(messages[0].Payload) != `{"title":"Lesson cancelled"}` {
+		t.Fatalf("the push service received %+v", messages)
+	}
+
+	// The push service answers 410 Gone after the browser unsubscribed, the subscription is deleted
+	server.Unsubscribe(browser)
+	database.sendPush(subscription, []byte(`{"title":"Lesson cancelled"}`), ctx)
+	exists, err := database.DB.NewSelect().Model((*dbModels.PushSubscription)(nil)).Where("id = ?", subscription.Id).Exists(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("the subscription was kept after the push service answered 410 Gone")
+	}
+}
@@ -0,0 +1,345 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// lastSeenResolution limits how often the last seen timestamp of a session is written.
+const lastSeenResolution = time.Minute
+
+// refreshTokenKeyPurpose is the hkdf info used to derive the key that wraps the crypto key of a refresh token.
+const refreshTokenKeyPurpose = "tmf refresh token crypto key"
+
+func createSessionEntry(idb bun.IDB, user dbModels.User, device string, ip string, expirationTime time.Time, ctx context.Context) (dbModels.Session, error) {
+	session := dbModels.Session{
+		Id:        uuid.NewString(),
+		UserId:    user.Id,
+		Device:    device,
+		IP:        ip,
+		LastSeen:  time.Now(),
+		ExpiresAt: expirationTime,
+	}
+	_, err := idb.NewInsert().Model(&session).Exec(ctx)
+	if err != nil {
+		return dbModels.Session{}, err
+	}
+	return session, nil
+}
+
+// startSession creates a new session for a user and issues its first tokens.
+func (database *Database) startSession(user dbModels.User, cryptoKey []byte, device string, ip string, ctx context.Context) (SessionTokens, error) {
+	session, err := createSessionEntry(database.DB, user, device, ip, time.Now().Add(config.Config.Sessions.RefreshTokenLifetime), ctx)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	return database.issueSessionTokens(database.DB, user, cryptoKey, session, ctx)
+}
+
+// touchSession checks that the session exists and is still active and updates its last seen timestamp.
+// Tokens issued before sessions were persisted have no id and are rejected.
+func (database *Database) touchSession(sessionId string, userId int, ctx context.Context) error {
+	if sessionId == "" {
+		return dbModels.ErrSessionRevoked
+	}
+	session := dbModels.Session{Id: sessionId}
+	err := database.DB.NewSelect().
+		Model(&session).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dbModels.ErrSessionRevoked
+		}
+		return err
+	}
+	if session.UserId != userId || !session.RevokedAt.IsZero() || session.ExpiresAt.Before(time.Now()) {
+		return dbModels.ErrSessionRevoked
+	}
+	if time.Since(session.LastSeen) > lastSeenResolution {
+		session.LastSeen = time.Now()
+		_, err = database.DB.NewUpdate().
+			Model(&session).
+			Column("last_seen").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSessions returns all active sessions of a user, most recently used first.
+func (database *Database) GetSessions(userId int, ctx context.Context) ([]gen.Session, error) {
+	var sessions []dbModels.Session
+	err := database.DB.NewSelect().
+		Model(&sessions).
+		Where("\"userId\" = ?", userId).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Order("last_seen DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genSessions := make([]gen.Session, len(sessions))
+	for i, s := range sessions {
+		genSessions[i] = s.ToGen()
+	}
+	return genSessions, nil
+}
+
+// RevokeSession revokes a single session of a user.
+func (database *Database) RevokeSession(userId int, sessionId string, ctx context.Context) error {
+	res, err := database.DB.NewUpdate().
+		Model((*dbModels.Session)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("id = ?", sessionId).
+		Where("\"userId\" = ?", userId).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return dbModels.ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeSessions revokes all sessions of a user except the one with the id keepSessionId.
+// Pass an empty keepSessionId to revoke every session.
+func (database *Database) RevokeSessions(userId int, keepSessionId string, ctx context.Context) error {
+	query := database.DB.NewUpdate().
+		Model((*dbModels.Session)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("\"userId\" = ?", userId).
+		Where("revoked_at IS NULL")
+	if keepSessionId != "" {
+		query.Where("id != ?", keepSessionId)
+	}
+	_, err := query.Exec(ctx)
+	return err
+}
+
+// EndSession revokes the session a token belongs to. Expired tokens can still end their session, invalid tokens are ignored.
+func (database *Database) EndSession(tokenString string, ctx context.Context) error {
+	claims := &Claims{}
+	_, err := jwt.NewParser(jwt.WithoutClaimsValidation()).ParseWithClaims(tokenString, claims, jwtKeyFunc)
+	if err != nil || claims.ID == "" {
+		return nil
+	}
+	err = database.RevokeSession(claims.UserId, claims.ID, ctx)
+	if errors.Is(err, dbModels.ErrSessionNotFound) {
+		return nil
+	}
+	return err
+}
+
+// issueSessionTokens creates a new access token and a new refresh token for a session.
+// The crypto key is stored with the refresh token, wrapped with a key only derivable from the refresh token itself.
+// The tokens are written with idb, so they can be issued inside a transaction.
+func (database *Database) issueSessionTokens(idb bun.IDB, user dbModels.User, cryptoKey []byte, session dbModels.Session, ctx context.Context) (SessionTokens, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	wrappingKey, err := tokenKey(refreshToken, refreshTokenKeyPurpose)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	wrappedKey, err := sealKey(wrappingKey, cryptoKey, []byte(session.Id))
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	entry := dbModels.RefreshToken{
+		SessionId:  session.Id,
+		TokenHash:  generateSHA256Hash(refreshToken),
+		WrappedKey: wrappedKey,
+		ExpiresAt:  session.ExpiresAt,
+	}
+	_, err = idb.NewInsert().Model(&entry).Exec(ctx)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	expirationTime := time.Now().Add(config.Config.Sessions.AccessTokenLifetime)
+	accessToken, err := generateSessionToken(user, cryptoKey, session.Id, expirationTime)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	return SessionTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expirationTime,
+	}, nil
+}
+
+// RefreshSession exchanges a refresh token for a new access and refresh token.
+// Every refresh token can only be used once. If a used token is presented again the whole session is revoked,
+// as either the client or an attacker is holding a stolen copy.
+func (database *Database) RefreshSession(refreshToken string, ctx context.Context) (SessionTokens, gen.User, error) {
+	entry := dbModels.RefreshToken{}
+	err := database.DB.NewSelect().
+		Model(&entry).
+		Where("\"tokenHash\" = ?", generateSHA256Hash(refreshToken)).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenInvalid
+		}
+		return SessionTokens{}, gen.User{}, err
+	}
+	session := dbModels.Session{Id: entry.SessionId}
+	err = database.DB.NewSelect().Model(&session).WherePK().Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenInvalid
+		}
+		return SessionTokens{}, gen.User{}, err
+	}
+
+	// Mark the token as used. The condition on used_at makes concurrent refreshes with the same token count as reuse.
+	res, err := database.DB.NewUpdate().
+		Model((*dbModels.RefreshToken)(nil)).
+		Set("used_at = ?", time.Now()).
+		Where("id = ?", entry.Id).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		err = database.RevokeSession(session.UserId, session.Id, ctx)
+		if err != nil && !errors.Is(err, dbModels.ErrSessionNotFound) {
+			return SessionTokens{}, gen.User{}, err
+		}
+		return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenReused
+	}
+	if !session.RevokedAt.IsZero() || entry.ExpiresAt.Before(time.Now()) {
+		return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenInvalid
+	}
+
+	wrappingKey, err := tokenKey(refreshToken, refreshTokenKeyPurpose)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	cryptoKey, err := openKey(wrappingKey, entry.WrappedKey, []byte(session.Id))
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+
+	user := dbModels.User{Id: session.UserId}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+
+	session.LastSeen = time.Now()
+	session.ExpiresAt = time.Now().Add(config.Config.Sessions.RefreshTokenLifetime)
+	_, err = database.DB.NewUpdate().
+		Model(&session).
+		Column("last_seen", "expires_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	tokens, err := database.issueSessionTokens(database.DB, user, cryptoKey, session, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	return tokens, user.ToGen(), nil
+}
+
+// legacyKeyFunc returns the key of tokens issued before sessions existed. They were always signed with Crypto.JwtSecretKey.
+func legacyKeyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || token.Header["kid"] != nil {
+		return nil, errors.New("unexpected signing method")
+	}
+	return []byte(config.Config.Crypto.JwtSecretKey), nil
+}
+
+// ExchangeLegacyToken starts a session for an access token issued before sessions and wrapped crypto keys existed.
+// Those tokens carry the plain crypto key in the cKey claim. They are only accepted while Crypto.AcceptLegacyCryptoKeyClaim
+// is set and until they expire, at most a year after they were issued.
+// The token is stored like a used refresh token of the new session, so it can only be exchanged once and
+// presenting it again revokes that session.
+func (database *Database) ExchangeLegacyToken(tokenString string, device string, ip string, ctx context.Context) (SessionTokens, gen.User, error) {
+	if !config.Config.Crypto.AcceptLegacyCryptoKeyClaim {
+		return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenInvalid
+	}
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, legacyKeyFunc)
+	if err != nil || claims.ID != "" || claims.CryptoKey == "" || claims.ExpiresAt == nil {
+		return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenInvalid
+	}
+	cryptoKey, err := base64.StdEncoding.DecodeString(claims.CryptoKey)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenInvalid
+	}
+	user := dbModels.User{Id: claims.UserId}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	if claims.PWD != generateSHA256Hash(user.PwdHash)[:8] {
+		return SessionTokens{}, gen.User{}, dbModels.ErrRefreshTokenInvalid
+	}
+	if tokens, pending, err := database.secondFactorLogin(user, cryptoKey, ctx); err != nil || pending {
+		return tokens, user.ToGen(), err
+	}
+
+	tokenHash := generateSHA256Hash(tokenString)
+	var tokens SessionTokens
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		session, err := createSessionEntry(tx, user, device, ip, time.Now().Add(config.Config.Sessions.RefreshTokenLifetime), ctx)
+		if err != nil {
+			return err
+		}
+		exchanged := dbModels.RefreshToken{
+			SessionId: session.Id,
+			TokenHash: tokenHash,
+			ExpiresAt: claims.ExpiresAt.Time,
+			UsedAt:    time.Now(),
+		}
+		res, err := tx.NewInsert().Model(&exchanged).On("CONFLICT (\"tokenHash\") DO NOTHING").Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return dbModels.ErrRefreshTokenReused
+		}
+		tokens, err = database.issueSessionTokens(tx, user, cryptoKey, session, ctx)
+		return err
+	})
+	if errors.Is(err, dbModels.ErrRefreshTokenReused) {
+		var session dbModels.Session
+		revokeErr := database.DB.NewSelect().
+			Model(&session).
+			Where("id = (SELECT \"sessionId\" FROM refresh_token WHERE \"tokenHash\" = ?)", tokenHash).
+			Scan(ctx)
+		if revokeErr == nil {
+			revokeErr = database.RevokeSession(session.UserId, session.Id, ctx)
+		}
+		if revokeErr != nil && !errors.Is(revokeErr, sql.ErrNoRows) && !errors.Is(revokeErr, dbModels.ErrSessionNotFound) {
+			return SessionTokens{}, gen.User{}, revokeErr
+		}
+		return SessionTokens{}, gen.User{}, err
+	}
+	if err != nil {
+		return SessionTokens{}, gen.User{}, err
+	}
+	return tokens, user.ToGen(), nil
+}
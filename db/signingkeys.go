@@ -0,0 +1,372 @@
+package db
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// signingKeyReloadInterval limits how often an unknown kid triggers a reload of the keyring,
+// so tokens with made up kids can not be used to flood the database.
+const signingKeyReloadInterval = 10 * time.Second
+
+// signingKey is a loaded key of the keyring. private is nil if the key can only be used for verification.
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+}
+
+// keyring holds the keys used to sign and verify tokens.
+// Keys are stored in the database, so all instances of the server share them.
+type keyring struct {
+	mu         sync.RWMutex
+	db         bun.IDB
+	current    *signingKey
+	keys       map[string]*signingKey
+	lastReload time.Time
+}
+
+var signingKeys = &keyring{keys: map[string]*signingKey{}}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	case "RS256":
+		return jwt.SigningMethodRS256, nil
+	}
+	return nil, fmt.Errorf("crypto: unsupported signing algorithm %q", algorithm)
+}
+
+// generateSigningKey creates a new key pair for the configured algorithm. The private key is sealed with the key encryption key.
+func generateSigningKey(algorithm string) (dbModels.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		err = fmt.Errorf("crypto: unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return dbModels.SigningKey{}, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return dbModels.SigningKey{}, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return dbModels.SigningKey{}, err
+	}
+	key := dbModels.SigningKey{
+		Id:        uuid.NewString(),
+		Algorithm: algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(publicDER),
+	}
+	key.PrivateKey, err = sealKey(keyEncryptionKey(), privateDER, []byte(key.Id))
+	if err != nil {
+		return dbModels.SigningKey{}, err
+	}
+	return key, nil
+}
+
+// loadSigningKey parses a stored key. If the private key can not be opened, e.g. because the key encryption key changed,
+// the key is still returned for verification.
+func loadSigningKey(stored dbModels.SigningKey) (*signingKey, error) {
+	method, err := signingMethod(stored.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := base64.StdEncoding.DecodeString(stored.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	public, err := x509.ParsePKIXPublicKey(publicDER)
+	if err != nil {
+		return nil, err
+	}
+	key := &signingKey{
+		id:        stored.Id,
+		method:    method,
+		public:    public,
+		createdAt: stored.CreatedAt,
+	}
+	if !stored.RetiredAt.IsZero() {
+		return key, nil
+	}
+	privateDER, err := openKey(keyEncryptionKey(), stored.PrivateKey, []byte(stored.Id))
+	if err != nil {
+		return key, nil
+	}
+	private, err := x509.ParsePKCS8PrivateKey(privateDER)
+	if err != nil {
+		return nil, err
+	}
+	if signer, ok := private.(crypto.Signer); ok {
+		key.private = signer
+	}
+	return key, nil
+}
+
+// reload reads all keys that are not expired from the database.
+func (ring *keyring) reload(ctx context.Context) error {
+	var stored []dbModels.SigningKey
+	err := ring.db.NewSelect().
+		Model(&stored).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	keys := map[string]*signingKey{}
+	var current *signingKey
+	for _, s := range stored {
+		key, err := loadSigningKey(s)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %s", s.Id, err.Error())
+			continue
+		}
+		keys[key.id] = key
+		if current == nil && key.private != nil && key.method.Alg() == config.Config.Signing.Algorithm {
+			current = key
+		}
+	}
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+	ring.keys = keys
+	ring.current = current
+	ring.lastReload = time.Now()
+	return nil
+}
+
+// lookup returns the verification key with the id kid. Unknown ids reload the keyring,
+// as another instance of the server may have rotated the key.
+func (ring *keyring) lookup(kid string) (*signingKey, error) {
+	ring.mu.RLock()
+	key, ok := ring.keys[kid]
+	lastReload := ring.lastReload
+	ring.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if ring.db == nil || time.Since(lastReload) < signingKeyReloadInterval {
+		return nil, dbModels.ErrUnknownSigningKey
+	}
+	err := ring.reload(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	if key, ok := ring.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, dbModels.ErrUnknownSigningKey
+}
+
+// LoadSigningKeys loads the keyring and creates a signing key if there is no usable one.
+func (database *Database) LoadSigningKeys(ctx context.Context) error {
+	signingKeys.db = database.DB
+	if config.Config.Signing.Algorithm == "HS256" {
+		return nil
+	}
+	if _, err := signingMethod(config.Config.Signing.Algorithm); err != nil {
+		return err
+	}
+	err := signingKeys.reload(ctx)
+	if err != nil {
+		return err
+	}
+	signingKeys.mu.RLock()
+	current := signingKeys.current
+	signingKeys.mu.RUnlock()
+	if current == nil {
+		return database.RotateSigningKey(ctx)
+	}
+	return nil
+}
+
+// errSigningKeyRotated stops a rotation that another instance of the server already did.
+var errSigningKeyRotated = errors.New("crypto: the signing key was already rotated")
+
+// RotateSigningKey creates a new signing key. The previous keys stop signing but stay valid for verification
+// until the access tokens signed with them expired. Expired keys are removed.
+// Instances rotate one at a time. If another instance rotated since the keyring was loaded, its key is used instead.
+func (database *Database) RotateSigningKey(ctx context.Context) error {
+	signingKeys.mu.RLock()
+	previous := signingKeys.current
+	signingKeys.mu.RUnlock()
+	key, err := generateSigningKey(config.Config.Signing.Algorithm)
+	if err != nil {
+		return err
+	}
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('signing_key'))"); err != nil {
+			return err
+		}
+		var active []dbModels.SigningKey
+		err := tx.NewSelect().
+			Model(&active).
+			Where("retired_at IS NULL").
+			Where("algorithm = ?", config.Config.Signing.Algorithm).
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		for _, stored := range active {
+			if previous != nil && stored.Id == previous.id {
+				continue
+			}
+			if loaded, err := loadSigningKey(stored); err == nil && loaded.private != nil {
+				return errSigningKeyRotated
+			}
+		}
+		now := time.Now()
+		_, err = tx.NewUpdate().
+			Model((*dbModels.SigningKey)(nil)).
+			Set("retired_at = ?", now).
+			Set("expires_at = ?", now.Add(config.Config.Sessions.AccessTokenLifetime)).
+			Where("retired_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*dbModels.SigningKey)(nil)).
+			Where("expires_at < ?", now).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().Model(&key).Exec(ctx)
+		return err
+	})
+	if errors.Is(err, errSigningKeyRotated) {
+		return signingKeys.reload(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Rotated token signing key, new kid %s", key.Id)
+	return signingKeys.reload(ctx)
+}
+
+// RunSigningKeyRotation rotates the signing key whenever the current key is older than Signing.RotationInterval.
+// Keys rotated by other instances are picked up on every check.
+func (database *Database) RunSigningKeyRotation() {
+	if config.Config.Signing.Algorithm == "HS256" || config.Config.Signing.RotationInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx := context.Background()
+		err := signingKeys.reload(ctx)
+		if err != nil {
+			log.Printf("Failed to reload signing keys: %s", err.Error())
+			continue
+		}
+		signingKeys.mu.RLock()
+		current := signingKeys.current
+		signingKeys.mu.RUnlock()
+		if current == nil || time.Since(current.createdAt) > config.Config.Signing.RotationInterval {
+			err = database.RotateSigningKey(ctx)
+			if err != nil {
+				log.Printf("Failed to rotate signing key: %s", err.Error())
+			}
+		}
+	}
+}
+
+// signToken signs the claims with the current key of the keyring, or with Crypto.JwtSecretKey if HS256 is configured.
+func signToken(claims jwt.Claims) (string, error) {
+	if config.Config.Signing.Algorithm == "HS256" {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.Config.Crypto.JwtSecretKey))
+	}
+	signingKeys.mu.RLock()
+	current := signingKeys.current
+	signingKeys.mu.RUnlock()
+	if current == nil {
+		return "", errors.New("crypto: no signing key loaded")
+	}
+	token := jwt.NewWithClaims(current.method, claims)
+	token.Header["kid"] = current.id
+	return token.SignedString(current.private)
+}
+
+// jwtKeyFunc returns the key used to verify the signature of a token.
+// Tokens without kid are only accepted while HS256 is configured.
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || config.Config.Signing.Algorithm != "HS256" {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(config.Config.Crypto.JwtSecretKey), nil
+	}
+	key, err := signingKeys.lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+// GetJWKS returns the public keys of the keyring as JSON Web Keys.
+func (database *Database) GetJWKS() gen.JwkSet {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+	keys := []gen.Jwk{}
+	for _, key := range signingKeys.keys {
+		jwk := gen.Jwk{
+			Kid: key.id,
+			Alg: getPointer(key.method.Alg()),
+			Use: getPointer("sig"),
+		}
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = getPointer("Ed25519")
+			jwk.X = getPointer(base64.RawURLEncoding.EncodeToString(public))
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = getPointer(base64.RawURLEncoding.EncodeToString(public.N.Bytes()))
+			jwk.E = getPointer(base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()))
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return gen.JwkSet{Keys: keys}
+}
+
+func getPointer[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/robfig/cron/v3"
+)
+
+// syncJob is a job of the scheduled Untis sync.
+type syncJob struct {
+	name   string
+	config config.SyncJobConfig
+	fetch  func(ctx context.Context) error
+	// Set while the job runs, a run starting meanwhile is skipped.
+	running atomic.Bool
+}
+
+// execute runs the job and turns a panic, e.g. of an uninitialized Untis client, into an error.
+func (job *syncJob) execute(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.fetch(ctx)
+}
+
+// syncLocation returns the time zone of Sync.TimeZone, the local time zone if it is empty.
+func syncLocation() (*time.Location, error) {
+	if config.Config.Sync.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(config.Config.Sync.TimeZone)
+}
+
+// SyncTimetables fetches the timetables of all classes for the next Sync.TimetableDays days with the service account.
+// A failed class does not stop the others, the errors are returned together.
+func (database *Database) SyncTimetables(ctx context.Context) error {
+	location, err := syncLocation()
+	if err != nil {
+		return err
+	}
+	var classes []dbModels.Class
+	err = database.DB.NewSelect().Model(&classes).Column("id").Scan(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(location)
+	startDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	endDate := startDate.AddDate(0, 0, config.Config.Sync.TimetableDays)
+	var errs []error
+	for _, class := range classes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := database.FetchClassLessons(class.Id, startDate, endDate, ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("class %d: %w", class.Id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunSyncScheduler runs the jobs of Config.Sync on their schedules. It blocks, run it in a goroutine.
+// The timeout of a job cancels its database writes and the remaining classes of the timetables,
+// a running WebUntis request is not interrupted.
+func (database *Database) RunSyncScheduler() {
+	if !config.Config.Sync.Enabled {
+		return
+	}
+	location, err := syncLocation()
+	if err != nil {
+		log.Printf("Failed to start the Untis sync: %s", err.Error())
+		return
+	}
+	scheduler := cron.New(cron.WithLocation(location))
+	jobs := []*syncJob{
+		{name: "teachers", config: config.Config.Sync.Teachers, fetch: database.FetchTeachers},
+		{name: "rooms", config: config.Config.Sync.Rooms, fetch: database.FetchRooms},
+		{name: "subjects", config: config.Config.Sync.Subjects, fetch: database.FetchSubjects},
+		{name: "classes", config: config.Config.Sync.Classes, fetch: database.FetchClasses},
+		{name: "timetables", config: config.Config.Sync.Timetables, fetch: database.SyncTimetables},
+	}
+	for _, job := range jobs {
+		if job.config.Schedule == "" {
+			continue
+		}
+		_, err := scheduler.AddFunc(job.config.Schedule, func() { database.runSyncJob(job) })
+		if err != nil {
+			log.Printf("Invalid schedule %q of the %s sync: %s", job.config.Schedule, job.name, err.Error())
+		}
+	}
+	scheduler.Run()
+}
+
+// lockSyncJob takes the advisory lock of a job, so only one instance runs it at a time.
+// It returns false if another instance holds the lock. Call unlock when the job is done.
+func (database *Database) lockSyncJob(name string) (locked bool, unlock func(), err error) {
+	ctx := context.Background()
+	// Session locks belong to a connection, the lock has to be released on the one it was taken on.
+	conn, err := database.DB.Conn(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext(?))", "sync_"+name).Scan(&locked)
+	if err != nil || !locked {
+		conn.Close()
+		return false, nil, err
+	}
+	return true, func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext(?))", "sync_"+name); err != nil {
+			log.Printf("Failed to unlock the %s sync: %s", name, err.Error())
+		}
+		conn.Close()
+	}, nil
+}
+
+// runSyncJob runs a job after the jitter and records the run.
+func (database *Database) runSyncJob(job *syncJob) {
+	if !job.running.CompareAndSwap(false, true) {
+		database.recordSyncRun(dbModels.SyncRun{Job: job.name, StartedAt: time.Now(), Status: string(gen.SyncRunStatusSkipped)})
+		return
+	}
+	defer job.running.Store(false)
+	// The lock is taken before the jitter, an instance waking up later must not run the job a second time.
+	locked, unlock, err := database.lockSyncJob(job.name)
+	if err != nil {
+		log.Printf("Failed to lock the %s sync: %s", job.name, err.Error())
+		return
+	}
+	if !locked {
+		// Another instance runs the job and records the run.
+		return
+	}
+	defer unlock()
+	if config.Config.Sync.Jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(config.Config.Sync.Jitter))))
+	}
+
+	ctx := context.Background()
+	if job.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.config.Timeout)
+		defer cancel()
+	}
+	run := dbModels.SyncRun{Job: job.name, StartedAt: time.Now()}
+	err = job.execute(ctx)
+	run.FinishedAt = time.Now()
+	switch {
+	case err == nil:
+		run.Status = string(gen.SyncRunStatusSucceeded)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.Status = string(gen.SyncRunStatusTimeout)
+		run.Error = err.Error()
+	default:
+		run.Status = string(gen.SyncRunStatusFailed)
+		run.Error = err.Error()
+	}
+	if err != nil {
+		log.Printf("The %s sync failed: %s", job.name, err.Error())
+	}
+	database.recordSyncRun(run)
+}
+
+// recordSyncRun stores a run and deletes the runs older than Sync.RunRetention.
+func (database *Database) recordSyncRun(run dbModels.SyncRun) {
+	ctx := context.Background()
+	_, err := database.DB.NewInsert().Model(&run).Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to record the %s sync: %s", run.Job, err.Error())
+		return
+	}
+	if config.Config.Sync.RunRetention > 0 {
+		_, err = database.DB.NewDelete().
+			Model((*dbModels.SyncRun)(nil)).
+			Where("started_at < ?", time.Now().Add(-config.Config.Sync.RunRetention)).
+			Exec(ctx)
+		if err != nil {
+			log.Printf("Failed to delete old sync runs: %s", err.Error())
+		}
+	}
+}
+
+// GetSyncRuns returns the recorded runs of the Untis sync matching the filter, newest first.
+func (database *Database) GetSyncRuns(filter gen.GetUntisSyncParams, ctx context.Context) ([]gen.SyncRun, error) {
+	var runs []dbModels.SyncRun
+	query := database.DB.NewSelect().Model(&runs)
+	if filter.Job != nil {
+		query.Where("job = ?", *filter.Job)
+	}
+	if filter.Status != nil {
+		query.Where("status = ?", string(*filter.Status))
+	}
+	limit := 50
+	if filter.Limit != nil && *filter.Limit > 0 && *filter.Limit <= 1000 {
+		limit = *filter.Limit
+	}
+	err := query.Limit(limit).Order("id DESC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genRuns := make([]gen.SyncRun, len(runs))
+	for i, run := range runs {
+		genRuns[i] = run.ToGen()
+	}
+	return genRuns, nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+)
+
+// throttleLimits returns the free attempts and the lockout threshold for a throttle key.
+func throttleLimits(key string) (int, int) {
+	if strings.HasPrefix(key, "ip:") {
+		return config.Config.Throttle.IPFreeAttempts, config.Config.Throttle.IPLockoutThreshold
+	}
+	return config.Config.Throttle.FreeAttempts, config.Config.Throttle.LockoutThreshold
+}
+
+// throttleDelay returns the time the next attempt has to wait after the given number of failures.
+// The delay doubles with every failure after the free attempts.
+func throttleDelay(key string, failures int) (time.Duration, bool) {
+	free, lockout := throttleLimits(key)
+	if lockout > 0 && failures >= lockout {
+		return config.Config.Throttle.LockoutDuration, true
+	}
+	if failures <= free {
+		return 0, false
+	}
+	delay := config.Config.Throttle.BaseDelay
+	for i := free + 1; i < failures && delay < config.Config.Throttle.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > config.Config.Throttle.MaxDelay {
+		delay = config.Config.Throttle.MaxDelay
+	}
+	return delay, false
+}
+
+// CheckThrottle returns ErrThrottled and the time to wait if any of the keys is blocked in the scope.
+func (database *Database) CheckThrottle(scope string, keys []string, ctx context.Context) (time.Duration, error) {
+	var throttles []dbModels.Throttle
+	err := database.DB.NewSelect().
+		Model(&throttles).
+		Where("scope = ?", scope).
+		Where("\"key\" IN (?)", bun.In(keys)).
+		Where("blocked_until > ?", time.Now()).
+		Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var retryAfter time.Duration
+	for _, throttle := range throttles {
+		if wait := time.Until(throttle.BlockedUntil); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		return retryAfter, dbModels.ErrThrottled
+	}
+	return 0, nil
+}
+
+// RecordFailure counts a failed attempt for every key and blocks the keys that exceeded their free attempts.
+func (database *Database) RecordFailure(scope string, keys []string, ctx context.Context) error {
+	now := time.Now()
+	for _, key := range keys {
+		throttle := dbModels.Throttle{
+			Scope:       scope,
+			Key:         key,
+			Failures:    1,
+			LastFailure: now,
+		}
+		// Failures outside of the window start counting again
+		err := database.DB.NewInsert().
+			Model(&throttle).
+			On("CONFLICT (scope, \"key\") DO UPDATE").
+			Set("failures = CASE WHEN throttle.last_failure < ? THEN 1 ELSE throttle.failures + 1 END", now.Add(-config.Config.Throttle.Window)).
+			Set("last_failure = EXCLUDED.last_failure").
+			Returning("*").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		delay, locked := throttleDelay(key, throttle.Failures)
+		if delay == 0 {
+			continue
+		}
+		throttle.BlockedUntil = now.Add(delay)
+		throttle.Locked = locked
+		_, err = database.DB.NewUpdate().
+			Model(&throttle).
+			Column("blocked_until", "locked").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess forgets the failures of the keys after a successful attempt.
+func (database *Database) RecordSuccess(scope string, keys []string, ctx context.Context) error {
+	_, err := database.DB.NewDelete().
+		Model((*dbModels.Throttle)(nil)).
+		Where("scope = ?", scope).
+		Where("\"key\" IN (?)", bun.In(keys)).
+		Exec(ctx)
+	return err
+}
+
+// GetLockouts returns the blocked accounts and ip addresses. If all is set, entries with failures that are not blocked are included.
+func (database *Database) GetLockouts(all bool, ctx context.Context) ([]gen.Lockout, error) {
+	var throttles []dbModels.Throttle
+	query := database.DB.NewSelect().
+		Model(&throttles).
+		Order("last_failure DESC")
+	if !all {
+		query.Where("blocked_until > ?", time.Now())
+	}
+	err := query.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lockouts := make([]gen.Lockout, len(throttles))
+	for i, throttle := range throttles {
+		lockouts[i] = throttle.ToGen()
+	}
+	return lockouts, nil
+}
+
+// ClearLockout removes the failures of an account or ip address.
+func (database *Database) ClearLockout(id int, ctx context.Context) error {
+	res, err := database.DB.NewDelete().
+		Model((*dbModels.Throttle)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return dbModels.ErrLockoutNotFound
+	}
+	return nil
+}
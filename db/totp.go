@@ -0,0 +1,413 @@
+package db
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// TOTP parameters of RFC 6238. These are the defaults every authenticator app supports.
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// Number of time steps a code may be off, to allow for clock drift.
+	totpSkew = 1
+)
+
+const recoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpAssociatedData binds a sealed TOTP secret to its user.
+func totpAssociatedData(userId int) []byte {
+	return []byte(fmt.Sprintf("tmf totp secret|%d", userId))
+}
+
+// totpCode computes the code of a time step (RFC 4226 section 5.3).
+func totpCode(secret []byte, step int64) string {
+	mac := hmac.New(sha1.New, secret)
+	_ = binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// verifyTOTP checks a code against the current time steps. Steps up to lastUsedStep are rejected, so a code can not be replayed.
+func verifyTOTP(secret []byte, code string, lastUsedStep int64) (int64, bool) {
+	return verifyTOTPAt(secret, code, lastUsedStep, time.Now())
+}
+
+// verifyTOTPAt checks a code against the time steps around now, see verifyTOTP.
+func verifyTOTPAt(secret []byte, code string, lastUsedStep int64, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeCode removes the spaces and dashes users type into codes.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// generateRecoveryCodes creates new codes in the form XXXXX-XXXXX.
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		data := make([]byte, 7)
+		if _, err := rand.Read(data); err != nil {
+			return nil, err
+		}
+		code := totpEncoding.EncodeToString(data)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes removes all recovery codes of a user and stores new ones.
+func replaceRecoveryCodes(idb bun.IDB, userId int, ctx context.Context) ([]string, error) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	_, err = idb.NewDelete().
+		Model((*dbModels.RecoveryCode)(nil)).
+		Where("\"userId\" = ?", userId).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]dbModels.RecoveryCode, len(codes))
+	for i, code := range codes {
+		entries[i] = dbModels.RecoveryCode{UserId: userId, CodeHash: generateSHA256Hash(normalizeCode(code))}
+	}
+	_, err = idb.NewInsert().Model(&entries).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// secondFactorRequired reports if the role of a user has to log in with a second factor.
+func secondFactorRequired(role string) bool {
+	return slices.Contains(config.Config.TwoFactor.RequiredRoles, role)
+}
+
+// loadTOTP returns the TOTP entry of a user and the opened secret.
+func loadTOTP(idb bun.IDB, userId int, forUpdate bool, ctx context.Context) (dbModels.UserTOTP, []byte, error) {
+	totp := dbModels.UserTOTP{UserId: userId}
+	query := idb.NewSelect().Model(&totp).WherePK()
+	if forUpdate {
+		query.For("UPDATE")
+	}
+	err := query.Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dbModels.UserTOTP{}, nil, dbModels.ErrTOTPNotEnabled
+		}
+		return dbModels.UserTOTP{}, nil, err
+	}
+	secret, err := openKey(keyEncryptionKey(), totp.Secret, totpAssociatedData(userId))
+	if err != nil {
+		return dbModels.UserTOTP{}, nil, err
+	}
+	return totp, secret, nil
+}
+
+// verifySecondFactor accepts a TOTP code of a confirmed enrollment or an unused recovery code.
+func verifySecondFactor(idb bun.IDB, userId int, code string, ctx context.Context) error {
+	totp, secret, err := loadTOTP(idb, userId, true, ctx)
+	if err != nil {
+		return err
+	}
+	if !totp.Confirmed {
+		return dbModels.ErrTOTPNotEnabled
+	}
+	code = normalizeCode(code)
+	if step, ok := verifyTOTP(secret, code, totp.LastUsedStep); ok {
+		totp.LastUsedStep = step
+		_, err = idb.NewUpdate().Model(&totp).Column("lastUsedStep").WherePK().Exec(ctx)
+		return err
+	}
+	res, err := idb.NewUpdate().
+		Model((*dbModels.RecoveryCode)(nil)).
+		Set("used_at = ?", time.Now()).
+		Where("\"userId\" = ?", userId).
+		Where("\"codeHash\" = ?", generateSHA256Hash(code)).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return dbModels.ErrInvalidSecondFactor
+	}
+	return nil
+}
+
+// GetTOTPStatus returns if TOTP is enabled for a user and how many recovery codes are left.
+func (database *Database) GetTOTPStatus(user gen.User, ctx context.Context) (gen.TotpStatus, error) {
+	status := gen.TotpStatus{}
+	if user.Role != nil {
+		status.Required = secondFactorRequired(string(*user.Role))
+	}
+	totp := dbModels.UserTOTP{UserId: *user.Id}
+	err := database.DB.NewSelect().Model(&totp).WherePK().Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return status, nil
+		}
+		return status, err
+	}
+	status.Enabled = totp.Confirmed
+	if totp.Confirmed {
+		left, err := database.DB.NewSelect().
+			Model((*dbModels.RecoveryCode)(nil)).
+			Where("\"userId\" = ?", *user.Id).
+			Where("used_at IS NULL").
+			Count(ctx)
+		if err != nil {
+			return status, err
+		}
+		status.RecoveryCodesLeft = &left
+	}
+	return status, nil
+}
+
+// StartTOTPEnrollment creates a new TOTP secret for a user. The secret is only used after it was confirmed with a code.
+// A previous unconfirmed enrollment is replaced.
+func (database *Database) StartTOTPEnrollment(userId int, userName string, ctx context.Context) (gen.TotpEnrollment, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return gen.TotpEnrollment{}, err
+	}
+	sealed, err := sealKey(keyEncryptionKey(), secret, totpAssociatedData(userId))
+	if err != nil {
+		return gen.TotpEnrollment{}, err
+	}
+	totp := dbModels.UserTOTP{UserId: userId, Secret: sealed}
+	res, err := database.DB.NewInsert().
+		Model(&totp).
+		On("CONFLICT (\"userId\") DO UPDATE").
+		Set("secret = EXCLUDED.secret").
+		Set("\"lastUsedStep\" = 0").
+		Where("user_totp.confirmed = false").
+		Exec(ctx)
+	if err != nil {
+		return gen.TotpEnrollment{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return gen.TotpEnrollment{}, dbModels.ErrTOTPEnabled
+	}
+
+	encoded := totpEncoding.EncodeToString(secret)
+	issuer := config.Config.TwoFactor.Issuer
+	query := url.Values{}
+	query.Set("secret", encoded)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + userName,
+		RawQuery: query.Encode(),
+	}
+	return gen.TotpEnrollment{Secret: encoded, ProvisioningUri: uri.String()}, nil
+}
+
+// ConfirmTOTP enables TOTP after the first code was verified and returns new recovery codes.
+func (database *Database) ConfirmTOTP(userId int, code string, ctx context.Context) ([]string, error) {
+	var codes []string
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		totp, secret, err := loadTOTP(tx, userId, true, ctx)
+		if err != nil {
+			return err
+		}
+		if totp.Confirmed {
+			return dbModels.ErrTOTPEnabled
+		}
+		step, ok := verifyTOTP(secret, normalizeCode(code), totp.LastUsedStep)
+		if !ok {
+			return dbModels.ErrInvalidSecondFactor
+		}
+		totp.Confirmed = true
+		totp.LastUsedStep = step
+		_, err = tx.NewUpdate().Model(&totp).Column("confirmed", "lastUsedStep").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, userId, ctx)
+		return err
+	})
+	return codes, err
+}
+
+// DisableTOTP removes the TOTP secret and the recovery codes of a user.
+func (database *Database) DisableTOTP(userId int, ctx context.Context) error {
+	return database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*dbModels.UserTOTP)(nil)).
+			Where("\"userId\" = ?", userId).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*dbModels.RecoveryCode)(nil)).
+			Where("\"userId\" = ?", userId).
+			Exec(ctx)
+		return err
+	})
+}
+
+// VerifySecondFactor checks a TOTP or recovery code of a user with enabled TOTP.
+func (database *Database) VerifySecondFactor(userId int, code string, ctx context.Context) error {
+	return database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return verifySecondFactor(tx, userId, code, ctx)
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user with enabled TOTP.
+func (database *Database) RegenerateRecoveryCodes(userId int, ctx context.Context) ([]string, error) {
+	var codes []string
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userId, ctx)
+		return err
+	})
+	return codes, err
+}
+
+// generatePartialToken creates the token handed out after the password or the identity provider verified the user, while
+// the second factor is missing. It carries the crypto key, so the session can be created once the second factor was verified.
+func generatePartialToken(user dbModels.User, cryptoKey []byte) (string, error) {
+	id := uuid.NewString()
+	wrappedKey, err := wrapCryptoKey(cryptoKey, user.Id, id)
+	if err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		UserId:     user.Id,
+		Name:       user.Name,
+		Role:       user.Role,
+		PWD:        generateSHA256Hash(user.PwdHash)[:8],
+		WrappedKey: wrappedKey,
+		Partial:    true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.Config.TwoFactor.PartialTokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return signToken(claims)
+}
+
+// unpackPartialToken verifies a partial token and loads its user.
+func (database *Database) unpackPartialToken(partialToken string, ctx context.Context) (dbModels.User, *Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(partialToken, claims, jwtKeyFunc)
+	if err != nil {
+		return dbModels.User{}, nil, err
+	}
+	if !claims.Partial {
+		return dbModels.User{}, nil, dbModels.ErrInvalidToken
+	}
+	user := dbModels.User{Id: claims.UserId}
+	err = database.fetchUser(&user, ctx)
+	if err != nil {
+		return dbModels.User{}, nil, err
+	}
+	if claims.PWD != generateSHA256Hash(user.PwdHash)[:8] {
+		return dbModels.User{}, nil, dbModels.ErrInvalidToken
+	}
+	return user, claims, nil
+}
+
+// StartTOTPEnrollmentWithPartialToken enrolls TOTP during a login of a user whose role requires a second factor.
+func (database *Database) StartTOTPEnrollmentWithPartialToken(partialToken string, ctx context.Context) (gen.TotpEnrollment, error) {
+	user, _, err := database.unpackPartialToken(partialToken, ctx)
+	if err != nil {
+		return gen.TotpEnrollment{}, err
+	}
+	return database.StartTOTPEnrollment(user.Id, user.Name, ctx)
+}
+
+// CompleteLogin verifies the second factor of a login and creates the session.
+// If the TOTP enrollment was not confirmed yet, the code confirms it and the new recovery codes are returned.
+func (database *Database) CompleteLogin(partialToken string, code string, device string, ip string, ctx context.Context) (SessionTokens, gen.User, []string, error) {
+	user, claims, err := database.unpackPartialToken(partialToken, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, nil, err
+	}
+	cryptoKey, err := claims.UnwrapCryptoKey()
+	if err != nil {
+		return SessionTokens{}, gen.User{}, nil, err
+	}
+	var recoveryCodes []string
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		totp, secret, err := loadTOTP(tx, user.Id, true, ctx)
+		if err != nil {
+			return err
+		}
+		if totp.Confirmed {
+			return verifySecondFactor(tx, user.Id, code, ctx)
+		}
+		step, ok := verifyTOTP(secret, normalizeCode(code), totp.LastUsedStep)
+		if !ok {
+			return dbModels.ErrInvalidSecondFactor
+		}
+		totp.Confirmed = true
+		totp.LastUsedStep = step
+		_, err = tx.NewUpdate().Model(&totp).Column("confirmed", "lastUsedStep").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		recoveryCodes, err = replaceRecoveryCodes(tx, user.Id, ctx)
+		return err
+	})
+	if err != nil {
+		return SessionTokens{}, gen.User{}, nil, err
+	}
+	tokens, err := database.startSession(user, cryptoKey, device, ip, ctx)
+	if err != nil {
+		return SessionTokens{}, gen.User{}, nil, err
+	}
+	return tokens, user.ToGen(), recoveryCodes, nil
+}
+
+// PartialTokenUserId returns the id of the user a partial token was issued for. It is used to throttle second factor attempts.
+func PartialTokenUserId(partialToken string) (int, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(partialToken, claims, jwtKeyFunc)
+	if err != nil || !claims.Partial {
+		return 0, dbModels.ErrInvalidToken
+	}
+	return claims.UserId, nil
+}
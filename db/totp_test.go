@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// totpSecret is the SHA-1 secret of the test vectors of RFC 6238 appendix B.
+var totpSecret = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	// The last six digits of the SHA-1 test vectors of RFC 6238 appendix B
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		if got := totpCode(totpSecret, test.unix/totpPeriod); got != test.want {
+			t.Errorf("totpCode at %d = %s, want %s", test.unix, got, test.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+	tests := []struct {
+		name         string
+		code         string
+		lastUsedStep int64
+		wantStep     int64
+		wantOk       bool
+	}{
+		{"current step", totpCode(totpSecret, current), 0, current, true},
+		{"previous step", totpCode(totpSecret, current-1), 0, current - 1, true},
+		{"next step", totpCode(totpSecret, current+1), 0, current + 1, true},
+		{"two steps behind", totpCode(totpSecret, current-2), 0, 0, false},
+		{"two steps ahead", totpCode(totpSecret, current+2), 0, 0, false},
+		{"replayed", totpCode(totpSecret, current), current, 0, false},
+		{"used earlier step", totpCode(totpSecret, current), current - 1, current, true},
+		{"replayed later step", totpCode(totpSecret, current-1), current, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+		{"empty code", "", 0, 0, false},
+	}
+	for _, test := range tests {
+		step, ok := verifyTOTPAt(totpSecret, test.code, test.lastUsedStep, now)
+		if ok != test.wantOk || step != test.wantStep {
+			t.Errorf("%s: verifyTOTPAt = %d, %v, want %d, %v", test.name, step, ok, test.wantStep, test.wantOk)
+		}
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Mr-Comand/goUntisAPI/structs"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/dataCollectors"
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
 	"github.com/uptrace/bun"
@@ -188,6 +190,38 @@ func (database *Database) FetchLesson(genUser gen.User, untis_pwd string, classI
 	if err != nil {
 		return err
 	}
+	return database.storePeriods(periods, ctx)
+}
+
+// FetchClassLessons fetches the timetable of a class with the service account.
+func (database *Database) FetchClassLessons(classId int, startDate time.Time, endDate time.Time, ctx context.Context) error {
+	periods, err := dataCollectors.DataCollectors.UntisClient.GetLessonsByClass(dbModels.Class{Id: classId}, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	return database.storePeriods(periods, ctx)
+}
+
+// FetchOwnLessons fetches the personal timetable of the user with its Untis login.
+func (database *Database) FetchOwnLessons(genUser gen.User, untis_pwd string, startDate time.Time, endDate time.Time, ctx context.Context) error {
+	var user dbModels.User
+	user.FromGen(genUser)
+	untisName, err := database.GetUserSetting(user.Id, "untis", "untisName", ctx)
+	if err != nil {
+		return err
+	}
+	periods, err := dataCollectors.DataCollectors.UntisClient.GetOwnLessons(untisName, untis_pwd, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	return database.storePeriods(periods, ctx)
+}
+
+// storePeriods converts Untis periods to lessons and upserts them. Material changes are written as lesson versions.
+func (database *Database) storePeriods(periods []structs.Period, ctx context.Context) error {
+	if len(periods) == 0 {
+		return nil
+	}
 	lessons := make([]dbModels.Lesson, len(periods))
 	for i, period := range periods {
 		var subjectIds []string
@@ -259,11 +293,38 @@ func (database *Database) FetchLesson(genUser gen.User, untis_pwd string, classI
 			ChairUp:               chairUp,
 		}
 	}
-	lessonQuery := database.DB.NewInsert()
-	lessonQuery.Model(&lessons)
-	lessonQuery.On("CONFLICT (id) DO UPDATE")
-	_, err = lessonQuery.Exec(ctx)
-	return err
+	var versions []dbModels.LessonVersion
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := lockLessonChanges(tx, ctx); err != nil {
+			return err
+		}
+		var changed []int
+		var err error
+		versions, changed, err = lessonVersions(tx, lessons, ctx)
+		if err != nil {
+			return err
+		}
+		lessonQuery := tx.NewInsert()
+		lessonQuery.Model(&lessons)
+		lessonQuery.On("CONFLICT (id) DO UPDATE")
+		_, err = lessonQuery.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if len(versions) > 0 {
+			_, err = tx.NewInsert().Model(&versions).Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return recordLessonChanges(tx, changed, ctx)
+	})
+	if err != nil {
+		return err
+	}
+	go database.notifyLessonChanges(versions, lessons)
+	go database.emitLessonChanges(versions, lessons)
+	return nil
 }
 func placeholderArray(arr []string) string {
 	placeholders := make([]string, len(arr))
@@ -319,6 +380,38 @@ func (database *Database) GetLesson(filter dbModels.LessonFilter, ctx context.Co
 	if filter.User.Id == 0 {
 		return nil, errors.New("user ID is required to get lessons")
 	}
+	choice, err := database.resolveLessonChoice(&filter, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lessonQuery := database.DB.NewSelect()
+	lessons := make([]dbModels.Lesson, 0)
+	lessonQuery.Model(&lessons)
+	err = whereLessonChoice(lessonQuery, filter.User, choice)
+	if err != nil {
+		return nil, err
+	}
+	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() {
+		lessonQuery.Where("start_time >= ? AND end_time <= ?", filter.StartDate, filter.EndDate)
+	}
+	if len(filter.LessonIds) > 0 {
+		lessonQuery.Where("\"lesson\".\"id\" IN (?)", bun.In(filter.LessonIds))
+	}
+	if filter.ChangedAfter > 0 {
+		lessonQuery.Where("\"lesson\".\"id\" IN (SELECT \"lessonId\" FROM lesson_change WHERE seq > ?)", filter.ChangedAfter)
+	}
+	err = lessonQuery.Scan(ctx)
+	genLesson := make([]gen.Lesson, len(lessons))
+	for i, c := range lessons {
+		genLesson[i] = c.ToGen()
+	}
+	return genLesson, err
+}
+
+// resolveLessonChoice loads the user of the filter and returns the choice to filter the lessons by:
+// the choice of the filter, or the default choice of the user.
+func (database *Database) resolveLessonChoice(filter *dbModels.LessonFilter, ctx context.Context) (dbModels.Choice, error) {
 	var choice dbModels.Choice
 	// get Choice
 	if filter.Choice.Id == 0 {
@@ -330,12 +423,16 @@ func (database *Database) GetLesson(filter dbModels.LessonFilter, ctx context.Co
 			err := query.Scan(ctx)
 			if err != nil {
 				if errors.Is(err, sql.ErrNoRows) {
-					return nil, dbModels.ErrUserNotFound
+					return dbModels.Choice{}, dbModels.ErrUserNotFound
 				}
-				return nil, err
+				return dbModels.Choice{}, err
 			}
 			if filter.User.DefaultChoice == nil {
-				return nil, fmt.Errorf("no DefaultChoice")
+				if filter.ClassFallback {
+					// An empty choice matches the classes of the user
+					return dbModels.Choice{}, nil
+				}
+				return dbModels.Choice{}, dbModels.ErrNoDefaultChoice
 			} else {
 				choice = *filter.User.DefaultChoice
 			}
@@ -348,9 +445,9 @@ func (database *Database) GetLesson(filter dbModels.LessonFilter, ctx context.Co
 			err := userQuery.Scan(ctx)
 			if err != nil {
 				if errors.Is(err, sql.ErrNoRows) {
-					return nil, dbModels.ErrUserNotFound
+					return dbModels.Choice{}, dbModels.ErrUserNotFound
 				}
-				return nil, err
+				return dbModels.Choice{}, err
 			}
 			//TODO: check class
 		}
@@ -362,61 +459,64 @@ func (database *Database) GetLesson(filter dbModels.LessonFilter, ctx context.Co
 		err := userQuery.Scan(ctx)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				return nil, dbModels.ErrUserNotFound
+				return dbModels.Choice{}, dbModels.ErrUserNotFound
 			}
-			return nil, err
+			return dbModels.Choice{}, err
 		}
 
 		query := database.DB.NewSelect()
 		query.Model(&filter.Choice)
 		query.WherePK()
-		if filter.User.Role != string(gen.UserRoleAdmin) {
+		// Only users allowed to read all choices may use the choices of others
+		if !authz.Can(filter.User.Role, authz.ChoicesRead) {
 			query.Where("\"choice\".\"userId\" = ?", filter.User.Id)
 		}
 		err = query.Scan(ctx)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				return nil, dbModels.ErrChoiceNotFound
+				return dbModels.Choice{}, dbModels.ErrChoiceNotFound
 			}
-			return nil, err
+			return dbModels.Choice{}, err
 		}
 		choice = filter.Choice
 	}
 
-	lessonQuery := database.DB.NewSelect()
-	lessons := make([]dbModels.Lesson, 0)
-	lessonQuery.Model(&lessons)
+	return choice, nil
+}
 
+// whereLessonChoice limits a query on the lesson table to the lessons of the choice, or to the classes of the user without one.
+// The conditions are grouped, so further conditions of the query apply to all of them.
+func whereLessonChoice(lessonQuery *bun.SelectQuery, user dbModels.User, choice dbModels.Choice) error {
 	var result map[string]interface{}
 	parsingError := json.Unmarshal([]byte(choice.Choice), &result)
 
 	if parsingError != nil || choice.Choice == "" || len(result) == 0 {
-		lessonQuery.Where("\"lesson\".\"classes\" @> ?", pgdialect.Array(filter.User.Classes))
-	} else {
-		for key, value := range result {
-			if classID, err := strconv.Atoi(key); err == nil {
-				subjects, err := parseInterfaceToStringArray(value)
-				if err != nil {
-					return nil, err
-				}
-				if len(subjects) == 0 {
-					lessonQuery.WhereOr("(\"lesson\".\"classes\" \\?| ARRAY[?])", key)
-				} else if classID > 0 {
-					lessonQuery.WhereOr("(\"lesson\".\"classes\" \\?| ARRAY[?] AND \"lesson\".\"subjects\" \\?| "+placeholderArray(subjects)+")", append([]interface{}{key}, dataArray(subjects)...)...)
-				} else { //TODO: If a Class ID is present as a negative as well as a positive value only the positive should be used.
-					lessonQuery.WhereOr("(\"lesson\".\"classes\" \\?| ARRAY[?] AND NOT \"lesson\".\"subjects\" \\?| "+placeholderArray(subjects)+")", append([]interface{}{key}, dataArray(subjects)...)...)
-
-				}
-			}
-		}
+		lessonQuery.Where("\"lesson\".\"classes\" @> ?", pgdialect.Array(user.Classes))
+		return nil
 	}
-	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() {
-		lessonQuery.Where("start_time >= ? AND end_time <= ?", filter.StartDate, filter.EndDate)
-	}
-	err := lessonQuery.Scan(ctx)
-	genLesson := make([]gen.Lesson, len(lessons))
-	for i, c := range lessons {
-		genLesson[i] = c.ToGen()
+	choiceSubjects := make(map[string][]string, len(result))
+	for key, value := range result {
+		if _, err := strconv.Atoi(key); err != nil {
+			continue
+		}
+		subjects, err := parseInterfaceToStringArray(value)
+		if err != nil {
+			return err
+		}
+		choiceSubjects[key] = subjects
 	}
-	return genLesson, err
+	lessonQuery.WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+		for key, subjects := range choiceSubjects {
+			classID, _ := strconv.Atoi(key)
+			if len(subjects) == 0 {
+				query.WhereOr("(\"lesson\".\"classes\" \\?| ARRAY[?])", key)
+			} else if classID > 0 {
+				query.WhereOr("(\"lesson\".\"classes\" \\?| ARRAY[?] AND \"lesson\".\"subjects\" \\?| "+placeholderArray(subjects)+")", append([]interface{}{key}, dataArray(subjects)...)...)
+			} else { //TODO: If a Class ID is present as a negative as well as a positive value only the positive should be used.
+				query.WhereOr("(\"lesson\".\"classes\" \\?| ARRAY[?] AND NOT \"lesson\".\"subjects\" \\?| "+placeholderArray(subjects)+")", append([]interface{}{key}, dataArray(subjects)...)...)
+			}
+		}
+		return query
+	})
+	return nil
 }
@@ -3,27 +3,22 @@ package db
 import (
 	"context"
 	"database/sql"
-	"encoding/base64"
 	"errors"
 	"strconv"
 
 	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/dataCollectors"
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/policy"
+	"github.com/uptrace/bun"
 )
 
-func checkUsernameCriteria(username string) bool {
-	if len(username) >= 5 {
-		return true
-	}
-	return false
-}
 func (database *Database) CreateUser(user gen.User, pwd string, ctx context.Context) (gen.User, error) {
-	if !checkUsernameCriteria(user.Name) {
-		return gen.User{}, dbModels.ErrUsernameNotMachRequirements
+	if err := policy.CheckUsername(user.Name); err != nil {
+		return gen.User{}, err
 	}
-	if len(pwd) == 0 {
-		return gen.User{}, dbModels.ErrPasswordNotMachRequirements
+	if err := policy.CheckPassword(pwd, user.Name); err != nil {
+		return gen.User{}, err
 	}
 	// unset DefaultChoice.Id to Prevent Collisions
 	if user.DefaultChoice == nil {
@@ -106,25 +101,96 @@ func (database *Database) fetchUser(user *dbModels.User, ctx context.Context) er
 	return nil
 
 }
-func (database *Database) DeleteUserByID(id int, ctx context.Context) error {
-	var user dbModels.User
-	query := database.DB.NewDelete()
-	query.Model(&user)
-	query.Where("\"user\".\"id\" = ?", id)
-	_, err := query.Exec(ctx) //sql.ErrNoRows
-	if err != nil {
-		return err
-	}
-	var choice dbModels.Choice
-	query = database.DB.NewDelete()
-	query.Model(&choice)
-	query.Where("\"choice\".\"userId\" = ?", id)
-	_, err = query.Exec(ctx) //sql.ErrNoRows
-	if err != nil {
-		return err
-	}
-	return nil
 
+// DeleteUserByID deletes a user with everything that belongs to them in one transaction.
+func (database *Database) DeleteUserByID(id int, ctx context.Context) error {
+	return database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*dbModels.User)(nil)).
+			Where("\"user\".\"id\" = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*dbModels.Choice)(nil)).
+			Where("\"choice\".\"userId\" = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.UserSetting)(nil)).Where("userid = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*dbModels.RefreshToken)(nil)).
+			Where("\"sessionId\" IN (SELECT id FROM session WHERE \"userId\" = ?)", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.Session)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.UserTOTP)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.RecoveryCode)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.PasswordResetToken)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.UserIdentity)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.UserDataKey)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.OIDCLoginState)(nil)).Where("\"linkUserId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*dbModels.GuardianLink)(nil)).
+			Where("\"studentId\" = ? OR \"guardianId\" = ?", id, id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.PushSubscription)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.PushPreferences)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.PushPending)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		err = deleteWebhooksOfUser(tx, id, ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*dbModels.CalendarFeed)(nil)).Where("\"userId\" = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		err = revokeAccessTokens(tx, id, ctx)
+		if err != nil {
+			return err
+		}
+		return revokeAppPasswords(tx, id, ctx)
+	})
 }
 func (database *Database) GetUsers(ctx context.Context) ([]gen.User, error) {
 	var users []dbModels.User
@@ -146,13 +212,6 @@ func (database *Database) UpdateUntisLogin(genUser gen.User, untisName, forename
 	var user dbModels.User
 	user.FromGen(genUser)
 
-	// Encrypt the password before storing
-	encryptData, err := encrypt([]byte(untisPWD), key)
-	if err != nil {
-		return err
-	}
-	encodedPWD := base64.StdEncoding.EncodeToString(encryptData)
-
 	// Call UntisClient setup
 	untisId, personType, classId, err := dataCollectors.DataCollectors.UntisClient.SetupStudent(untisName, forename, surname, untisPWD)
 	if err != nil {
@@ -162,7 +221,7 @@ func (database *Database) UpdateUntisLogin(genUser gen.User, untisName, forename
 	if err := database.UpdateUserSetting(user.Id, "untis", "untisName", untisName, ctx); err != nil {
 		return err
 	}
-	if err := database.UpdateUserSetting(user.Id, "untis", "untisPWD", encodedPWD, ctx); err != nil {
+	if err := database.UpdateSecretUserSetting(user.Id, "untis", "untisPWD", untisPWD, key, ctx); err != nil {
 		return err
 	}
 	if err := database.UpdateUserSetting(user.Id, "untis", "userId", strconv.Itoa(untisId), ctx); err != nil {
@@ -174,6 +233,10 @@ func (database *Database) UpdateUntisLogin(genUser gen.User, untisName, forename
 	if err := database.UpdateUserSetting(user.Id, "untis", "classId", strconv.Itoa(classId), ctx); err != nil {
 		return err
 	}
+	// The account is connected again after a password reset.
+	if err := database.DeleteUserSetting(user.Id, "account", "untisReconnectRequired", ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -181,14 +244,17 @@ func (database *Database) GetUntisLogin(genUser gen.User, key []byte, ctx contex
 	var user dbModels.User
 	user.FromGen(genUser)
 
-	// Retrieve the encrypted UntisPWD setting
-	encPWD, err := database.GetUserSetting(user.Id, "untis", "untisPWD", ctx)
+	// Retrieve and decrypt the UntisPWD setting
+	untisPWD, err := database.GetSecretUserSetting(user.Id, "untis", "untisPWD", key, ctx)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", "", dbModels.ErrUserNotFound
 		}
 		return "", "", err
 	}
+	if len(untisPWD) == 0 {
+		return "", "", errors.New("no untis login")
+	}
 
 	// Retrieve the UntisName setting
 	untisName, err := database.GetUserSetting(user.Id, "untis", "untisName", ctx)
@@ -199,24 +265,11 @@ func (database *Database) GetUntisLogin(genUser gen.User, key []byte, ctx contex
 		return "", "", err
 	}
 
-	// Decode and decrypt the password
-	untisPWD, err := base64.StdEncoding.DecodeString(encPWD)
-	if err != nil {
-		return "", "", err
-	}
-	if len(untisPWD) == 0 {
-		return "", "", errors.New("no untis login")
-	}
-	decryptData, err := decrypt(untisPWD, key)
-	if err != nil {
-		return "", "", err
-	}
-
-	return untisName, string(decryptData), nil
+	return untisName, untisPWD, nil
 }
-func (database *Database) GetUntisLoginByCryptoKey(CryptoKey string, user gen.User, ctx context.Context) (string, string, error) {
+func (database *Database) GetUntisLoginByClaims(claims *Claims, user gen.User, ctx context.Context) (string, string, error) {
 
-	key, err := base64.StdEncoding.DecodeString(CryptoKey)
+	key, err := claims.UnwrapCryptoKey()
 	if err != nil {
 		return "", "", err
 	}
@@ -234,6 +287,9 @@ func (database *Database) UpdateUser(user gen.User, ctx context.Context) error {
 	columns := []string{}
 
 	if dbUser.Name != user.Name {
+		if err := policy.CheckUsername(user.Name); err != nil {
+			return err
+		}
 		dbUser.Name = user.Name
 		columns = append(columns, "name")
 	}
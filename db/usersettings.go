@@ -2,6 +2,9 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"log"
 
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
 )
@@ -34,3 +37,79 @@ func (database *Database) GetUserSetting(userID int, settingType, settingName st
 		Scan(ctx)
 	return setting.SettingsVariable, err
 }
+
+// DeleteUserSetting removes a user setting. Removing a setting that does not exist is not an error.
+func (database *Database) DeleteUserSetting(userID int, settingType, settingName string, ctx context.Context) error {
+	setting := dbModels.UserSetting{
+		UserID:      userID,
+		SettingType: settingType,
+		SettingName: settingName,
+	}
+	_, err := database.DB.NewDelete().
+		Model(&setting).
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+// secretSettings lists the settings that are stored encrypted with the crypto key of the user.
+var secretSettings = []struct {
+	SettingType string
+	SettingName string
+}{
+	{"untis", "untisPWD"},
+}
+
+// UpdateSecretUserSetting encrypts a setting with the crypto key of the user and stores it.
+func (database *Database) UpdateSecretUserSetting(userID int, settingType, settingName, settingValue string, key []byte, ctx context.Context) error {
+	encValue, err := encryptSetting(userID, settingType, settingName, []byte(settingValue), key)
+	if err != nil {
+		return err
+	}
+	return database.UpdateUserSetting(userID, settingType, settingName, encValue, ctx)
+}
+
+// GetSecretUserSetting retrieves and decrypts a setting stored with UpdateSecretUserSetting.
+func (database *Database) GetSecretUserSetting(userID int, settingType, settingName string, key []byte, ctx context.Context) (string, error) {
+	encValue, err := database.GetUserSetting(userID, settingType, settingName, ctx)
+	if err != nil {
+		return "", err
+	}
+	if encValue == "" {
+		return "", nil
+	}
+	value, _, err := decryptSetting(userID, settingType, settingName, encValue, key)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// migrateSecretUserSettings re-encrypts all secret settings of a user still stored in the legacy AES-CBC format.
+func (database *Database) migrateSecretUserSettings(userID int, key []byte, ctx context.Context) error {
+	for _, setting := range secretSettings {
+		encValue, err := database.GetUserSetting(userID, setting.SettingType, setting.SettingName, ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+		if encValue == "" {
+			continue
+		}
+		value, legacy, err := decryptSetting(userID, setting.SettingType, setting.SettingName, encValue, key)
+		if err != nil {
+			return err
+		}
+		if !legacy {
+			continue
+		}
+		err = database.UpdateSecretUserSetting(userID, setting.SettingType, setting.SettingName, string(value), key, ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Migrated setting %s/%s of user %d to AES-GCM.", setting.SettingType, setting.SettingName, userID)
+	}
+	return nil
+}
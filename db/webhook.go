@@ -0,0 +1,544 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+	"github.com/uptrace/bun"
+)
+
+// webhookSecretPurpose is the associated data prefix of sealed webhook secrets.
+const webhookSecretPurpose = "tmf webhook secret"
+
+// webhookBatchSize is the number of due deliveries claimed at once.
+const webhookBatchSize = 20
+
+// ErrWebhookAddress is returned when a webhook url resolves to an address that is not allowed.
+var ErrWebhookAddress = errors.New("db: The webhook url resolves to a loopback, private or link local address")
+
+// webhookWake wakes the delivery worker after new deliveries were queued.
+var webhookWake = make(chan struct{}, 1)
+
+// newWebhookSecret returns a random signing secret and its sealed form for the webhook of a user.
+func newWebhookSecret(userId int) (string, string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	secret = "whsec_" + secret
+	sealed, err := sealKey(keyEncryptionKey(), []byte(secret), []byte(fmt.Sprintf("%s|%d", webhookSecretPurpose, userId)))
+	return secret, sealed, err
+}
+
+// openWebhookSecret returns the plain signing secret of a webhook.
+func openWebhookSecret(webhook dbModels.Webhook) ([]byte, error) {
+	return openKey(keyEncryptionKey(), webhook.SealedSecret, []byte(fmt.Sprintf("%s|%d", webhookSecretPurpose, webhook.UserId)))
+}
+
+// GetWebhooks returns the webhooks of a user.
+func (database *Database) GetWebhooks(userId int, ctx context.Context) ([]gen.Webhook, error) {
+	var webhooks []dbModels.Webhook
+	err := database.DB.NewSelect().Model(&webhooks).Where("\"userId\" = ?", userId).Order("id").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genWebhooks := make([]gen.Webhook, len(webhooks))
+	for i, webhook := range webhooks {
+		genWebhooks[i] = webhook.ToGen()
+	}
+	return genWebhooks, nil
+}
+
+// CreateWebhook registers a webhook with a new signing secret. The secret is only returned here.
+func (database *Database) CreateWebhook(webhook dbModels.Webhook, ctx context.Context) (gen.NewWebhook, error) {
+	secret, sealed, err := newWebhookSecret(webhook.UserId)
+	if err != nil {
+		return gen.NewWebhook{}, err
+	}
+	webhook.SealedSecret = sealed
+	webhook.Active = true
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Lock the user, so two webhooks at once can not both pass the limit
+		err := tx.NewSelect().Model((*dbModels.User)(nil)).Column("id").Where("id = ?", webhook.UserId).For("UPDATE").Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return dbModels.ErrUserNotFound
+			}
+			return err
+		}
+		count, err := tx.NewSelect().Model((*dbModels.Webhook)(nil)).Where("\"userId\" = ?", webhook.UserId).Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count >= config.Config.Webhooks.MaxPerUser {
+			return dbModels.ErrWebhookLimitReached
+		}
+		_, err = tx.NewInsert().Model(&webhook).Returning("*").Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return gen.NewWebhook{}, err
+	}
+	return gen.NewWebhook{Secret: secret, Webhook: webhook.ToGen()}, nil
+}
+
+// getWebhook returns a webhook of a user.
+func (database *Database) getWebhook(userId int, webhookId int, ctx context.Context) (dbModels.Webhook, error) {
+	var webhook dbModels.Webhook
+	err := database.DB.NewSelect().Model(&webhook).Where("id = ?", webhookId).Where("\"userId\" = ?", userId).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhook, dbModels.ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// UpdateWebhook changes the fields of a webhook that are set in the body.
+func (database *Database) UpdateWebhook(userId int, webhookId int, body gen.PutUsersUserIdWebhooksWebhookIdJSONBody, ctx context.Context) (gen.Webhook, error) {
+	webhook, err := database.getWebhook(userId, webhookId, ctx)
+	if err != nil {
+		return gen.Webhook{}, err
+	}
+	if body.Url != nil {
+		webhook.URL = *body.Url
+	}
+	if body.Events != nil {
+		webhook.Events = make([]string, len(*body.Events))
+		for i, event := range *body.Events {
+			webhook.Events[i] = string(event)
+		}
+	}
+	if body.Description != nil {
+		webhook.Description = *body.Description
+	}
+	if body.Active != nil {
+		webhook.Active = *body.Active
+	}
+	_, err = database.DB.NewUpdate().Model(&webhook).Column("url", "events", "description", "active").WherePK().Exec(ctx)
+	if err != nil {
+		return gen.Webhook{}, err
+	}
+	return webhook.ToGen(), nil
+}
+
+// RotateWebhookSecret replaces the signing secret of a webhook. Queued deliveries are signed with the new secret.
+func (database *Database) RotateWebhookSecret(userId int, webhookId int, ctx context.Context) (gen.NewWebhook, error) {
+	webhook, err := database.getWebhook(userId, webhookId, ctx)
+	if err != nil {
+		return gen.NewWebhook{}, err
+	}
+	secret, sealed, err := newWebhookSecret(webhook.UserId)
+	if err != nil {
+		return gen.NewWebhook{}, err
+	}
+	webhook.SealedSecret = sealed
+	_, err = database.DB.NewUpdate().Model(&webhook).Column("sealedSecret").WherePK().Exec(ctx)
+	if err != nil {
+		return gen.NewWebhook{}, err
+	}
+	return gen.NewWebhook{Secret: secret, Webhook: webhook.ToGen()}, nil
+}
+
+// DeleteWebhook deletes a webhook of a user with its deliveries.
+func (database *Database) DeleteWebhook(userId int, webhookId int, ctx context.Context) error {
+	return database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewDelete().
+			Model((*dbModels.Webhook)(nil)).
+			Where("id = ?", webhookId).
+			Where("\"userId\" = ?", userId).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+			return dbModels.ErrWebhookNotFound
+		}
+		_, err = tx.NewDelete().Model((*dbModels.WebhookDelivery)(nil)).Where("\"webhookId\" = ?", webhookId).Exec(ctx)
+		return err
+	})
+}
+
+// deleteWebhooksOfUser deletes the webhooks of a user with their deliveries.
+func deleteWebhooksOfUser(idb bun.IDB, userId int, ctx context.Context) error {
+	_, err := idb.NewDelete().
+		Model((*dbModels.WebhookDelivery)(nil)).
+		Where("\"webhookId\" IN (SELECT id FROM webhook WHERE \"userId\" = ?)", userId).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = idb.NewDelete().Model((*dbModels.Webhook)(nil)).Where("\"userId\" = ?", userId).Exec(ctx)
+	return err
+}
+
+// GetWebhookDeliveries returns the deliveries of a webhook of a user, newest first.
+func (database *Database) GetWebhookDeliveries(userId int, webhookId int, params gen.GetUsersUserIdWebhooksWebhookIdDeliveriesParams, ctx context.Context) ([]gen.WebhookDelivery, error) {
+	if _, err := database.getWebhook(userId, webhookId, ctx); err != nil {
+		return nil, err
+	}
+	limit := 100
+	if params.Limit != nil && *params.Limit > 0 && *params.Limit <= 1000 {
+		limit = *params.Limit
+	}
+	var deliveries []dbModels.WebhookDelivery
+	query := database.DB.NewSelect().
+		Model(&deliveries).
+		Relation("Event", func(query *bun.SelectQuery) *bun.SelectQuery {
+			return query.Column("type")
+		}).
+		Where("\"webhook_delivery\".\"webhookId\" = ?", webhookId).
+		Order("webhook_delivery.id DESC").
+		Limit(limit)
+	if params.Status != nil {
+		query.Where("\"webhook_delivery\".status = ?", string(*params.Status))
+	}
+	err := query.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genDeliveries := make([]gen.WebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		genDeliveries[i] = delivery.ToGen()
+	}
+	return genDeliveries, nil
+}
+
+// RetryWebhookDelivery queues a delivery of a webhook of a user again, with a fresh number of attempts.
+func (database *Database) RetryWebhookDelivery(userId int, webhookId int, deliveryId int64, ctx context.Context) (gen.WebhookDelivery, error) {
+	if _, err := database.getWebhook(userId, webhookId, ctx); err != nil {
+		return gen.WebhookDelivery{}, err
+	}
+	delivery := dbModels.WebhookDelivery{Id: deliveryId}
+	res, err := database.DB.NewUpdate().
+		Model(&delivery).
+		Set("status = ?", string(gen.WebhookDeliveryStatusPending)).
+		Set("attempts = 0").
+		Set("next_attempt_at = ?", time.Now()).
+		WherePK().
+		Where("\"webhookId\" = ?", webhookId).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return gen.WebhookDelivery{}, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return gen.WebhookDelivery{}, dbModels.ErrWebhookDeliveryNotFound
+	}
+	wakeWebhookDelivery()
+	delivery.Event = &dbModels.Event{Id: delivery.EventId}
+	err = database.DB.NewSelect().Model(delivery.Event).Column("type").WherePK().Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return gen.WebhookDelivery{}, err
+	}
+	return delivery.ToGen(), nil
+}
+
+// queueWebhookDeliveries queues the stored events for the webhooks that subscribed to them. Lesson changes are only
+// queued for webhooks of users whose choice includes the lesson, or that manage the webhooks of every account.
+func (database *Database) queueWebhookDeliveries(events []dbModels.Event, ctx context.Context) {
+	var webhooks []dbModels.Webhook
+	err := database.DB.NewSelect().Model(&webhooks).Relation("User").Where("\"webhook\".active").Scan(ctx)
+	if err != nil {
+		log.Printf("Failed to load the webhooks: %s", err.Error())
+		return
+	}
+	var lessonIds []int
+	for _, event := range events {
+		if event.LessonId != 0 {
+			lessonIds = append(lessonIds, event.LessonId)
+		}
+	}
+	now := time.Now()
+	lessonsByUser := make(map[int]map[int]bool)
+	var deliveries []dbModels.WebhookDelivery
+	for _, webhook := range webhooks {
+		subscribed := make(map[string]bool, len(webhook.Events))
+		for _, event := range webhook.Events {
+			subscribed[event] = true
+		}
+		allLessons := webhook.User != nil && authz.Can(webhook.User.Role, authz.WebhooksManage)
+		for _, event := range events {
+			if !subscribed[event.Type] {
+				continue
+			}
+			if event.LessonId != 0 && !allLessons {
+				matching, ok := lessonsByUser[webhook.UserId]
+				if !ok {
+					matching = make(map[int]bool)
+					ids, err := database.lessonsOfUser(webhook.UserId, lessonIds, ctx)
+					if err != nil {
+						log.Printf("Failed to match the lesson changes of user %d: %s", webhook.UserId, err.Error())
+					}
+					for _, id := range ids {
+						matching[id] = true
+					}
+					lessonsByUser[webhook.UserId] = matching
+				}
+				if !matching[event.LessonId] {
+					continue
+				}
+			}
+			deliveries = append(deliveries, dbModels.WebhookDelivery{
+				WebhookId:     webhook.Id,
+				EventId:       event.Id,
+				Status:        string(gen.WebhookDeliveryStatusPending),
+				NextAttemptAt: now,
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	_, err = database.DB.NewInsert().Model(&deliveries).Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to queue the webhook deliveries: %s", err.Error())
+		return
+	}
+	wakeWebhookDelivery()
+}
+
+// wakeWebhookDelivery makes the worker look for due deliveries now instead of at the next poll.
+func wakeWebhookDelivery() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// webhookBackoff returns the delay before the next attempt after the given number of failed attempts.
+func webhookBackoff(attempts int) time.Duration {
+	delay := config.Config.Webhooks.RetryBaseDelay
+	for i := 1; i < attempts && delay < config.Config.Webhooks.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > config.Config.Webhooks.RetryMaxDelay {
+		delay = config.Config.Webhooks.RetryMaxDelay
+	}
+	return delay
+}
+
+// publicAddress reports if ip is reachable on the internet, webhooks must not reach into the network of the server.
+func publicAddress(ip net.IP) bool {
+	_, sharedAddressSpace, _ := net.ParseCIDR("100.64.0.0/10")
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// newWebhookClient returns the client of the deliveries. It checks the address after the name was resolved,
+// so a host name pointing to an internal address is rejected too, and it does not follow redirects.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: config.Config.Webhooks.Timeout}
+	if !config.Config.Webhooks.AllowPrivateNetworks {
+		dialer.Control = func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !publicAddress(ip) {
+				return ErrWebhookAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: config.Config.Webhooks.Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: config.Config.Webhooks.Timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// RunWebhookDelivery sends the due webhook deliveries every Webhooks.PollInterval and whenever new ones are queued.
+// It blocks, run it in a goroutine.
+func (database *Database) RunWebhookDelivery() {
+	if !config.Config.Webhooks.Enabled {
+		return
+	}
+	client := newWebhookClient()
+	ticker := time.NewTicker(config.Config.Webhooks.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+		database.deliverDueWebhooks(client)
+	}
+}
+
+// deliverDueWebhooks sends the due deliveries until none are left.
+func (database *Database) deliverDueWebhooks(client *http.Client) {
+	ctx := context.Background()
+	for {
+		deliveries, err := database.claimDueWebhookDeliveries(ctx)
+		if err != nil {
+			log.Printf("Failed to claim the due webhook deliveries: %s", err.Error())
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+		// The deliveries of a webhook are sent in order, different webhooks at once
+		byWebhook := make(map[int][]dbModels.WebhookDelivery)
+		for _, delivery := range deliveries {
+			byWebhook[delivery.WebhookId] = append(byWebhook[delivery.WebhookId], delivery)
+		}
+		done := make(chan struct{})
+		for _, webhookDeliveries := range byWebhook {
+			go func(webhookDeliveries []dbModels.WebhookDelivery) {
+				for _, delivery := range webhookDeliveries {
+					database.deliverWebhook(client, delivery, ctx)
+				}
+				done <- struct{}{}
+			}(webhookDeliveries)
+		}
+		for range byWebhook {
+			<-done
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// claimDueWebhookDeliveries returns due deliveries of active webhooks and moves their next attempt behind the time
+// needed to send them, so other instances skip them meanwhile. A crashed instance leaves them due again afterwards.
+func (database *Database) claimDueWebhookDeliveries(ctx context.Context) ([]dbModels.WebhookDelivery, error) {
+	var ids []int64
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+		err := tx.NewSelect().
+			Model((*dbModels.WebhookDelivery)(nil)).
+			Column("id").
+			Where("status = ?", string(gen.WebhookDeliveryStatusPending)).
+			Where("next_attempt_at <= ?", now).
+			Where("\"webhookId\" IN (SELECT id FROM webhook WHERE active)").
+			Order("id").
+			Limit(webhookBatchSize).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx, &ids)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		lease := config.Config.Webhooks.Timeout * (webhookBatchSize + 1)
+		_, err = tx.NewUpdate().
+			Model((*dbModels.WebhookDelivery)(nil)).
+			Set("next_attempt_at = ?", now.Add(lease)).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		return err
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	var deliveries []dbModels.WebhookDelivery
+	err = database.DB.NewSelect().
+		Model(&deliveries).
+		Relation("Event").
+		Relation("Webhook").
+		Where("\"webhook_delivery\".id IN (?)", bun.In(ids)).
+		Order("webhook_delivery.id").
+		Scan(ctx)
+	return deliveries, err
+}
+
+// webhookSignature returns the X-TMF-Signature header of a payload: the unix timestamp and the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>".
+func webhookSignature(secret []byte, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unix + "."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook sends a delivery and records the result. Failed deliveries are due again after the backoff,
+// after Webhooks.MaxAttempts they are dead.
+func (database *Database) deliverWebhook(client *http.Client, delivery dbModels.WebhookDelivery, ctx context.Context) {
+	statusCode, err := sendWebhook(client, delivery)
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status = string(gen.WebhookDeliveryStatusSucceeded)
+		delivery.LastError = ""
+		delivery.DeliveredAt = now
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= config.Config.Webhooks.MaxAttempts {
+			delivery.Status = string(gen.WebhookDeliveryStatusDead)
+		} else {
+			delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+		}
+	}
+	_, err = database.DB.NewUpdate().
+		Model(&delivery).
+		Column("status", "attempts", "next_attempt_at", "lastError", "lastStatusCode", "delivered_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to record the webhook delivery %d: %s", delivery.Id, err.Error())
+	}
+}
+
+// sendWebhook posts the event of a delivery to its webhook.
+func sendWebhook(client *http.Client, delivery dbModels.WebhookDelivery) (int, error) {
+	if delivery.Event == nil || delivery.Webhook == nil {
+		return 0, errors.New("the event or the webhook was deleted")
+	}
+	secret, err := openWebhookSecret(*delivery.Webhook)
+	if err != nil {
+		return 0, errors.New("the signing secret can not be read, replace it")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"id":        delivery.Event.Id,
+		"type":      delivery.Event.Type,
+		"createdAt": delivery.Event.CreatedAt,
+		"data":      delivery.Event.Data,
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TMF-Timetable-Webhook")
+	req.Header.Set("X-TMF-Event", delivery.Event.Type)
+	req.Header.Set("X-TMF-Delivery", strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set("X-TMF-Signature", webhookSignature(secret, time.Now(), body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("the webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"time"
 
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/dataCollectors"
 	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
 )
@@ -42,6 +44,15 @@ func (database *Database) CreateWeekSubtitle(date time.Time, subtitle string, ct
 		return err
 	}
 
+	event, err := newEvent(gen.WebhookEventWeekSubtitleCreated, 0, map[string]string{
+		"date":     monday.Format("2006-01-02"),
+		"subtitle": subtitle,
+	})
+	if err != nil {
+		log.Printf("Failed to create the event of the week subtitle of %s: %s", monday.Format("2006-01-02"), err.Error())
+		return nil
+	}
+	go database.emitEvents([]dbModels.Event{event})
 	return nil
 }
 func (database *Database) GetWeekSubtitle(date time.Time, ctx context.Context) (string, error) {
@@ -0,0 +1,95 @@
+// Package events fans the stored change events out to the open streams of this instance.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Event is a change of the stored data, e.g. a changed lesson or an updated menu.
+type Event struct {
+	Id        int64
+	Type      string
+	CreatedAt time.Time
+	// The changed lesson of lesson.changed events.
+	LessonId int
+	// JSON encoded data of the event.
+	Data json.RawMessage
+}
+
+// Subscriber receives the published events on C. C is closed when the subscriber fell behind or unsubscribed.
+type Subscriber struct {
+	C chan Event
+}
+
+// Hub publishes events to any number of subscribers. Publishing never blocks on a slow subscriber.
+// Events are published in the order of their ids.
+type Hub struct {
+	mutex       sync.Mutex
+	subscribers map[*Subscriber]struct{}
+	// Every event up to lastId was published. ready is false until the listener marked where it started.
+	lastId int64
+	ready  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[*Subscriber]struct{}{}}
+}
+
+// DefaultHub receives the events of the database, see db.RunEventListener.
+var DefaultHub = NewHub()
+
+// Subscribe adds a subscriber that buffers up to buffer events.
+func (hub *Hub) Subscribe(buffer int) *Subscriber {
+	subscriber := &Subscriber{C: make(chan Event, buffer)}
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+// Unsubscribe removes a subscriber and closes its channel. It does nothing if the subscriber was already removed.
+func (hub *Hub) Unsubscribe(subscriber *Subscriber) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.remove(subscriber)
+}
+
+func (hub *Hub) remove(subscriber *Subscriber) {
+	if _, ok := hub.subscribers[subscriber]; ok {
+		delete(hub.subscribers, subscriber)
+		close(subscriber.C)
+	}
+}
+
+// MarkPublished records that every event up to id was published without publishing them,
+// e.g. the events stored before the instance started. Streams replay those from the database.
+func (hub *Hub) MarkPublished(id int64) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.lastId, hub.ready = id, true
+}
+
+// LastId returns the id up to which every event was published. ok is false before the listener started.
+// A subscriber receives every event after the LastId read after it subscribed.
+func (hub *Hub) LastId() (id int64, ok bool) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	return hub.lastId, hub.ready
+}
+
+// Publish sends the event to every subscriber. A subscriber with a full buffer is removed instead of waiting for it,
+// it can catch up from the stored events.
+func (hub *Hub) Publish(event Event) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.lastId, hub.ready = event.Id, true
+	for subscriber := range hub.subscribers {
+		select {
+		case subscriber.C <- event:
+		default:
+			hub.remove(subscriber)
+		}
+	}
+}
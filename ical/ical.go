@@ -0,0 +1,143 @@
+// Package ical writes iCalendar files (RFC 5545) for calendar subscriptions and CalDAV.
+package ical
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a VEVENT. All day events use the dates of Start and End, End is the day after the last day.
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Summary     string
+	Location    string
+	Description string
+	// "CONFIRMED", "TENTATIVE" or "CANCELLED", empty to omit it.
+	Status       string
+	LastModified time.Time
+}
+
+// Calendar is a VCALENDAR with its events.
+type Calendar struct {
+	Name string
+	// METHOD of the calendar, "PUBLISH" for subscriptions. CalDAV resources have none.
+	Method string
+	// How often subscribed clients should reload the calendar, 0 to omit it.
+	RefreshInterval time.Duration
+	// DTSTAMP of events without LastModified.
+	Stamp  time.Time
+	Events []Event
+}
+
+const prodId = "-//TooManyFiles//TMF Timetable//EN"
+
+// Encode returns the calendar in the text/calendar format.
+func (calendar Calendar) Encode() []byte {
+	var builder strings.Builder
+	writeLine(&builder, "BEGIN:VCALENDAR")
+	writeLine(&builder, "VERSION:2.0")
+	writeLine(&builder, "PRODID:"+prodId)
+	writeLine(&builder, "CALSCALE:GREGORIAN")
+	if calendar.Method != "" {
+		writeLine(&builder, "METHOD:"+calendar.Method)
+	}
+	if calendar.Name != "" {
+		writeLine(&builder, "X-WR-CALNAME:"+escapeText(calendar.Name))
+	}
+	if calendar.RefreshInterval > 0 {
+		interval := duration(calendar.RefreshInterval)
+		writeLine(&builder, "REFRESH-INTERVAL;VALUE=DURATION:"+interval)
+		writeLine(&builder, "X-PUBLISHED-TTL:"+interval)
+	}
+	for _, event := range calendar.Events {
+		stamp := event.LastModified
+		if stamp.IsZero() {
+			stamp = calendar.Stamp
+		}
+		writeLine(&builder, "BEGIN:VEVENT")
+		writeLine(&builder, "UID:"+escapeText(event.UID))
+		writeLine(&builder, "DTSTAMP:"+dateTime(stamp))
+		if event.AllDay {
+			writeLine(&builder, "DTSTART;VALUE=DATE:"+event.Start.Format("20060102"))
+			writeLine(&builder, "DTEND;VALUE=DATE:"+event.End.Format("20060102"))
+		} else {
+			writeLine(&builder, "DTSTART:"+dateTime(event.Start))
+			writeLine(&builder, "DTEND:"+dateTime(event.End))
+		}
+		writeLine(&builder, "SUMMARY:"+escapeText(event.Summary))
+		if event.Location != "" {
+			writeLine(&builder, "LOCATION:"+escapeText(event.Location))
+		}
+		if event.Description != "" {
+			writeLine(&builder, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		if event.Status != "" {
+			writeLine(&builder, "STATUS:"+event.Status)
+		}
+		if !event.LastModified.IsZero() {
+			writeLine(&builder, "LAST-MODIFIED:"+dateTime(event.LastModified))
+		}
+		if event.AllDay {
+			writeLine(&builder, "TRANSP:TRANSPARENT")
+		}
+		writeLine(&builder, "END:VEVENT")
+	}
+	writeLine(&builder, "END:VCALENDAR")
+	return []byte(builder.String())
+}
+
+// dateTime formats a time in UTC.
+func dateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// duration formats a duration like PT1H30M, rounded to minutes.
+func duration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+	result := "PT"
+	if minutes >= 60 {
+		result += strconv.Itoa(minutes/60) + "H"
+	}
+	if minutes%60 != 0 {
+		result += strconv.Itoa(minutes%60) + "M"
+	}
+	return result
+}
+
+// escapeText escapes a TEXT value.
+func escapeText(text string) string {
+	return strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+		"\r", "",
+	).Replace(text)
+}
+
+// writeLine writes a content line, folded after 75 octets without splitting UTF-8 characters.
+func writeLine(builder *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Do not cut inside a multi byte character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		builder.WriteString(line[:cut])
+		builder.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with the space
+		limit = 74
+	}
+	builder.WriteString(line)
+	builder.WriteString("\r\n")
+}
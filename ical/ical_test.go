@@ -0,0 +1,108 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Math", "Math"},
+		{"Room 1, 2; 3", `Room 1\, 2\; 3`},
+		{`C:\Users`, `C:\\Users`},
+		{"line 1\r\nline 2", `line 1\nline 2`},
+		{"line 1\nline 2", `line 1\nline 2`},
+		{"line 1\rline 2", "line 1line 2"},
+		{`\n`, `\\n`},
+		{"Französisch", "Französisch"},
+	}
+	for _, test := range tests {
+		if got := escapeText(test.text); got != test.want {
+			t.Errorf("escapeText(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"short", "SUMMARY:Math", []string{"SUMMARY:Math"}},
+		{"75 octets", strings.Repeat("a", 75), []string{strings.Repeat("a", 75)}},
+		{"76 octets", strings.Repeat("a", 76), []string{strings.Repeat("a", 75), " a"}},
+		{
+			// The euro sign takes the octets 74 to 76 and moves to the next line as a whole
+			"multi-byte character at the boundary",
+			strings.Repeat("a", 74) + "€b",
+			[]string{strings.Repeat("a", 74), " €b"},
+		},
+		{
+			"multi-byte character ending at the boundary",
+			strings.Repeat("a", 72) + "€b",
+			[]string{strings.Repeat("a", 72) + "€", " b"},
+		},
+		{
+			"continuation lines",
+			strings.Repeat("a", 75+74+1),
+			[]string{strings.Repeat("a", 75), " " + strings.Repeat("a", 74), " a"},
+		},
+	}
+	for _, test := range tests {
+		var builder strings.Builder
+		writeLine(&builder, test.line)
+		want := strings.Join(test.want, "\r\n") + "\r\n"
+		if builder.String() != want {
+			t.Errorf("%s: writeLine wrote %q, want %q", test.name, builder.String(), want)
+		}
+	}
+}
+
+func TestWriteLineFolding(t *testing.T) {
+	line := "DESCRIPTION:" + escapeText(strings.Repeat("Vertretung für Französisch ✓ 🎉\r\n", 10))
+	var builder strings.Builder
+	writeLine(&builder, line)
+	output := builder.String()
+	if !strings.HasSuffix(output, "\r\n") {
+		t.Fatalf("the line does not end with CRLF: %q", output)
+	}
+	physical := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
+	for i, part := range physical {
+		if len(part) > 75 {
+			t.Errorf("line %d has %d octets: %q", i, len(part), part)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("line %d splits a character: %q", i, part)
+		}
+		if i > 0 && !strings.HasPrefix(part, " ") {
+			t.Errorf("continuation line %d does not start with a space: %q", i, part)
+		}
+	}
+	if unfolded := strings.ReplaceAll(strings.TrimSuffix(output, "\r\n"), "\r\n ", ""); unfolded != line {
+		t.Errorf("unfolded line = %q, want %q", unfolded, line)
+	}
+}
+
+func TestEncodeDescriptionWithLineBreaks(t *testing.T) {
+	calendar := Calendar{Stamp: time.Date(2024, 9, 2, 6, 0, 0, 0, time.UTC), Events: []Event{{
+		UID:         "lesson-1@timetable.example",
+		Start:       time.Date(2024, 9, 2, 8, 0, 0, 0, time.UTC),
+		End:         time.Date(2024, 9, 2, 8, 45, 0, 0, time.UTC),
+		Summary:     "Math",
+		Description: "Substitution\r\nRoom changed",
+	}}}
+	output := string(calendar.Encode())
+	if !strings.Contains(output, "\r\nDESCRIPTION:Substitution\\nRoom changed\r\n") {
+		t.Errorf("the description is not one escaped line:\n%s", output)
+	}
+	// Every line break of the file is a CRLF
+	if strings.Count(output, "\n") != strings.Count(output, "\r\n") {
+		t.Errorf("the calendar contains a bare line feed:\n%q", output)
+	}
+}
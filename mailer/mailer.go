@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+)
+
+// Mailer sends plain text mails to users.
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+var DefaultMailer Mailer
+
+// InitMailer selects the mailer configured in Mailer.Type.
+func InitMailer() {
+	switch config.Config.Mailer.Type {
+	case "smtp":
+		DefaultMailer = SMTPMailer{
+			Host:     config.Config.Mailer.SMTP.Host,
+			Port:     config.Config.Mailer.SMTP.Port,
+			Username: config.Config.Mailer.SMTP.Username,
+			Password: config.Config.Mailer.SMTP.Password,
+			From:     config.Config.Mailer.From,
+		}
+	case "file":
+		DefaultMailer = FileMailer{
+			Dir:  config.Config.Mailer.FileDir,
+			From: config.Config.Mailer.From,
+		}
+	default:
+		DefaultMailer = LogMailer{}
+	}
+}
+
+// SMTPMailer sends mails through a SMTP server using PLAIN auth.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (mailer SMTPMailer) Send(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", mailer.Host, mailer.Port)
+	return smtp.SendMail(addr, auth, mailer.From, []string{to}, buildMessage(mailer.From, to, subject, body))
+}
+
+// FileMailer writes every mail as .eml file into a directory. Meant for development and tests.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (mailer FileMailer) Send(to string, subject string, body string) error {
+	if err := os.MkdirAll(mailer.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(to))
+	return os.WriteFile(filepath.Join(mailer.Dir, name), buildMessage(mailer.From, to, subject, body), 0600)
+}
+
+// LogMailer only logs that a mail would have been sent. The body is not logged as it may contain secrets.
+type LogMailer struct{}
+
+func (mailer LogMailer) Send(to string, subject string, body string) error {
+	log.Printf("No mailer configured. Dropped mail %q to %s.", subject, to)
+	return nil
+}
+
+func buildMessage(from string, to string, subject string, body string) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+	return []byte("From: " + header.Replace(from) + "\r\n" +
+		"To: " + header.Replace(to) + "\r\n" +
+		"Subject: " + header.Replace(subject) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		body)
+}
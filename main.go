@@ -18,6 +18,8 @@ import (
 	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/dataCollectors"
 	"github.com/TooManyFiles/TMF-Timetable-Backend/db"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/mailer"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/webpush"
 	"github.com/rs/cors"
 )
 
@@ -29,6 +31,8 @@ func main() {
 		panic(err)
 	}
 	dataCollectors.InitDataCollectors()
+	mailer.InitMailer()
+	webpush.InitWebPush()
 	initDB()
 	initServer()
 
@@ -36,6 +40,12 @@ func main() {
 
 func initDB() {
 	database = db.NewDatabase(config.Config.DatabaseConfig)
+	go database.RunSigningKeyRotation()
+	go database.RunAuditRetention()
+	go database.RunSyncScheduler()
+	go database.RunWebhookDelivery()
+	go database.RunPendingPush()
+	go database.RunEventListener()
 }
 
 func initServer() {
@@ -43,17 +53,24 @@ func initServer() {
 	server := api.NewServer(database)
 
 	r := http.NewServeMux()
+	// CalDAV uses WebDAV methods the spec can not describe, so it is routed by hand
+	r.Handle("/caldav/", server.CalDAVHandler())
+	r.Handle("/.well-known/caldav", server.CalDAVHandler())
 
 	// get an `http.Handler` that we can use
-	h := gen.HandlerFromMux(server, r)
+	h := gen.HandlerWithOptions(server, gen.StdHTTPServerOptions{
+		BaseRouter: r,
+		// Middlewares run in reverse order: AuthMiddleware authenticates before PermissionMiddleware checks the role
+		Middlewares: []gen.MiddlewareFunc{server.PermissionMiddleware, server.AuthMiddleware},
+	})
 	handler := cors.New(cors.Options{
-		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodHead, http.MethodOptions, http.MethodPut},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete},
 		Logger:           log.Default(),
 		Debug:            true,
 		AllowedHeaders:   []string{"*"},
 		AllowCredentials: true,
 		AllowedOrigins:   config.Config.AllowedOrigins,
-	}).Handler(h)
+	}).Handler(api.SecurityHeadersMiddleware(api.CSRFMiddleware(h)))
 
 	s := &http.Server{
 		Handler:                      handler,
@@ -0,0 +1,155 @@
+// Package policy checks passwords and usernames against the rules configured in config.Config.
+package policy
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/api/gen"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// Most common passwords of leaked password lists, lower case, one per line.
+// Taken from the password frequency list bundled with zxcvbn (MIT license).
+//
+//go:embed common-passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]struct{} {
+	passwords := map[string]struct{}{}
+	for _, password := range strings.Split(commonPasswordList, "\n") {
+		if password != "" {
+			passwords[password] = struct{}{}
+		}
+	}
+	return passwords
+}()
+
+// bcryptMaxBytes is the longest password bcrypt can hash.
+const bcryptMaxBytes = 72
+
+// Error lists every rule a password or username violates.
+// It unwraps to ErrPasswordNotMachRequirements or ErrUsernameNotMachRequirements, so callers can keep checking for those.
+type Error struct {
+	Violations []gen.PolicyViolation
+	err        error
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return e.err.Error() + ": " + strings.Join(messages, " ")
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// ToGen returns the response body for the violations.
+func (e *Error) ToGen() gen.PolicyViolations {
+	message := "Password does not match the requirements."
+	if e.err == dbModels.ErrUsernameNotMachRequirements {
+		message = "Username does not match the requirements."
+	}
+	return gen.PolicyViolations{Message: message, Violations: e.Violations}
+}
+
+// CheckPassword checks a new password of the user with the given name.
+func CheckPassword(password string, username string) error {
+	rules := config.Config.PasswordPolicy
+	var violations []gen.PolicyViolation
+	violate := func(rule string, message string) {
+		violations = append(violations, gen.PolicyViolation{Field: "password", Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < rules.MinLength || password == "" {
+		violate("minLength", fmt.Sprintf("Must be at least %d characters long.", max(rules.MinLength, 1)))
+	}
+	if rules.MaxLength > 0 && length > rules.MaxLength {
+		violate("maxLength", fmt.Sprintf("Must not be longer than %d characters.", rules.MaxLength))
+	}
+	if len(password) > bcryptMaxBytes {
+		violate("maxBytes", fmt.Sprintf("Must not be longer than %d bytes.", bcryptMaxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if rules.RequireUpper && !upper {
+		violate("upper", "Must contain an upper case letter.")
+	}
+	if rules.RequireLower && !lower {
+		violate("lower", "Must contain a lower case letter.")
+	}
+	if rules.RequireDigit && !digit {
+		violate("digit", "Must contain a digit.")
+	}
+	if rules.RequireSymbol && !symbol {
+		violate("symbol", "Must contain a symbol.")
+	}
+	classes := 0
+	for _, present := range []bool{upper, lower, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < rules.MinCharacterClasses {
+		violate("characterClasses", fmt.Sprintf("Must contain at least %d of: upper case letters, lower case letters, digits, symbols.", rules.MinCharacterClasses))
+	}
+
+	if rules.RejectCommon {
+		if _, common := commonPasswords[strings.ToLower(password)]; common {
+			violate("common", "Is one of the most common passwords.")
+		}
+	}
+	if rules.RejectUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violate("containsUsername", "Must not contain the username.")
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations, err: dbModels.ErrPasswordNotMachRequirements}
+	}
+	return nil
+}
+
+// CheckUsername checks the name of a new user.
+func CheckUsername(username string) error {
+	rules := config.Config.UsernamePolicy
+	var violations []gen.PolicyViolation
+	violate := func(rule string, message string) {
+		violations = append(violations, gen.PolicyViolation{Field: "username", Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(username)
+	if length < rules.MinLength || username == "" {
+		violate("minLength", fmt.Sprintf("Must be at least %d characters long.", max(rules.MinLength, 1)))
+	}
+	if rules.MaxLength > 0 && length > rules.MaxLength {
+		violate("maxLength", fmt.Sprintf("Must not be longer than %d characters.", rules.MaxLength))
+	}
+	if !rules.MatchPattern(username) {
+		violate("pattern", "Contains characters that are not allowed.")
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations, err: dbModels.ErrUsernameNotMachRequirements}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	dbModels "github.com/TooManyFiles/TMF-Timetable-Backend/db/models"
+)
+
+// rules returns the names of the violated rules of err.
+func rules(t *testing.T, err error, want error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyError *Error
+	if !errors.As(err, &policyError) || !errors.Is(err, want) {
+		t.Fatalf("error = %v, want a policy error wrapping %v", err, want)
+	}
+	names := make([]string, len(policyError.Violations))
+	for i, violation := range policyError.Violations {
+		names[i] = violation.Rule
+	}
+	return names
+}
+
+func TestCheckPassword(t *testing.T) {
+	previous := config.Config.PasswordPolicy
+	t.Cleanup(func() { config.Config.PasswordPolicy = previous })
+
+	tests := []struct {
+		name     string
+		policy   config.PasswordPolicyConfig
+		password string
+		username string
+		want     []string
+	}{
+		{"valid", config.PasswordPolicyConfig{MinLength: 8}, "staple battery", "jdoe", nil},
+		{"empty", config.PasswordPolicyConfig{}, "", "jdoe", []string{"minLength"}},
+		{"too short", config.PasswordPolicyConfig{MinLength: 8}, "short", "jdoe", []string{"minLength"}},
+		// Lengths count characters, not bytes
+		{"multi-byte at min length", config.PasswordPolicyConfig{MinLength: 8}, "äöüßäöüß", "jdoe", nil},
+		{"multi-byte at max length", config.PasswordPolicyConfig{MaxLength: 8}, "äöüßäöüß", "jdoe", nil},
+		{"multi-byte too long", config.PasswordPolicyConfig{MaxLength: 8}, "äöüßäöüßä", "jdoe", []string{"maxLength"}},
+		{"too long", config.PasswordPolicyConfig{MaxLength: 10}, "staple battery", "jdoe", []string{"maxLength"}},
+		{"over the bcrypt limit", config.PasswordPolicyConfig{MaxLength: 72}, strings.Repeat("ä", 37), "jdoe", []string{"maxBytes"}},
+		{"at the bcrypt limit", config.PasswordPolicyConfig{}, strings.Repeat("a", 72), "jdoe", nil},
+		{
+			"character classes",
+			config.PasswordPolicyConfig{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			"staple battery", "jdoe",
+			[]string{"upper", "digit"},
+		},
+		{
+			"all character classes",
+			config.PasswordPolicyConfig{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			"Staple battery 9", "jdoe",
+			nil,
+		},
+		{"min character classes", config.PasswordPolicyConfig{MinCharacterClasses: 3}, "staple battery", "jdoe", []string{"characterClasses"}},
+		{"enough character classes", config.PasswordPolicyConfig{MinCharacterClasses: 3}, "Staple battery", "jdoe", nil},
+		{"common", config.PasswordPolicyConfig{RejectCommon: true}, "Password", "jdoe", []string{"common"}},
+		{"common allowed", config.PasswordPolicyConfig{}, "password", "jdoe", nil},
+		{"contains username", config.PasswordPolicyConfig{RejectUsername: true}, "my name is JDoe", "jdoe", []string{"containsUsername"}},
+		{"no username", config.PasswordPolicyConfig{RejectUsername: true}, "staple battery", "", nil},
+		{"several rules", config.PasswordPolicyConfig{MinLength: 8, RequireDigit: true}, "jdoe", "jdoe", []string{"minLength", "digit"}},
+	}
+	for _, test := range tests {
+		config.Config.PasswordPolicy = test.policy
+		got := rules(t, CheckPassword(test.password, test.username), dbModels.ErrPasswordNotMachRequirements)
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: violated rules = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckUsername(t *testing.T) {
+	previous := config.Config.UsernamePolicy
+	t.Cleanup(func() { config.Config.UsernamePolicy = previous })
+
+	tests := []struct {
+		name     string
+		policy   config.UsernamePolicyConfig
+		username string
+		want     []string
+	}{
+		{"valid", config.UsernamePolicyConfig{MinLength: 3, MaxLength: 8, Pattern: `^[a-z.]+$`}, "j.doe", nil},
+		{"empty", config.UsernamePolicyConfig{}, "", []string{"minLength"}},
+		{"too short", config.UsernamePolicyConfig{MinLength: 5}, "jdoe", []string{"minLength"}},
+		{"too long", config.UsernamePolicyConfig{MaxLength: 3}, "jdoe", []string{"maxLength"}},
+		{"multi-byte at max length", config.UsernamePolicyConfig{MaxLength: 4}, "jösé", nil},
+		{"pattern", config.UsernamePolicyConfig{Pattern: `^[a-z.]+$`}, "j doe", []string{"pattern"}},
+		{"no pattern", config.UsernamePolicyConfig{}, "j doe", nil},
+		{"several rules", config.UsernamePolicyConfig{MinLength: 5, Pattern: `^[a-z]+$`}, "j.d", []string{"minLength", "pattern"}},
+	}
+	for _, test := range tests {
+		config.Config.UsernamePolicy = test.policy
+		got := rules(t, CheckUsername(test.username), dbModels.ErrUsernameNotMachRequirements)
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: violated rules = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package sso
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/authz"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrDisabled = errors.New("sso: OIDC login is disabled")
+var ErrNoIDToken = errors.New("sso: The token response contains no id_token")
+var ErrNonceMismatch = errors.New("sso: The nonce of the id_token does not match")
+
+// Identity is the account of a user at the identity provider.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	// Role of the user mapped with OIDC.RoleMapping.
+	Role string
+}
+
+// Provider wraps the discovered identity provider.
+type Provider struct {
+	issuer   string
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth2   oauth2.Config
+}
+
+var (
+	providerMu      sync.Mutex
+	defaultProvider *Provider
+)
+
+// GetProvider returns the configured identity provider. The discovery document is fetched on first use,
+// so the server can start while the identity provider is not reachable. It is fetched again if OIDC.Issuer changed.
+func GetProvider(ctx context.Context) (*Provider, error) {
+	if !config.Config.OIDC.Enabled {
+		return nil, ErrDisabled
+	}
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if defaultProvider != nil && defaultProvider.issuer == config.Config.OIDC.Issuer {
+		return defaultProvider, nil
+	}
+	provider, err := oidc.NewProvider(ctx, config.Config.OIDC.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	checkRoleMapping()
+	scopes := config.Config.OIDC.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	defaultProvider = &Provider{
+		issuer:   config.Config.OIDC.Issuer,
+		provider: provider,
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.Config.OIDC.ClientID}),
+		oauth2: oauth2.Config{
+			ClientID:     config.Config.OIDC.ClientID,
+			ClientSecret: config.Config.OIDC.ClientSecret,
+			RedirectURL:  config.Config.OIDC.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+	}
+	return defaultProvider, nil
+}
+
+// AuthCodeURL returns the url of the identity provider the browser is redirected to.
+// verifier is the PKCE code verifier, only its S256 challenge is sent.
+func (provider *Provider) AuthCodeURL(state string, nonce string, verifier string) string {
+	return provider.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange redeems the authorization code and verifies the returned id_token.
+func (provider *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (Identity, error) {
+	token, err := provider.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, ErrNoIDToken
+	}
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, err
+	}
+	if idToken.Nonce != nonce {
+		return Identity{}, ErrNonceMismatch
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, err
+	}
+	identity := Identity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Role:    mapRole(claims[config.Config.OIDC.RoleClaim]),
+	}
+	identity.Username, _ = claims["preferred_username"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	return identity, nil
+}
+
+// rolePriority decides which role is used if the claim maps to several roles. Custom roles rank below the
+// built in roles, in the order of their names.
+var rolePriority = []string{"admin", "teacher", "student", "guardian"}
+
+// rank returns the position of a role in rolePriority.
+func rank(role string) int {
+	if i := slices.Index(rolePriority, role); i >= 0 {
+		return i
+	}
+	return len(rolePriority)
+}
+
+// mapRole maps the role claim, a string or a list of strings, with OIDC.RoleMapping.
+// Mappings to roles that are neither built in nor configured in Roles are ignored.
+func mapRole(claim interface{}) string {
+	var values []string
+	switch claim := claim.(type) {
+	case string:
+		values = []string{claim}
+	case []interface{}:
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	var mapped []string
+	for _, v := range values {
+		// viper lowercases map keys, so the mapping is matched case insensitive
+		for key, role := range config.Config.OIDC.RoleMapping {
+			if strings.EqualFold(key, v) && authz.RoleExists(role) && !slices.Contains(mapped, role) {
+				mapped = append(mapped, role)
+			}
+		}
+	}
+	if len(mapped) == 0 {
+		return config.Config.OIDC.DefaultRole
+	}
+	slices.SortFunc(mapped, func(a, b string) int {
+		if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return mapped[0]
+}
+
+// checkRoleMapping logs the mapped roles that do not exist, as users mapped to them get the default role.
+func checkRoleMapping() {
+	for key, role := range config.Config.OIDC.RoleMapping {
+		if !authz.RoleExists(role) {
+			log.Printf("Warning: OIDC.RoleMapping maps %q to the unknown role %q, it is ignored.", key, role)
+		}
+	}
+	if !authz.RoleExists(config.Config.OIDC.DefaultRole) {
+		log.Printf("Warning: OIDC.DefaultRole %q is not a known role.", config.Config.OIDC.DefaultRole)
+	}
+}
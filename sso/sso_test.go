@@ -0,0 +1,146 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/sso/ssotest"
+)
+
+// withIdentityProvider starts a local identity provider and configures it as OIDC.Issuer for a test.
+func withIdentityProvider(t *testing.T) *ssotest.Server {
+	t.Helper()
+	server, err := ssotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := config.Config.OIDC
+	t.Cleanup(func() {
+		config.Config.OIDC = previous
+		server.Close()
+	})
+	config.Config.OIDC.Enabled = true
+	config.Config.OIDC.Issuer = server.URL
+	config.Config.OIDC.ClientID = "tmf-timetable"
+	config.Config.OIDC.RedirectURL = "https://timetable.example/oidc/callback"
+	config.Config.OIDC.RoleClaim = "groups"
+	config.Config.OIDC.RoleMapping = map[string]string{"teachers": "teacher", "students": "student"}
+	config.Config.OIDC.DefaultRole = "student"
+	return server
+}
+
+// authorize follows the authorization url like a browser and returns the code of the callback.
+func authorize(t *testing.T, provider *Provider, state string, nonce string, verifier string) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(provider.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || res.StatusCode != http.StatusFound {
+		t.Fatalf("authorization failed with status %d", res.StatusCode)
+	}
+	if callback.Query().Get("state") != state {
+		t.Fatalf("callback state = %q, want %q", callback.Query().Get("state"), state)
+	}
+	return callback.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	server := withIdentityProvider(t)
+	server.Login(ssotest.User{Subject: "1234", Claims: map[string]interface{}{
+		"preferred_username": "jdoe",
+		"email":              "jdoe@school.example",
+		"email_verified":     true,
+		"groups":             []string{"staff", "Teachers"},
+	}})
+	ctx := context.Background()
+	provider, err := GetProvider(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := authorize(t, provider, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	identity, err := provider.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{
+		Issuer:        server.URL,
+		Subject:       "1234",
+		Username:      "jdoe",
+		Email:         "jdoe@school.example",
+		EmailVerified: true,
+		Role:          "teacher",
+	}
+	if identity != want {
+		t.Errorf("Exchange = %+v, want %+v", identity, want)
+	}
+
+	if _, err := provider.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verifier", "nonce"); err == nil {
+		t.Error("a code could be redeemed twice")
+	}
+	code = authorize(t, provider, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	if _, err := provider.Exchange(ctx, code, "other-verifier-other-verifier-other-verifier", "nonce"); err == nil {
+		t.Error("the code was redeemed with the wrong PKCE verifier")
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	server := withIdentityProvider(t)
+	server.Login(ssotest.User{Subject: "1234"})
+	server.ForceNonce("nonce of another login")
+	ctx := context.Background()
+	provider, err := GetProvider(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := authorize(t, provider, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	_, err = provider.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verifier", "nonce")
+	if !errors.Is(err, ErrNonceMismatch) {
+		t.Errorf("Exchange error = %v, want %v", err, ErrNonceMismatch)
+	}
+}
+
+func TestMapRole(t *testing.T) {
+	previous, roles := config.Config.OIDC, config.Config.Roles
+	t.Cleanup(func() { config.Config.OIDC, config.Config.Roles = previous, roles })
+	config.Config.Roles = map[string][]string{"class_leader": {"view:read"}}
+	config.Config.OIDC.DefaultRole = "student"
+	config.Config.OIDC.RoleMapping = map[string]string{
+		"teachers":      "teacher",
+		"admins":        "admin",
+		"parents":       "guardian",
+		"class-leaders": "class_leader",
+		"janitors":      "janitor",
+	}
+
+	tests := []struct {
+		claim interface{}
+		want  string
+	}{
+		{"teachers", "teacher"},
+		{"TEACHERS", "teacher"},
+		{"parents", "guardian"},
+		{"class-leaders", "class_leader"},
+		{[]interface{}{"teachers", "admins"}, "admin"},
+		{[]interface{}{"class-leaders", "parents"}, "guardian"},
+		{[]interface{}{"staff", 42, "teachers"}, "teacher"},
+		// Mappings to roles that do not exist are ignored
+		{"janitors", "student"},
+		{"unknown", "student"},
+		{nil, "student"},
+		{[]interface{}{}, "student"},
+	}
+	for _, test := range tests {
+		if got := mapRole(test.claim); got != test.want {
+			t.Errorf("mapRole(%v) = %q, want %q", test.claim, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package ssotest is a local stand-in for the OpenID Connect identity provider of the school, for tests and development.
+// It serves the discovery document, the JWKS and the authorization and token endpoints, and signs the id_tokens
+// with a key generated on start. Every authorization request logs in the user set with Login.
+package ssotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// keyId is the kid of the signing key in the JWKS.
+const keyId = "ssotest"
+
+// User is the account that logs in at the identity provider.
+type User struct {
+	Subject string
+	// Claims are added to the id_token, e.g. "preferred_username", "email" or the role claim.
+	Claims map[string]interface{}
+}
+
+type grant struct {
+	user      User
+	clientId  string
+	nonce     string
+	challenge string
+}
+
+// Server is an identity provider listening on a local http address. Use Server.URL as OIDC.Issuer.
+type Server struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	mutex  sync.Mutex
+	user   User
+	nonce  string
+	grants map[string]grant
+}
+
+// NewServer starts an identity provider. Close it when done.
+func NewServer() (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{key: key, grants: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", server.discovery)
+	mux.HandleFunc("GET /jwks", server.jwks)
+	mux.HandleFunc("GET /authorize", server.authorize)
+	mux.HandleFunc("POST /token", server.token)
+	server.Server = httptest.NewServer(mux)
+	return server, nil
+}
+
+// Login sets the user that is logged in by the following authorization requests.
+func (server *Server) Login(user User) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.user = user
+}
+
+// ForceNonce makes the following id_tokens carry nonce instead of the nonce of the authorization request,
+// like an id_token replayed from another login. Pass "" to reset it.
+func (server *Server) ForceNonce(nonce string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.nonce = nonce
+}
+
+func (server *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                server.URL,
+		"authorization_endpoint":                server.URL + "/authorize",
+		"token_endpoint":                        server.URL + "/token",
+		"jwks_uri":                              server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (server *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyId,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(server.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(server.key.E)).Bytes()),
+		}},
+	})
+}
+
+// randomText returns a random url safe string for codes and access tokens.
+func randomText() string {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// authorize logs in the user set with Login without asking and redirects back with a code.
+func (server *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" || query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "Invalid authorization request.", http.StatusBadRequest)
+		return
+	}
+	code := randomText()
+	server.mutex.Lock()
+	server.grants[code] = grant{
+		user:      server.user,
+		clientId:  query.Get("client_id"),
+		nonce:     query.Get("nonce"),
+		challenge: query.Get("code_challenge"),
+	}
+	server.mutex.Unlock()
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token redeems a code once. The PKCE verifier has to match the challenge of the authorization request.
+func (server *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	server.mutex.Lock()
+	grant, ok := server.grants[r.PostForm.Get("code")]
+	delete(server.grants, r.PostForm.Get("code"))
+	nonce := server.nonce
+	server.mutex.Unlock()
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	if nonce == "" {
+		nonce = grant.nonce
+	}
+	claims := jwt.MapClaims{}
+	for name, value := range grant.user.Claims {
+		claims[name] = value
+	}
+	claims["iss"] = server.URL
+	claims["sub"] = grant.user.Subject
+	claims["aud"] = grant.clientId
+	claims["nonce"] = nonce
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(5 * time.Minute).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyId
+	idToken, err := token.SignedString(server.key)
+	if err != nil {
+		http.Error(w, `{"error":"server_error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": randomText(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
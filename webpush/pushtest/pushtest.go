@@ -0,0 +1,123 @@
+// Package pushtest is a local stand-in for the push service of a browser, for tests and development.
+// It hands out subscriptions, decrypts the notifications sent to them and records them.
+package pushtest
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/webpush"
+)
+
+// Message is a notification the push service received.
+type Message struct {
+	Endpoint string
+	// Authorization header, "vapid t=<jwt>, k=<public key>".
+	Authorization string
+	TTL           string
+	Urgency       string
+	Payload       []byte
+}
+
+type subscriber struct {
+	key        *ecdh.PrivateKey
+	authSecret []byte
+	gone       bool
+}
+
+// Server is a push service listening on a local https address, as subscriptions have to use https.
+// Set the Client of the VAPIDSender to Server.Client(), it trusts the certificate of the server.
+type Server struct {
+	*httptest.Server
+	mutex       sync.Mutex
+	subscribers map[string]*subscriber
+	messages    []Message
+	nextId      int
+}
+
+// NewServer starts a push service. Close it when done.
+func NewServer() *Server {
+	server := &Server{subscribers: map[string]*subscriber{}}
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+// Subscribe creates a subscription like a browser does with PushManager.subscribe.
+func (server *Server) Subscribe() (webpush.Subscription, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return webpush.Subscription{}, err
+	}
+	authSecret := make([]byte, 16)
+	if _, err := rand.Read(authSecret); err != nil {
+		return webpush.Subscription{}, err
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.nextId++
+	endpoint := server.URL + "/push/" + strconv.Itoa(server.nextId)
+	server.subscribers[endpoint] = &subscriber{key: key, authSecret: authSecret}
+	return webpush.Subscription{
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(authSecret),
+	}, nil
+}
+
+// Unsubscribe makes the push service answer 410 Gone for the subscription, like after the user revoked the permission.
+func (server *Server) Unsubscribe(subscription webpush.Subscription) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if sub, ok := server.subscribers[subscription.Endpoint]; ok {
+		sub.gone = true
+	}
+}
+
+// Messages returns the notifications received so far.
+func (server *Server) Messages() []Message {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]Message(nil), server.messages...)
+}
+
+func (server *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Authorization"), "vapid ") {
+		http.Error(w, "Invalid push request.", http.StatusBadRequest)
+		return
+	}
+	endpoint := server.URL + r.URL.Path
+	server.mutex.Lock()
+	sub, ok := server.subscribers[endpoint]
+	server.mutex.Unlock()
+	if !ok || sub.gone {
+		http.Error(w, "Subscription gone.", http.StatusGone)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid body.", http.StatusBadRequest)
+		return
+	}
+	payload, err := webpush.Decrypt(sub.key, sub.authSecret, body)
+	if err != nil {
+		http.Error(w, "Decryption failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.mutex.Lock()
+	server.messages = append(server.messages, Message{
+		Endpoint:      endpoint,
+		Authorization: r.Header.Get("Authorization"),
+		TTL:           r.Header.Get("TTL"),
+		Urgency:       r.Header.Get("Urgency"),
+		Payload:       payload,
+	})
+	server.mutex.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
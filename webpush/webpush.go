@@ -0,0 +1,254 @@
+// Package webpush sends notifications to browsers through their push services (RFC 8030),
+// encrypted for the subscription (RFC 8291) and signed with the VAPID key of the server (RFC 8292).
+package webpush
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/config"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrSubscriptionGone is returned if the push service no longer knows the subscription. It should be deleted.
+var ErrSubscriptionGone = errors.New("webpush: The subscription expired or was removed")
+
+// Subscription is the PushSubscription of a browser. P256dh and Auth are base64url encoded.
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// Sender delivers a payload to a subscription.
+type Sender interface {
+	Send(subscription Subscription, payload []byte) error
+}
+
+var DefaultSender Sender
+
+// InitWebPush selects the sender configured in WebPush.Type.
+func InitWebPush() {
+	if config.Config.WebPush.Type == "log" {
+		DefaultSender = LogSender{}
+		return
+	}
+	sender, err := NewVAPIDSender(config.Config.WebPush.VAPIDPrivateKey, config.Config.WebPush.Subject, config.Config.WebPush.TTL)
+	if err != nil {
+		log.Printf("Web push disabled, the VAPID key is invalid: %s", err.Error())
+		DefaultSender = LogSender{}
+		return
+	}
+	DefaultSender = sender
+}
+
+// VAPIDSender sends payloads to the push services of the browsers.
+type VAPIDSender struct {
+	privateKey *ecdsa.PrivateKey
+	// Raw uncompressed public key, sent as k in the Authorization header.
+	publicKey []byte
+	subject   string
+	ttl       time.Duration
+	Client    *http.Client
+}
+
+// NewVAPIDSender creates a sender for the base64url encoded private key of a VAPID key pair.
+func NewVAPIDSender(privateKey string, subject string, ttl time.Duration) (*VAPIDSender, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	ecdhKey, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	// The ecdsa key is derived through PKCS #8, crypto/ecdh keys can not sign.
+	der, err := x509.MarshalPKCS8PrivateKey(ecdhKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return &VAPIDSender{
+		privateKey: key.(*ecdsa.PrivateKey),
+		publicKey:  ecdhKey.PublicKey().Bytes(),
+		subject:    subject,
+		ttl:        ttl,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (sender *VAPIDSender) Send(subscription Subscription, payload []byte) error {
+	body, err := Encrypt(subscription, payload)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+	// The token is only valid for the origin of the push service
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{endpoint.Scheme + "://" + endpoint.Host},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(12 * time.Hour)),
+		Subject:   sender.subject,
+	}).SignedString(sender.privateKey)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "vapid t="+token+", k="+base64.RawURLEncoding.EncodeToString(sender.publicKey))
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(sender.ttl.Seconds())))
+	req.Header.Set("Urgency", "high")
+	resp, err := sender.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("webpush: The push service answered %s", resp.Status)
+	}
+	return nil
+}
+
+// LogSender only logs that a notification would have been sent.
+type LogSender struct{}
+
+func (sender LogSender) Send(subscription Subscription, payload []byte) error {
+	log.Printf("Web push disabled. Dropped notification to %s: %s", subscription.Endpoint, payload)
+	return nil
+}
+
+// Encrypt encrypts a payload for a subscription with the aes128gcm content coding of RFC 8291, as a single record.
+func Encrypt(subscription Subscription, payload []byte) ([]byte, error) {
+	userKeyBytes, err := base64.RawURLEncoding.DecodeString(subscription.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	userKey, err := ecdh.P256().NewPublicKey(userKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(subscription.Auth)
+	if err != nil {
+		return nil, err
+	}
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverKey.ECDH(userKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+	gcm, nonce, err := contentKeys(sharedSecret, authSecret, salt, userKeyBytes, serverPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// The padding delimiter 0x02 marks the last record
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(ciphertext)))
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return append(header, ciphertext...), nil
+}
+
+// contentKeys derives the content encryption key and the nonce of RFC 8291 section 3.4.
+// userPublic is the key of the subscription, serverPublic the ephemeral key of the sender.
+func contentKeys(sharedSecret []byte, authSecret []byte, salt []byte, userPublic []byte, serverPublic []byte) (cipher.AEAD, []byte, error) {
+	keyInfo := append([]byte("WebPush: info\x00"), userPublic...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, nil, err
+	}
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	return gcm, nonce, err
+}
+
+// Decrypt reverses Encrypt with the private key and the auth secret of the subscription.
+// Browsers do this themselves, it is used by the push service stand-in in package pushtest.
+func Decrypt(userKey *ecdh.PrivateKey, authSecret []byte, body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("webpush: The body is too short")
+	}
+	salt := body[:16]
+	keyLength := int(body[20])
+	if len(body) < 21+keyLength {
+		return nil, errors.New("webpush: The body is too short")
+	}
+	serverPublic := body[21 : 21+keyLength]
+	serverKey, err := ecdh.P256().NewPublicKey(serverPublic)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := userKey.ECDH(serverKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, nonce, err := contentKeys(sharedSecret, authSecret, salt, userKey.PublicKey().Bytes(), serverPublic)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, body[21+keyLength:], nil)
+	if err != nil {
+		return nil, err
+	}
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("webpush: Missing padding delimiter")
+	}
+	return plaintext[:len(plaintext)-1], nil
+}
@@ -0,0 +1,60 @@
+package webpush_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TooManyFiles/TMF-Timetable-Backend/webpush"
+	"github.com/TooManyFiles/TMF-Timetable-Backend/webpush/pushtest"
+)
+
+// newSender creates a VAPIDSender with a new key that trusts the push service.
+func newSender(t *testing.T, server *pushtest.Server) *webpush.VAPIDSender {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := webpush.NewVAPIDSender(base64.RawURLEncoding.EncodeToString(key.Bytes()), "mailto:admin@school.example", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.Client = server.Client()
+	return sender
+}
+
+func TestVAPIDSender(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+	sender := newSender(t, server)
+	subscription, err := server.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"title":"Lesson cancelled","body":"Math at 08:00"}`)
+	if err := sender.Send(subscription, payload); err != nil {
+		t.Fatal(err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("the push service received %d messages, want 1", len(messages))
+	}
+	message := messages[0]
+	if string(message.Payload) != string(payload) {
+		t.Errorf("decrypted payload = %s, want %s", message.Payload, payload)
+	}
+	if message.Endpoint != subscription.Endpoint || message.TTL != "3600" || !strings.HasPrefix(message.Authorization, "vapid t=") {
+		t.Errorf("message = %+v", message)
+	}
+
+	server.Unsubscribe(subscription)
+	if err := sender.Send(subscription, payload); !errors.Is(err, webpush.ErrSubscriptionGone) {
+		t.Errorf("send to a removed subscription: error = %v, want %v", err, webpush.ErrSubscriptionGone)
+	}
+}